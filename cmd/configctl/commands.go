@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"sass.com/configsvc/pkg/client"
+)
+
+func runLogin(e *env, args []string) error {
+	fs := e.flags("login")
+	server := fs.String("server", "", "base URL of the service, like http://localhost:8089")
+	username := fs.String("username", "", "username")
+	password := fs.String("password", "", "password, defaults to $CONFIGCTL_PASSWORD or a prompt")
+	if _, err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	p := profiles.Profiles[e.profile]
+	if *server != "" {
+		p.Server = *server
+	}
+	if *username != "" {
+		p.Username = *username
+	}
+	if p.Server == "" || p.Username == "" {
+		return fmt.Errorf("%w: --server and --username are required", errUsage)
+	}
+	if *password == "" {
+		*password = os.Getenv("CONFIGCTL_PASSWORD")
+	}
+	if *password == "" {
+		fmt.Fprint(e.stderr, "Password: ")
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	c, err := client.New(client.Options{
+		BaseURL:  p.Server,
+		Username: p.Username,
+		Password: *password,
+		OnTokens: func(access, refresh string) {
+			p.AccessToken, p.RefreshToken = access, refresh
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.Login(e.ctx); err != nil {
+		return err
+	}
+
+	profiles.Profiles[e.profile] = p
+	if err := profiles.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "logged in to %s as %s (profile %q)\n", p.Server, p.Username, e.profile)
+	return nil
+}
+
+func runGet(e *env, args []string) error {
+	fs := e.flags("get")
+	version := fs.Int("version", 0, "version to get, defaults to the latest")
+	pos, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	var cfg *client.Config
+	if *version > 0 {
+		cfg, err = c.GetVersion(e.ctx, pos[0], *version)
+	} else {
+		cfg, err = c.GetLatest(e.ctx, pos[0])
+	}
+	if err != nil {
+		return err
+	}
+	return printConfig(e, cfg)
+}
+
+func runList(e *env, args []string) error {
+	fs := e.flags("list")
+	var opts client.ListOptions
+	fs.StringVar(&opts.Prefix, "prefix", "", "only names starting with prefix")
+	fs.StringVar(&opts.Query, "query", "", "only names containing query")
+	fs.StringVar(&opts.Type, "type", "", "only configs of this type")
+	if _, err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	configs, err := listAll(e, c, opts)
+	if err != nil {
+		return err
+	}
+	return printConfigs(e, configs)
+}
+
+// listAll follows the cursors of the list endpoint
+func listAll(e *env, c *client.Client, opts client.ListOptions) ([]client.Config, error) {
+	var configs []client.Config
+	for {
+		page, err := c.List(e.ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, page.Items...)
+		if page.NextCursor == "" {
+			return configs, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func runHistory(e *env, args []string) error {
+	fs := e.flags("history")
+	pos, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	var versions []client.Config
+	cursor := ""
+	for {
+		page, err := c.Versions(e.ctx, pos[0], 0, cursor)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, page.Items...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(versions) == 0 {
+		return client.ErrNotFound
+	}
+
+	return e.print(versions, func(w io.Writer) {
+		fmt.Fprintln(w, "VERSION\tTYPE\tCREATED BY\tCREATED AT")
+		for _, v := range versions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", v.Version, v.Type, v.CreatedBy, formatTime(v.CreatedAt))
+		}
+	})
+}
+
+func runDiff(e *env, args []string) error {
+	fs := e.flags("diff")
+	from := fs.Int("from", 0, "version to compare from")
+	to := fs.Int("to", 0, "version to compare to, defaults to the latest")
+	pos, err := e.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *from <= 0 {
+		return fmt.Errorf("%w: --from is required", errUsage)
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	diff, err := c.Diff(e.ctx, pos[0], *from, *to)
+	if err != nil {
+		return err
+	}
+	return e.print(diff, func(w io.Writer) {
+		if len(diff.Summary) == 0 {
+			fmt.Fprintf(w, "no changes between version %d and %d\n", diff.From, diff.To)
+			return
+		}
+		fmt.Fprintln(w, "CHANGE\tPATH\tOLD\tNEW")
+		for _, ch := range diff.Summary {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ch.Type, ch.Path, compactJSON(ch.Old), compactJSON(ch.New))
+		}
+	})
+}
+
+func runApply(e *env, args []string) error {
+	fs := e.flags("apply")
+	file := fs.String("f", "", "file with the configs, - reads stdin")
+	force := fs.Bool("force", false, "store schema changes that are not backward compatible, admins only")
+	if _, err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	return writeManifests(e, *file, true, *force)
+}
+
+func runImport(e *env, args []string) error {
+	fs := e.flags("import")
+	file := fs.String("f", "", "file written by export, - reads stdin")
+	overwrite := fs.Bool("overwrite", false, "update configs that already exist and differ")
+	if _, err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	return writeManifests(e, *file, *overwrite, false)
+}
+
+// result is what apply and import did with a config
+type result struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	Result  string `json:"result"`
+}
+
+// writeManifests creates the configs of a file that are missing and, with
+// overwrite, updates the ones that differ. Nothing is written unless every
+// config in the file is valid.
+func writeManifests(e *env, file string, overwrite, force bool) error {
+	if err := requireFile(file); err != nil {
+		return err
+	}
+	manifests, err := readManifests(file)
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := validateAll(e, c, manifests, force); err != nil {
+		return err
+	}
+
+	var results []result
+	var failed error
+	for _, m := range manifests {
+		r, err := writeManifest(e, c, m, overwrite, force)
+		if err != nil {
+			failed = fmt.Errorf("%s: %w", m.Name, err)
+			break
+		}
+		results = append(results, r)
+	}
+
+	if err := e.print(results, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tVERSION\tRESULT")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", r.Name, r.Version, r.Result)
+		}
+	}); err != nil {
+		return err
+	}
+	return failed
+}
+
+func writeManifest(e *env, c *client.Client, m manifest, overwrite, force bool) (result, error) {
+	in, err := m.input()
+	if err != nil {
+		return result{}, err
+	}
+	in.Force = force
+
+	latest, err := c.GetLatest(e.ctx, m.Name)
+	if errors.Is(err, client.ErrNotFound) {
+		cfg, err := c.Create(e.ctx, in)
+		if err != nil {
+			return result{}, err
+		}
+		return result{Name: cfg.Name, Version: cfg.Version, Result: "created"}, nil
+	}
+	if err != nil {
+		return result{}, err
+	}
+	if latest.Stale {
+		return result{}, errors.New("service unavailable")
+	}
+
+	if latest.Type == in.Type && jsonEqual(latest.Schema, in.Schema) && jsonEqual(latest.Input, in.Input) {
+		return result{Name: latest.Name, Version: latest.Version, Result: "unchanged"}, nil
+	}
+	if !overwrite {
+		return result{Name: latest.Name, Version: latest.Version, Result: "skipped"}, nil
+	}
+	// the version read above is expected, so a concurrent write is not lost
+	cfg, err := c.Update(e.ctx, in, latest.Version)
+	if err != nil {
+		return result{}, err
+	}
+	return result{Name: cfg.Name, Version: cfg.Version, Result: "updated"}, nil
+}
+
+func jsonEqual(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func runRollback(e *env, args []string) error {
+	fs := e.flags("rollback")
+	force := fs.Bool("force", false, "restore a schema that is not backward compatible, admins only")
+	pos, err := e.parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	version, err := strconv.Atoi(pos[1])
+	if err != nil || version <= 0 {
+		return fmt.Errorf("%w: invalid version %q", errUsage, pos[1])
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := c.Rollback(e.ctx, pos[0], version, 0, *force)
+	if err != nil {
+		return err
+	}
+	return printConfig(e, cfg)
+}
+
+func runValidate(e *env, args []string) error {
+	fs := e.flags("validate")
+	file := fs.String("f", "", "file with the configs, - reads stdin")
+	if _, err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := requireFile(*file); err != nil {
+		return err
+	}
+	manifests, err := readManifests(*file)
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := validateAll(e, c, manifests, false); err != nil {
+		return err
+	}
+
+	names := make([]string, len(manifests))
+	for i, m := range manifests {
+		names[i] = m.Name
+	}
+	return e.print(map[string]interface{}{"valid": names}, func(w io.Writer) {
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\tvalid\n", name)
+		}
+	})
+}
+
+// runExport writes the latest version of every config as manifests that
+// import and apply read back, YAML unless -o json
+func runExport(e *env, args []string) error {
+	fs := e.flags("export")
+	var opts client.ListOptions
+	fs.StringVar(&opts.Prefix, "prefix", "", "only names starting with prefix")
+	if _, err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if e.output == "table" {
+		e.output = "yaml"
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	configs, err := listAll(e, c, opts)
+	if err != nil {
+		return err
+	}
+	manifests := make([]manifest, 0, len(configs))
+	for _, cfg := range configs {
+		m, err := toManifest(cfg)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, m)
+	}
+	return e.print(manifests, nil)
+}
@@ -0,0 +1,191 @@
+// configctl drives the config service from a shell or a pipeline.
+//
+//	configctl login --server http://localhost:8089 --username alice
+//	configctl get payments.limits -o yaml
+//	configctl apply -f payments.yaml
+//
+// Exit codes: 0 success, 1 failed request, 2 usage error, 3 invalid
+// config, 4 config not found.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"sass.com/configsvc/pkg/client"
+)
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitInvalid  = 3
+	exitNotFound = 4
+)
+
+// errUsage marks errors caused by how the command was called
+var errUsage = errors.New("usage error")
+
+// errInvalid marks configs that failed validation
+var errInvalid = errors.New("invalid config")
+
+// stdin is read by login when no password is given
+var stdin io.Reader = os.Stdin
+
+type command struct {
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"login":    {"login --server URL --username NAME [--password PASSWORD]", runLogin},
+	"get":      {"get NAME [--version N]", runGet},
+	"list":     {"list [--prefix P] [--query Q] [--type T]", runList},
+	"history":  {"history NAME", runHistory},
+	"diff":     {"diff NAME --from N [--to M]", runDiff},
+	"apply":    {"apply -f FILE", runApply},
+	"rollback": {"rollback NAME VERSION", runRollback},
+	"validate": {"validate -f FILE", runValidate},
+	"export":   {"export [--prefix P]", runExport},
+	"import":   {"import -f FILE [--overwrite]", runImport},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	e := &env{ctx: context.Background(), stdout: stdout, stderr: stderr, usage: cmd.usage}
+	err := cmd.run(e, args[1:])
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(stderr, "error:", err)
+		fmt.Fprintln(stderr, "usage: configctl", cmd.usage)
+		return exitUsage
+	case errors.Is(err, errInvalid):
+		fmt.Fprintln(stderr, "error:", err)
+		return exitInvalid
+	case errors.Is(err, client.ErrNotFound):
+		fmt.Fprintln(stderr, "error:", err)
+		return exitNotFound
+	default:
+		fmt.Fprintln(stderr, "error:", err)
+		return exitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: configctl <command> [flags]")
+	fmt.Fprintln(w, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(w, "  "+commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nevery command takes --profile NAME (default \"default\") and -o table|json|yaml")
+}
+
+// env is what a command runs with
+type env struct {
+	ctx     context.Context
+	stdout  io.Writer
+	stderr  io.Writer
+	usage   string
+	profile string
+	output  string
+}
+
+// flags returns a flag set with the options shared by every command
+func (e *env) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.StringVar(&e.profile, "profile", "default", "profile to use")
+	fs.StringVar(&e.output, "o", "table", "output format: table, json or yaml")
+	return fs
+}
+
+// parse parses args, flags may come before or after the wantArgs positional arguments
+func (e *env) parse(fs *flag.FlagSet, args []string, wantArgs int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != wantArgs {
+		return nil, fmt.Errorf("%w: expected %d argument(s), got %d", errUsage, wantArgs, len(positional))
+	}
+	switch e.output {
+	case "table", "json", "yaml":
+	default:
+		return nil, fmt.Errorf("%w: unknown output format %q", errUsage, e.output)
+	}
+	return positional, nil
+}
+
+// client returns a client for the selected profile that saves renewed tokens
+func (e *env) client() (*client.Client, error) {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := profiles.Profiles[e.profile]
+	if !ok || p.Server == "" {
+		return nil, fmt.Errorf("profile %q is not logged in, run configctl login first", e.profile)
+	}
+
+	return client.New(client.Options{
+		BaseURL:      p.Server,
+		Token:        p.AccessToken,
+		RefreshToken: p.RefreshToken,
+		OnTokens: func(access, refresh string) {
+			p.AccessToken, p.RefreshToken = access, refresh
+			profiles.Profiles[e.profile] = p
+			if err := profiles.save(); err != nil {
+				fmt.Fprintln(e.stderr, "warning: failed to save renewed tokens:", err)
+			}
+		},
+	})
+}
+
+func requireFile(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("%w: -f FILE is required", errUsage)
+	}
+	return nil
+}
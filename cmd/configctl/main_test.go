@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/auth"
+	"sass.com/configsvc/internal/cache"
+	"sass.com/configsvc/internal/config"
+	configdata "sass.com/configsvc/internal/config_data"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
+	"sass.com/configsvc/internal/policy"
+	schemaregistry "sass.com/configsvc/internal/schema_registry"
+	"sass.com/configsvc/internal/secrets"
+)
+
+const dbSchema = `{"type":"object","properties":{"host":{"type":"string"},"port":{"type":"integer"}},"required":["host"]}`
+
+func TestMain(m *testing.M) {
+	cache.Init()
+	os.Exit(m.Run())
+}
+
+// newTestServer serves the auth and config routes of cmd/server with a
+// single admin, alice, of tenant acme, who registered the schema db
+func newTestServer(t *testing.T) *httptest.Server {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "config.db")+"?_txlock=immediate"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.UserRevocation{},
+		&models.Configurations{}, &models.LastConfigurations{}, &models.ConfigEvent{}, &models.OutboxEvent{}, &models.ConfigGrant{},
+		&models.RegisteredSchema{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	secs := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	revocations, err := auth.NewRevocationList(auth.NewRevocationRepo(db))
+	if err != nil {
+		t.Fatalf("failed to load revocations: %v", err)
+	}
+	userRepo := auth.NewUserRepo(db)
+	authService := auth.NewAuthService(userRepo, auth.NewTokenRepo(db), revocations,
+		&config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}, secs)
+	if _, err := auth.NewUserService(userRepo, authService).CreateUser("acme", "alice", "password123", models.RoleAdmin); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	authHandler := auth.NewAuthHandler(authService)
+	configHandler := configdata.NewConfigHandler(configdata.NewConfigService(configdata.NewConfigRepo(db), notify.New(notify.DefaultMaxWaiters)))
+	policyService := policy.NewPolicyService(policy.NewPolicyRepo(db))
+	schemaService := schemaregistry.NewSchemaService(schemaregistry.NewSchemaRepo(db))
+	configdata.UseSchemaRegistry(schemaService)
+	t.Cleanup(func() { configdata.UseSchemaRegistry(nil) })
+	if _, _, err := schemaService.Register("acme", "alice", "db", schemaregistry.SchemaInput{Schema: dbSchema}); err != nil {
+		t.Fatalf("failed to register schema: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/v1/login", func(c *gin.Context) { authHandler.Login(c.Writer, c.Request) })
+	r.POST("/api/v1/token/refresh", func(c *gin.Context) { authHandler.Refresh(c.Writer, c.Request) })
+	api := r.Group("/api/v1")
+	api.Use(auth.AuthMiddleware(secs, revocations))
+	byName := policy.NameFromParam("name")
+	canRead := policy.RequireConfigAccess(policyService, policy.ActionRead, byName)
+	api.GET("/configs", policy.ScopeConfigAccess(policyService, policy.ActionRead), configHandler.ListConfigs)
+	canCreate := policy.RequireConfigAccess(policyService, policy.ActionWrite, policy.NameFromBody("name"))
+	canWrite := policy.RequireConfigAccess(policyService, policy.ActionWrite, byName)
+	api.POST("/configs", canCreate, configHandler.CreateConfig)
+	api.PUT("/configs/:name", canWrite, configHandler.UpdateConfig)
+	api.POST("/configs/validate", configdata.NormalizeConfigBody(), canCreate, configHandler.ValidateNewConfig)
+	api.POST("/configs/:name/validate", configdata.NormalizeConfigBody(), canWrite, configHandler.ValidateConfig)
+	api.POST("/configs/:name/rollback/:version", policy.RequireConfigAccess(policyService, policy.ActionPublish, byName), configHandler.RollbackConfig)
+	api.GET("/configs/:name/latest", canRead, configHandler.GetLastVersionByName)
+	api.GET("/configs/:name/versions/:version", canRead, configHandler.GetConfigByNameByVersion)
+	api.GET("/configs/:name/versions", canRead, configHandler.GetConfigVersions)
+	api.GET("/configs/:name/diff", canRead, configHandler.DiffVersions)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// configctl runs the command and returns its exit code and output
+func configctl(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+const flagsYAML = `name: ctl.flags
+type: object
+schema:
+  type: object
+  properties:
+    enabled: {type: boolean}
+    limit: {type: integer}
+  required: [enabled]
+input:
+  enabled: true
+  limit: %d
+---
+name: ctl.banner
+type: object
+schema: {type: object}
+input: {text: hello}
+`
+
+func TestReadManifests(t *testing.T) {
+	multi := writeFile(t, "multi.yaml", strings.Replace(flagsYAML, "%d", "5", 1))
+	list := writeFile(t, "list.yml", "- name: a\n  schema: {type: object}\n  input: {}\n- name: b\n  schema: {type: object}\n  input: {}\n")
+	single := writeFile(t, "single.json", `{"name":"c","type":"object","schema":{"type":"object"},"input":{"x":1}}`)
+	broken := writeFile(t, "broken.json", `{"name":`)
+
+	for path, want := range map[string][]string{multi: {"ctl.flags", "ctl.banner"}, list: {"a", "b"}, single: {"c"}} {
+		manifests, err := readManifests(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if len(manifests) != len(want) {
+			t.Fatalf("%s: expected %d manifests, got %d", path, len(want), len(manifests))
+		}
+		for i, m := range manifests {
+			if m.Name != want[i] {
+				t.Errorf("%s: expected %s, got %s", path, want[i], m.Name)
+			}
+		}
+	}
+
+	in, err := mustRead(t, multi)[0].input()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !jsonEqual(in.Input, `{"enabled":true,"limit":5}`) {
+		t.Errorf("unexpected input %s", in.Input)
+	}
+
+	if _, err := readManifests(broken); err == nil {
+		t.Error("expected an error for a broken file")
+	}
+}
+
+func mustRead(t *testing.T, path string) []manifest {
+	manifests, err := readManifests(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return manifests
+}
+
+func TestRun_Workflow(t *testing.T) {
+	srv := newTestServer(t)
+	t.Setenv("CONFIGCTL_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+
+	if code, _, stderr := configctl("get", "ctl.flags"); code != exitError || !strings.Contains(stderr, "not logged in") {
+		t.Fatalf("expected exit %d before login, got %d: %s", exitError, code, stderr)
+	}
+	if code, _, stderr := configctl("login", "--server", srv.URL, "--username", "alice", "--password", "wrong"); code != exitError {
+		t.Fatalf("expected exit %d for a wrong password, got %d: %s", exitError, code, stderr)
+	}
+	if code, _, stderr := configctl("login", "--server", srv.URL, "--username", "alice", "--password", "password123"); code != exitOK {
+		t.Fatalf("login failed with %d: %s", code, stderr)
+	}
+
+	v1 := writeFile(t, "v1.yaml", strings.Replace(flagsYAML, "%d", "5", 1))
+	code, stdout, stderr := configctl("apply", "-f", v1, "-o", "json")
+	if code != exitOK {
+		t.Fatalf("apply failed with %d: %s", code, stderr)
+	}
+	var results []result
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("unexpected output %q: %v", stdout, err)
+	}
+	if len(results) != 2 || results[0].Result != "created" || results[1].Result != "created" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	if _, stdout, _ := configctl("apply", "-f", v1); !strings.Contains(stdout, "unchanged") || strings.Contains(stdout, "updated") {
+		t.Errorf("expected unchanged configs, got:\n%s", stdout)
+	}
+	v2 := writeFile(t, "v2.yaml", strings.Replace(flagsYAML, "%d", "9", 1))
+	if code, stdout, _ := configctl("apply", "-f", v2); code != exitOK || !strings.Contains(stdout, "updated") {
+		t.Errorf("expected an update, got %d:\n%s", code, stdout)
+	}
+
+	code, stdout, _ = configctl("get", "ctl.flags", "-o", "json")
+	var cfg struct {
+		Version int
+		Input   string
+	}
+	if code != exitOK || json.Unmarshal([]byte(stdout), &cfg) != nil || cfg.Version != 2 || !jsonEqual(cfg.Input, `{"enabled":true,"limit":9}`) {
+		t.Fatalf("unexpected get output %d:\n%s", code, stdout)
+	}
+
+	if code, stdout, _ := configctl("diff", "ctl.flags", "--from", "1"); code != exitOK || !strings.Contains(stdout, "/limit") {
+		t.Errorf("expected the limit change, got %d:\n%s", code, stdout)
+	}
+	if code, _, stderr := configctl("rollback", "ctl.flags", "1"); code != exitOK {
+		t.Fatalf("rollback failed with %d: %s", code, stderr)
+	}
+	code, stdout, _ = configctl("history", "ctl.flags", "-o", "yaml")
+	if code != exitOK || strings.Count(stdout, " Version:") != 3 {
+		t.Errorf("expected 3 versions, got %d:\n%s", code, stdout)
+	}
+
+	// export and import round trip: nothing changes
+	code, exported, stderr := configctl("export", "--prefix", "ctl.")
+	if code != exitOK {
+		t.Fatalf("export failed with %d: %s", code, stderr)
+	}
+	dump := writeFile(t, "export.yaml", exported)
+	if code, stdout, stderr := configctl("import", "-f", dump); code != exitOK || strings.Count(stdout, "unchanged") != 2 {
+		t.Errorf("expected unchanged configs, got %d:\n%s%s", code, stdout, stderr)
+	}
+	if code, stdout, _ := configctl("import", "-f", v2); code != exitOK || !strings.Contains(stdout, "skipped") {
+		t.Errorf("expected import to skip a changed config, got %d:\n%s", code, stdout)
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	srv := newTestServer(t)
+	t.Setenv("CONFIGCTL_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	t.Setenv("CONFIGCTL_PASSWORD", "password123")
+	if code, _, stderr := configctl("login", "--server", srv.URL, "--username", "alice"); code != exitOK {
+		t.Fatalf("login failed with %d: %s", code, stderr)
+	}
+
+	invalid := writeFile(t, "invalid.yaml", "name: ctl.invalid\nschema: {type: object, required: [enabled]}\ninput: {}\n")
+	valid := writeFile(t, "valid.json", `{"name":"ctl.valid","type":"object","schema":{"type":"object"},"input":{}}`)
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, exitUsage},
+		{[]string{"frobnicate"}, exitUsage},
+		{[]string{"get"}, exitUsage},
+		{[]string{"get", "ctl.flags", "-o", "xml"}, exitUsage},
+		{[]string{"apply"}, exitUsage},
+		{[]string{"rollback", "ctl.flags", "latest"}, exitUsage},
+		{[]string{"get", "ctl.missing"}, exitNotFound},
+		{[]string{"validate", "-f", invalid}, exitInvalid},
+		{[]string{"apply", "-f", invalid}, exitInvalid},
+		{[]string{"validate", "-f", valid}, exitOK},
+		{[]string{"get", "ctl.invalid"}, exitNotFound},
+	}
+	for _, tt := range tests {
+		if code, _, stderr := configctl(tt.args...); code != tt.want {
+			t.Errorf("configctl %v: expected exit %d, got %d: %s", tt.args, tt.want, code, stderr)
+		}
+	}
+}
+
+func TestRun_ValidateWithService(t *testing.T) {
+	srv := newTestServer(t)
+	t.Setenv("CONFIGCTL_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	t.Setenv("CONFIGCTL_PASSWORD", "password123")
+	if code, _, stderr := configctl("login", "--server", srv.URL, "--username", "alice"); code != exitOK {
+		t.Fatalf("login failed with %d: %s", code, stderr)
+	}
+
+	// Refs to registered schemas resolve on the service, nothing is read locally
+	const manifest = "name: ctl.db\nschema: {$ref: /schemas/db/versions/1}\ninput: %s\n"
+	valid := writeFile(t, "valid.yaml", fmt.Sprintf(manifest, "{host: db.internal, port: 5432}"))
+	if code, stdout, stderr := configctl("validate", "-f", valid); code != exitOK || strings.Join(strings.Fields(stdout), " ") != "ctl.db valid" {
+		t.Fatalf("expected ctl.db to be valid, got %d:\n%s%s", code, stdout, stderr)
+	}
+	invalid := writeFile(t, "invalid.yaml", fmt.Sprintf(manifest, "{port: db}"))
+	code, _, stderr := configctl("validate", "-f", invalid)
+	if code != exitInvalid || !strings.Contains(stderr, "ctl.db: input does not match the schema: /port: ") ||
+		!strings.Contains(stderr, "missing properties: 'host'") {
+		t.Fatalf("expected each violation to be reported, got %d: %s", code, stderr)
+	}
+	unknown := writeFile(t, "unknown.yaml", "name: ctl.db\nschema: {$ref: /schemas/cache/versions/1}\ninput: {}\n")
+	if code, _, stderr := configctl("validate", "-f", unknown); code != exitInvalid || !strings.Contains(stderr, "not registered") {
+		t.Fatalf("expected an unregistered schema to be reported, got %d: %s", code, stderr)
+	}
+
+	// Once the config exists, validate checks an update of it
+	if code, _, stderr := configctl("apply", "-f", valid); code != exitOK {
+		t.Fatalf("apply failed with %d: %s", code, stderr)
+	}
+	narrower := writeFile(t, "narrower.yaml", "name: ctl.db\nschema: {type: object, required: [host, port]}\ninput: {host: db, port: 1}\n")
+	if code, _, stderr := configctl("validate", "-f", narrower); code != exitInvalid || !strings.Contains(stderr, "not backward compatible") {
+		t.Fatalf("expected the schema change to be rejected, got %d: %s", code, stderr)
+	}
+}
+
+func TestRun_ValidateFormatsAndKeywords(t *testing.T) {
+	srv := newTestServer(t)
+	t.Setenv("CONFIGCTL_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	t.Setenv("CONFIGCTL_PASSWORD", "password123")
+	if code, _, stderr := configctl("login", "--server", srv.URL, "--username", "alice"); code != exitOK {
+		t.Fatalf("login failed with %d: %s", code, stderr)
+	}
+
+	// The formats and x- keywords of the service, which a compiler of the
+	// CLI would not know, decide like they do on writes
+	const manifest = `name: ctl.timeouts
+schema:
+  type: object
+  properties:
+    timeout: {type: string, format: duration, x-max-duration: 1m}
+    schedule: {type: string, format: cron}
+input: %s
+`
+	valid := writeFile(t, "valid.yaml", fmt.Sprintf(manifest, `{timeout: 30s, schedule: "*/5 * * * *"}`))
+	if code, _, stderr := configctl("validate", "-f", valid); code != exitOK {
+		t.Fatalf("expected ctl.timeouts to be valid, got %d: %s", code, stderr)
+	}
+	invalid := writeFile(t, "invalid.yaml", fmt.Sprintf(manifest, `{timeout: 2m, schedule: often}`))
+	code, _, stderr := configctl("validate", "-f", invalid)
+	if code != exitInvalid || !strings.Contains(stderr, "/timeout: must be <= 1m0s but found 2m") ||
+		!strings.Contains(stderr, "/schedule: ") {
+		t.Fatalf("expected both violations to be reported, got %d: %s", code, stderr)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"sass.com/configsvc/pkg/client"
+)
+
+// manifest is a config as written in the files of apply, validate, export
+// and import. Schema and input are documents, not JSON strings.
+type manifest struct {
+	Name   string      `json:"name" yaml:"name"`
+	Type   string      `json:"type" yaml:"type"`
+	Schema interface{} `json:"schema" yaml:"schema"`
+	Input  interface{} `json:"input" yaml:"input"`
+}
+
+// readManifests reads a file, or stdin for "-". A file holds one manifest,
+// a list of them, or for YAML several documents.
+func readManifests(path string) ([]manifest, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []manifest
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		manifests, err = parseJSONManifests(data)
+	default:
+		// YAML is a superset of JSON, so stdin and unknown extensions go here
+		manifests, err = parseYAMLManifests(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errInvalid, path, err)
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("%w: %s: no configs in file", errInvalid, path)
+	}
+	return manifests, nil
+}
+
+func parseJSONManifests(data []byte) ([]manifest, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var manifests []manifest
+		err := json.Unmarshal(data, &manifests)
+		return manifests, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return []manifest{m}, nil
+}
+
+func parseYAMLManifests(data []byte) ([]manifest, error) {
+	var manifests []manifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return manifests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+			var list []manifest
+			if err := node.Decode(&list); err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, list...)
+			continue
+		}
+		var m manifest
+		if err := node.Decode(&m); err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+}
+
+// input returns the manifest as the JSON strings the service stores
+func (m manifest) input() (client.ConfigInput, error) {
+	schema, err := json.Marshal(m.Schema)
+	if err != nil {
+		return client.ConfigInput{}, fmt.Errorf("schema: %v", err)
+	}
+	input, err := json.Marshal(m.Input)
+	if err != nil {
+		return client.ConfigInput{}, fmt.Errorf("input: %v", err)
+	}
+	return client.ConfigInput{Name: m.Name, Type: m.Type, Schema: string(schema), Input: string(input)}, nil
+}
+
+// validate asks the service whether it would store the manifest, so apply
+// fails before writing anything and validate checks exactly what apply
+// would. It returns why the service would reject it, and an error only when
+// the service could not be asked.
+func (m manifest) validate(e *env, c *client.Client, force bool) ([]string, error) {
+	if strings.TrimSpace(m.Name) == "" {
+		return []string{"name is required"}, nil
+	}
+	if m.Schema == nil {
+		return []string{"schema is required"}, nil
+	}
+	in, err := m.input()
+	if err != nil {
+		return []string{err.Error()}, nil
+	}
+	in.Force = force
+
+	result, err := c.Validate(e.ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, f := range result.Errors {
+		if len(f.Violations) == 0 {
+			problems = append(problems, f.Error)
+		}
+		for _, v := range f.Violations {
+			if v.InstancePath == "" {
+				problems = append(problems, fmt.Sprintf("%s: %s", f.Error, v.Message))
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s: %s", f.Error, v.InstancePath, v.Message))
+		}
+	}
+	return problems, nil
+}
+
+// validateAll reports every invalid manifest, not only the first one
+func validateAll(e *env, c *client.Client, manifests []manifest, force bool) error {
+	invalid := 0
+	for i, m := range manifests {
+		name := m.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		problems, err := m.validate(e, c, force)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if len(problems) > 0 {
+			invalid++
+			for _, p := range problems {
+				fmt.Fprintf(e.stderr, "%s: %s\n", name, p)
+			}
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%w: %d of %d config(s) failed validation", errInvalid, invalid, len(manifests))
+	}
+	return nil
+}
+
+// toManifest turns a stored config back into a manifest
+func toManifest(cfg client.Config) (manifest, error) {
+	m := manifest{Name: cfg.Name, Type: cfg.Type}
+	if err := json.Unmarshal([]byte(cfg.Schema), &m.Schema); err != nil {
+		return m, fmt.Errorf("%s: schema: %v", cfg.Name, err)
+	}
+	if err := json.Unmarshal([]byte(cfg.Input), &m.Input); err != nil {
+		return m, fmt.Errorf("%s: input: %v", cfg.Name, err)
+	}
+	return m, nil
+}
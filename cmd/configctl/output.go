@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"sass.com/configsvc/pkg/client"
+)
+
+// print writes v as JSON or YAML, or calls table for the table output
+func (e *env) print(v interface{}, table func(w io.Writer)) error {
+	switch e.output {
+	case "json":
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		return writeYAML(e.stdout, v)
+	default:
+		w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	}
+}
+
+// writeYAML goes through JSON so YAML keys are the ones of the API
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func printConfig(e *env, cfg *client.Config) error {
+	return e.print(cfg, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tTYPE\tVERSION\tCREATED BY\tCREATED AT")
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", cfg.Name, cfg.Type, cfg.Version, cfg.CreatedBy, formatTime(cfg.CreatedAt))
+		fmt.Fprintf(w, "\n%s\n", indentJSON(cfg.Input))
+	})
+}
+
+func printConfigs(e *env, configs []client.Config) error {
+	if configs == nil {
+		configs = []client.Config{}
+	}
+	return e.print(configs, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tTYPE\tVERSION\tUPDATED AT")
+		for _, cfg := range configs {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", cfg.Name, cfg.Type, cfg.Version, formatTime(cfg.UpdatedAt))
+		}
+	})
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func indentJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// compactJSON keeps diff values on a single table row
+func compactJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return "-"
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
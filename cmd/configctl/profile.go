@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// profile is a logged in server. Refresh tokens are single use, so the
+// file is rewritten whenever the tokens are renewed.
+type profile struct {
+	Server       string `json:"server"`
+	Username     string `json:"username"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type profileFile struct {
+	path     string
+	Profiles map[string]profile `json:"profiles"`
+}
+
+// profilePath is $CONFIGCTL_CONFIG or configctl/profiles.json in the user config dir
+func profilePath() (string, error) {
+	if path := os.Getenv("CONFIGCTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "configctl", "profiles.json"), nil
+}
+
+func loadProfiles() (*profileFile, error) {
+	path, err := profilePath()
+	if err != nil {
+		return nil, err
+	}
+	f := &profileFile{path: path, Profiles: map[string]profile{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	if f.Profiles == nil {
+		f.Profiles = map[string]profile{}
+	}
+	return f, nil
+}
+
+// save writes the file readable by the current user only, it holds tokens
+func (f *profileFile) save() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0o600)
+}
@@ -3,15 +3,24 @@ package main
 import (
 	"fmt"
 	"log"
+	"net"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"sass.com/configsvc/internal/auth"
 	"sass.com/configsvc/internal/cache"
 	"sass.com/configsvc/internal/config"
 	configdata "sass.com/configsvc/internal/config_data"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
+	"sass.com/configsvc/internal/policy"
+	schemaregistry "sass.com/configsvc/internal/schema_registry"
 	"sass.com/configsvc/internal/secrets"
+	"sass.com/configsvc/internal/webhook"
+	"sass.com/configsvc/pkg/configpb"
 )
 
 func main() {
@@ -25,21 +34,42 @@ func main() {
 	secs := secrets.LoadSecrets()
 
 	// Setup DB
-	db, err := gorm.Open(sqlite.Open("./data/config.db"), &gorm.Config{})
+	// Immediate transactions take the write lock up front, so concurrent
+	// config writes queue up instead of failing to upgrade a read lock
+	db, err := gorm.Open(sqlite.Open("./data/config.db?_txlock=immediate"), &gorm.Config{})
 	if err != nil {
 		log.Fatal("failed to connect database:", err)
 	}
 
 	// Setup Cache "Redis"
 	cache.Init()
+	configdata.SetSchemaCacheSize(cfg.SchemaCacheSize)
 
 	// Wire repo, service, handler
 	userRepo := auth.NewUserRepo(db)
-	authService := auth.NewAuthService(userRepo, cfg, secs)
+	tokenRepo := auth.NewTokenRepo(db)
+	revocations, err := auth.NewRevocationList(auth.NewRevocationRepo(db))
+	if err != nil {
+		log.Fatal("failed to load revoked tokens:", err)
+	}
+	stopPruning := revocations.StartPruning(time.Hour)
+	defer stopPruning()
+	authService := auth.NewAuthService(userRepo, tokenRepo, revocations, cfg, secs)
 	authHandler := auth.NewAuthHandler(authService)
+	userService := auth.NewUserService(userRepo, authService)
+	userHandler := auth.NewUserHandler(userService)
 	configRepo := configdata.NewConfigRepo(db)
-	configService := configdata.NewConfigService(configRepo)
+	configService := configdata.NewConfigService(configRepo, notify.New(notify.DefaultMaxWaiters))
 	configHandler := configdata.NewConfigHandler(configService)
+	schemaService := schemaregistry.NewSchemaService(schemaregistry.NewSchemaRepo(db))
+	schemaHandler := schemaregistry.NewSchemaHandler(schemaService)
+	configdata.UseSchemaRegistry(schemaService)
+	policyService := policy.NewPolicyService(policy.NewPolicyRepo(db))
+	policyHandler := policy.NewPolicyHandler(policyService)
+	webhookRepo := webhook.NewWebhookRepo(db)
+	webhookHandler := webhook.NewWebhookHandler(webhook.NewWebhookService(webhookRepo))
+	stopDispatch := webhook.NewDispatcher(webhookRepo, nil).Start(time.Second)
+	defer stopDispatch()
 
 	// Setup routes
 	r := gin.Default()
@@ -49,17 +79,98 @@ func main() {
 	r.POST("/api/v1/login", func(c *gin.Context) {
 		authHandler.Login(c.Writer, c.Request)
 	})
+	r.POST("/api/v1/token/refresh", func(c *gin.Context) {
+		authHandler.Refresh(c.Writer, c.Request)
+	})
 
 	// JWT-protected routes
 	api := r.Group("/api/v1")
-	api.Use(auth.AuthMiddleware(secs))
+	api.Use(auth.AuthMiddleware(secs, revocations))
+	{
+		api.POST("/logout", authHandler.Logout)
+		api.PUT("/me/password", userHandler.ChangeMyPassword)
+
+		// Per-config access is checked against the caller's grants
+		byName := policy.NameFromParam("name")
+		canRead := policy.RequireConfigAccess(policyService, policy.ActionRead, byName)
+		canWrite := policy.RequireConfigAccess(policyService, policy.ActionWrite, byName)
+		canPublish := policy.RequireConfigAccess(policyService, policy.ActionPublish, byName)
+		canManage := policy.RequireConfigAccess(policyService, policy.ActionManage, byName)
+		canCreate := policy.RequireConfigAccess(policyService, policy.ActionWrite, policy.NameFromBody("name"))
+
+		api.GET("/configs", policy.ScopeConfigAccess(policyService, policy.ActionRead), configHandler.ListConfigs)
+		api.GET("/events", policy.ScopeConfigAccess(policyService, policy.ActionRead), configHandler.StreamEvents)
+		// YAML and TOML bodies are turned into JSON before NameFromBody reads them
+		api.POST("/configs", configdata.NormalizeConfigBody(), canCreate, configHandler.CreateConfig)
+		api.PUT("/configs/:name", configdata.NormalizeConfigBody(), canWrite, configHandler.UpdateConfig)
+		// Dry runs of the two writes above, they store nothing
+		api.POST("/configs/validate", configdata.NormalizeConfigBody(), canCreate, configHandler.ValidateNewConfig)
+		api.POST("/configs/:name/validate", configdata.NormalizeConfigBody(), canWrite, configHandler.ValidateConfig)
+		api.PATCH("/configs/:name", canWrite, configHandler.PatchConfig)
+		api.POST("/configs/:name/rollback/:version", canPublish, configHandler.RollbackConfig)
+		api.GET("/configs/:name/latest", canRead, configHandler.GetLastVersionByName)
+		api.GET("/configs/:name/watch", canRead, configHandler.WatchConfig)
+		api.GET("/configs/:name/versions/:version", canRead, configHandler.GetConfigByNameByVersion)
+		api.GET("/configs/:name/versions", canRead, configHandler.GetConfigVersions)
+		api.GET("/configs/:name/events", canRead, configHandler.GetConfigEvents)
+		api.GET("/configs/:name/diff", canRead, configHandler.DiffVersions)
+		api.DELETE("/configs/:name", canManage, configHandler.DeleteConfig)
+		api.POST("/configs/:name/restore", canManage, configHandler.RestoreConfig)
+
+		api.GET("/schemas", schemaHandler.ListSchemas)
+		api.GET("/schemas/:id/versions", schemaHandler.ListSchemaVersions)
+		api.GET("/schemas/:id/versions/:version", schemaHandler.GetSchemaVersion)
+	}
+
+	// Admin-only routes
+	admin := api.Group("")
+	admin.Use(auth.RequireRole(models.RoleAdmin))
 	{
-		api.POST("/configs", configHandler.CreateConfig)
-		api.PUT("/configs/:name", configHandler.UpdateConfig)
-		api.POST("/configs/:name/rollback/:version", configHandler.RollbackConfig)
-		api.GET("/configs/:name/latest", configHandler.GetLastVersionByName)
-		api.GET("/configs/:name/versions/:version", configHandler.GetConfigByNameByVersion)
-		api.GET("/configs/:name/versions", configHandler.GetConfigVersions)
+		admin.POST("/users", userHandler.CreateUser)
+		admin.GET("/users", userHandler.ListUsers)
+		admin.GET("/users/:id", userHandler.GetUser)
+		admin.PUT("/users/:id/role", userHandler.ChangeRole)
+		admin.POST("/users/:id/disable", userHandler.DisableUser)
+		admin.POST("/users/:id/enable", userHandler.EnableUser)
+		admin.POST("/users/:id/reset-password", userHandler.ResetPassword)
+		admin.POST("/users/:id/revoke-tokens", authHandler.RevokeUserTokens)
+
+		admin.POST("/grants", policyHandler.CreateGrant)
+		admin.GET("/grants", policyHandler.ListGrants)
+		admin.DELETE("/grants/:id", policyHandler.DeleteGrant)
+		admin.GET("/configs/:name/access", policyHandler.WhoCanAccess)
+		admin.POST("/configs/:name/purge", configHandler.PurgeConfig)
+
+		admin.POST("/schemas/:id/versions", configdata.NormalizeConfigBody(), schemaHandler.RegisterSchema)
+
+		admin.POST("/webhooks", webhookHandler.CreateWebhook)
+		admin.GET("/webhooks", webhookHandler.ListWebhooks)
+		admin.GET("/webhooks/:id", webhookHandler.GetWebhook)
+		admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+		admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+		admin.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+		admin.GET("/webhooks/:id/deliveries/:delivery_id", webhookHandler.GetDelivery)
+		admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", webhookHandler.Redeliver)
+	}
+
+	// gRPC runs next to the REST API with the same auth and grants
+	if cfg.GRPCPort != 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			log.Fatal("failed to listen for grpc:", err)
+		}
+		grpcServer := grpc.NewServer(
+			grpc.UnaryInterceptor(auth.UnaryAuthInterceptor(secs, revocations)),
+			grpc.StreamInterceptor(auth.StreamAuthInterceptor(secs, revocations)),
+		)
+		configpb.RegisterConfigServiceServer(grpcServer, configdata.NewConfigGRPCServer(configService, policyService))
+		go func() {
+			log.Printf("grpc server running on %s", lis.Addr())
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Println("grpc server stopped:", err)
+			}
+		}()
+		defer grpcServer.Stop()
 	}
 
 	// Run server using port from config
@@ -5,10 +5,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type mockAuthService struct {
-	loginCalled bool
+	loginCalled  bool
+	logoutCalled bool
+	revokedUser  uuid.UUID
 }
 
 func (m *mockAuthService) Login(username, password string) (string, string, error) {
@@ -19,7 +25,23 @@ func (m *mockAuthService) Login(username, password string) (string, string, erro
 	return "", "", http.ErrNoCookie // just return error
 }
 
-func (m *mockAuthService) Logout(userID string) error {
+func (m *mockAuthService) Refresh(refreshToken string) (string, string, error) {
+	if refreshToken == "valid-refresh" {
+		return "access456", "refresh456", nil
+	}
+	return "", "", ErrInvalidRefreshToken
+}
+
+func (m *mockAuthService) Logout(userID, jti string, expiresAt time.Time, refreshToken string) error {
+	m.logoutCalled = true
+	if refreshToken == "foreign-refresh" {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+func (m *mockAuthService) RevokeAllForUser(clientID string, userID uuid.UUID) error {
+	m.revokedUser = userID
 	return nil
 }
 
@@ -52,3 +74,129 @@ func TestAuthHandler_Login_Invalid(t *testing.T) {
 		t.Fatalf("expected 401, got %d", w.Result().StatusCode)
 	}
 }
+
+func TestAuthHandler_Refresh_Success(t *testing.T) {
+	svc := &mockAuthService{}
+	h := NewAuthHandler(svc)
+
+	body := bytes.NewBufferString(`{"refresh_token":"valid-refresh"}`)
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", body)
+	w := httptest.NewRecorder()
+
+	h.Refresh(w, req)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestAuthHandler_Refresh_Invalid(t *testing.T) {
+	svc := &mockAuthService{}
+	h := NewAuthHandler(svc)
+
+	body := bytes.NewBufferString(`{"refresh_token":"stolen"}`)
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", body)
+	w := httptest.NewRecorder()
+
+	h.Refresh(w, req)
+
+	if w.Result().StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestAuthHandler_Refresh_MissingToken(t *testing.T) {
+	svc := &mockAuthService{}
+	h := NewAuthHandler(svc)
+
+	body := bytes.NewBufferString(`{}`)
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", body)
+	w := httptest.NewRecorder()
+
+	h.Refresh(w, req)
+
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestAuthHandler_Logout_Success(t *testing.T) {
+	svc := &mockAuthService{}
+	h := NewAuthHandler(svc)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/logout", func(c *gin.Context) {
+		c.Set("user_id", "tester")
+		c.Set("jti", "jti-1")
+		h.Logout(c)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if !svc.logoutCalled {
+		t.Fatal("expected service logout to be called")
+	}
+}
+
+func TestAuthHandler_Logout_ForeignRefreshToken(t *testing.T) {
+	svc := &mockAuthService{}
+	h := NewAuthHandler(svc)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/logout", func(c *gin.Context) {
+		c.Set("user_id", "tester")
+		c.Set("jti", "jti-1")
+		h.Logout(c)
+	})
+
+	body := bytes.NewBufferString(`{"refresh_token":"foreign-refresh"}`)
+	req := httptest.NewRequest(http.MethodPost, "/logout", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_RevokeUserTokens(t *testing.T) {
+	svc := &mockAuthService{}
+	h := NewAuthHandler(svc)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/users/:id/revoke-tokens", h.RevokeUserTokens)
+
+	id := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/users/"+id.String()+"/revoke-tokens", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if svc.revokedUser != id {
+		t.Fatalf("expected user %s to be revoked, got %s", id, svc.revokedUser)
+	}
+}
+
+func TestAuthHandler_RevokeUserTokens_InvalidID(t *testing.T) {
+	svc := &mockAuthService{}
+	h := NewAuthHandler(svc)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/users/:id/revoke-tokens", h.RevokeUserTokens)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/not-a-uuid/revoke-tokens", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
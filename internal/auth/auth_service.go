@@ -2,37 +2,53 @@ package auth
 
 import (
 	"errors"
+	"time"
 
+	"github.com/google/uuid"
 	"sass.com/configsvc/internal/config"
+	"sass.com/configsvc/internal/models"
 	"sass.com/configsvc/internal/secrets"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
 type AuthService interface {
 	Login(username, password string) (string, string, error)
+	Refresh(refreshToken string) (string, string, error)
+	Logout(userID, jti string, expiresAt time.Time, refreshToken string) error
+	RevokeAllForUser(clientID string, userID uuid.UUID) error
 }
 
 type AuthServiceImpl struct {
-	userRepo UserRepository
-	cfg      *config.Config
-	secrets  *secrets.Secrets
+	userRepo    UserRepository
+	tokenRepo   TokenRepository
+	revocations *RevocationList
+	cfg         *config.Config
+	secrets     *secrets.Secrets
 }
 
-func NewAuthService(userRepo UserRepository, cfg *config.Config, secrets *secrets.Secrets) AuthService {
+func NewAuthService(userRepo UserRepository, tokenRepo TokenRepository, revocations *RevocationList, cfg *config.Config, secrets *secrets.Secrets) AuthService {
 	return &AuthServiceImpl{
-		userRepo: userRepo,
-		cfg:      cfg,
-		secrets:  secrets,
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		revocations: revocations,
+		cfg:         cfg,
+		secrets:     secrets,
 	}
 }
 
 // Login verifies user and returns access + refresh tokens
 func (s *AuthServiceImpl) Login(username, password string) (string, string, error) {
 	u, err := s.userRepo.FindByUsername(username)
-	if err != nil || u == nil {
-		return "", "", errors.New("invalid credentials")
+	if err != nil || u == nil || u.IsActive == 0 {
+		return "", "", ErrInvalidCredentials
 	}
 	if !s.userRepo.VerifyPassword(u, password) {
-		return "", "", errors.New("invalid credentials")
+		return "", "", ErrInvalidCredentials
 	}
 
 	access, err := createAccessToken(*u, s.cfg, s.secrets)
@@ -40,10 +56,123 @@ func (s *AuthServiceImpl) Login(username, password string) (string, string, erro
 		return "", "", err
 	}
 
-	// To simplify, we don't store refresh token and its expiration time to Sqlite
-	refresh, err := createRefreshToken()
+	// Every login starts a new refresh token family
+	refresh, err := s.issueRefreshToken(u.ID, uuid.New())
 	if err != nil {
 		return "", "", err
 	}
 	return access, refresh, nil
 }
+
+// Refresh redeems a refresh token once and returns a new access + refresh token pair.
+// Presenting an already redeemed token revokes every token of its family.
+func (s *AuthServiceImpl) Refresh(refreshToken string) (string, string, error) {
+	rt, err := s.tokenRepo.FindByHash(hashRefreshToken(refreshToken))
+	if err != nil {
+		return "", "", err
+	}
+	if rt == nil || rt.RevokedAt != nil {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	if rt.UsedAt != nil {
+		return "", "", s.revokeFamily(rt.FamilyID, now)
+	}
+	if now.After(rt.ExpiresAt) {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	// Another request may have redeemed the same token in the meantime
+	ok, err := s.tokenRepo.MarkUsed(rt.ID, now)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		return "", "", s.revokeFamily(rt.FamilyID, now)
+	}
+
+	u, err := s.userRepo.FindByID(rt.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	if u == nil || u.IsActive == 0 {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	access, err := createAccessToken(*u, s.cfg, s.secrets)
+	if err != nil {
+		return "", "", err
+	}
+	refresh, err := s.issueRefreshToken(u.ID, rt.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// Logout revokes the presented access token and, when given, the refresh token family
+func (s *AuthServiceImpl) Logout(userID, jti string, expiresAt time.Time, refreshToken string) error {
+	if jti != "" {
+		if err := s.revocations.RevokeToken(jti, userID, expiresAt); err != nil {
+			return err
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	rt, err := s.tokenRepo.FindByHash(hashRefreshToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	// Never let a user revoke somebody else's session
+	if rt == nil || rt.UserID.String() != userID {
+		return ErrInvalidRefreshToken
+	}
+	return s.tokenRepo.RevokeFamily(rt.FamilyID, time.Now())
+}
+
+// RevokeAllForUser invalidates every access and refresh token issued to a user of the tenant so far
+func (s *AuthServiceImpl) RevokeAllForUser(clientID string, userID uuid.UUID) error {
+	u, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if u == nil || u.ClientID != clientID {
+		return ErrUserNotFound
+	}
+
+	now := time.Now()
+	accessTTL := time.Duration(s.cfg.AccessTokenTTLInDays) * 24 * time.Hour
+	if err := s.revocations.RevokeUser(userID.String(), now, now.Add(accessTTL)); err != nil {
+		return err
+	}
+	return s.tokenRepo.RevokeAllForUser(userID, now)
+}
+
+func (s *AuthServiceImpl) issueRefreshToken(userID, familyID uuid.UUID) (string, error) {
+	refresh, err := createRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	rt := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(refresh),
+		ExpiresAt: time.Now().Add(time.Duration(s.cfg.RefreshTokenTTLInMinutes) * time.Minute),
+	}
+	if err := s.tokenRepo.Create(rt); err != nil {
+		return "", err
+	}
+	return refresh, nil
+}
+
+func (s *AuthServiceImpl) revokeFamily(familyID uuid.UUID, at time.Time) error {
+	if err := s.tokenRepo.RevokeFamily(familyID, at); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"sass.com/configsvc/internal/config"
@@ -17,17 +19,100 @@ func (m *mockUserRepo) FindByUsername(username string) (*models.User, error) {
 	return m.user, nil
 }
 
+func (m *mockUserRepo) FindByID(id uuid.UUID) (*models.User, error) {
+	if m.user != nil && m.user.ID == id {
+		return m.user, nil
+	}
+	return nil, nil
+}
+
 func (m *mockUserRepo) VerifyPassword(u *models.User, password string) bool {
 	return password == "correct-password"
 }
 
+func (m *mockUserRepo) Create(u *models.User, password string) error {
+	return nil
+}
+
+func (m *mockUserRepo) List(clientID string) ([]models.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserRepo) UpdateRole(id uuid.UUID, role models.Role) error {
+	return nil
+}
+
+func (m *mockUserRepo) SetActive(id uuid.UUID, active bool) error {
+	return nil
+}
+
+func (m *mockUserRepo) ResetPassword(id uuid.UUID, password string) error {
+	return nil
+}
+
+type mockTokenRepo struct {
+	tokens map[string]*models.RefreshToken
+}
+
+func newMockTokenRepo() *mockTokenRepo {
+	return &mockTokenRepo{tokens: map[string]*models.RefreshToken{}}
+}
+
+func (m *mockTokenRepo) Create(rt *models.RefreshToken) error {
+	m.tokens[rt.TokenHash] = rt
+	return nil
+}
+
+func (m *mockTokenRepo) FindByHash(hash string) (*models.RefreshToken, error) {
+	return m.tokens[hash], nil
+}
+
+func (m *mockTokenRepo) MarkUsed(id uuid.UUID, at time.Time) (bool, error) {
+	for _, rt := range m.tokens {
+		if rt.ID == id {
+			if rt.UsedAt != nil {
+				return false, nil
+			}
+			rt.UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockTokenRepo) RevokeAllForUser(userID uuid.UUID, at time.Time) error {
+	for _, rt := range m.tokens {
+		if rt.UserID == userID && rt.RevokedAt == nil {
+			rt.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func newTestRevocationList(t *testing.T) *RevocationList {
+	l, err := NewRevocationList(NewRevocationRepo(setupTestDB(t)))
+	if err != nil {
+		t.Fatalf("failed to create revocation list: %v", err)
+	}
+	return l
+}
+
+func (m *mockTokenRepo) RevokeFamily(familyID uuid.UUID, at time.Time) error {
+	for _, rt := range m.tokens {
+		if rt.FamilyID == familyID && rt.RevokedAt == nil {
+			rt.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
 func TestAuthService_Login_Success(t *testing.T) {
-	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser}
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
 
 	fakeCfg := &config.Config{AccessTokenTTLInDays: 1}
 	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
 
-	svc := NewAuthService(&mockUserRepo{user: user}, fakeCfg, fakeSecrets)
+	svc := NewAuthService(&mockUserRepo{user: user}, newMockTokenRepo(), newTestRevocationList(t), fakeCfg, fakeSecrets)
 
 	access, refresh, err := svc.Login("elon", "correct-password")
 	if err != nil {
@@ -39,12 +124,12 @@ func TestAuthService_Login_Success(t *testing.T) {
 }
 
 func TestAuthService_Login_InvalidPassword(t *testing.T) {
-	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser}
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
 
 	fakeCfg := &config.Config{AccessTokenTTLInDays: 1}
 	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
 
-	svc := NewAuthService(&mockUserRepo{user: user}, fakeCfg, fakeSecrets)
+	svc := NewAuthService(&mockUserRepo{user: user}, newMockTokenRepo(), newTestRevocationList(t), fakeCfg, fakeSecrets)
 
 	_, _, err := svc.Login("elon", "wrong-password")
 	if err == nil {
@@ -56,10 +141,231 @@ func TestAuthService_Login_UserNotFound(t *testing.T) {
 	fakeCfg := &config.Config{AccessTokenTTLInDays: 1}
 	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
 
-	svc := NewAuthService(&mockUserRepo{user: nil}, fakeCfg, fakeSecrets)
+	svc := NewAuthService(&mockUserRepo{user: nil}, newMockTokenRepo(), newTestRevocationList(t), fakeCfg, fakeSecrets)
 
 	_, _, err := svc.Login("missing", "correct-password")
 	if err == nil || err.Error() != "invalid credentials" {
 		t.Fatalf("expected 'invalid credentials' error, got %v", err)
 	}
 }
+
+func TestAuthService_Login_StoresHashedRefreshToken(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	tokenRepo := newMockTokenRepo()
+
+	svc := NewAuthService(&mockUserRepo{user: user}, tokenRepo, newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	_, refresh, err := svc.Login("elon", "correct-password")
+	if err != nil {
+		t.Fatalf("expected success, got err: %v", err)
+	}
+	if _, ok := tokenRepo.tokens[refresh]; ok {
+		t.Fatal("refresh token must not be stored in plain text")
+	}
+	rt, ok := tokenRepo.tokens[hashRefreshToken(refresh)]
+	if !ok {
+		t.Fatal("expected refresh token to be stored")
+	}
+	if rt.UserID != user.ID {
+		t.Errorf("expected user id %s, got %s", user.ID, rt.UserID)
+	}
+	if ttl := time.Until(rt.ExpiresAt); ttl <= 59*time.Minute || ttl > 60*time.Minute {
+		t.Errorf("expected expiry derived from RefreshTokenTTLInMinutes, got %s", ttl)
+	}
+}
+
+func TestAuthService_Refresh_RotatesToken(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	tokenRepo := newMockTokenRepo()
+
+	svc := NewAuthService(&mockUserRepo{user: user}, tokenRepo, newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	_, refresh, err := svc.Login("elon", "correct-password")
+	if err != nil {
+		t.Fatalf("expected success, got err: %v", err)
+	}
+
+	access, rotated, err := svc.Refresh(refresh)
+	if err != nil {
+		t.Fatalf("expected refresh to succeed, got err: %v", err)
+	}
+	if access == "" || rotated == "" || rotated == refresh {
+		t.Fatal("expected new access token and rotated refresh token")
+	}
+
+	oldRT := tokenRepo.tokens[hashRefreshToken(refresh)]
+	newRT := tokenRepo.tokens[hashRefreshToken(rotated)]
+	if oldRT.UsedAt == nil {
+		t.Error("expected old refresh token to be marked used")
+	}
+	if newRT.FamilyID != oldRT.FamilyID {
+		t.Error("expected rotated token to stay in the same family")
+	}
+}
+
+func TestAuthService_Refresh_ReuseRevokesFamily(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	tokenRepo := newMockTokenRepo()
+
+	svc := NewAuthService(&mockUserRepo{user: user}, tokenRepo, newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	_, refresh, _ := svc.Login("elon", "correct-password")
+	_, rotated, err := svc.Refresh(refresh)
+	if err != nil {
+		t.Fatalf("expected refresh to succeed, got err: %v", err)
+	}
+
+	// Replaying the first token must be detected
+	if _, _, err := svc.Refresh(refresh); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// The legitimately rotated token is revoked as well
+	if _, _, err := svc.Refresh(rotated); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestAuthService_Refresh_Expired(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	tokenRepo := newMockTokenRepo()
+
+	svc := NewAuthService(&mockUserRepo{user: user}, tokenRepo, newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	_, refresh, _ := svc.Login("elon", "correct-password")
+	tokenRepo.tokens[hashRefreshToken(refresh)].ExpiresAt = time.Now().Add(-time.Minute)
+
+	if _, _, err := svc.Refresh(refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestAuthService_Refresh_UnknownToken(t *testing.T) {
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+
+	svc := NewAuthService(&mockUserRepo{user: nil}, newMockTokenRepo(), newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	if _, _, err := svc.Refresh("does-not-exist"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestAuthService_Logout_RevokesAccessAndRefreshToken(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	tokenRepo := newMockTokenRepo()
+	revocations := newTestRevocationList(t)
+
+	svc := NewAuthService(&mockUserRepo{user: user}, tokenRepo, revocations, fakeCfg, fakeSecrets)
+
+	_, refresh, _ := svc.Login("elon", "correct-password")
+	if err := svc.Logout(user.ID.String(), "jti-1", time.Now().Add(time.Hour), refresh); err != nil {
+		t.Fatalf("expected logout to succeed, got err: %v", err)
+	}
+
+	if !revocations.IsRevoked("jti-1", user.ID.String(), time.Now()) {
+		t.Error("expected access token to be revoked")
+	}
+	if _, _, err := svc.Refresh(refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected refresh token to be revoked, got %v", err)
+	}
+}
+
+func TestAuthService_Logout_ForeignRefreshToken(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+
+	svc := NewAuthService(&mockUserRepo{user: user}, newMockTokenRepo(), newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	_, refresh, _ := svc.Login("elon", "correct-password")
+	err := svc.Logout(uuid.NewString(), "jti-1", time.Now().Add(time.Hour), refresh)
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestAuthService_RevokeAllForUser(t *testing.T) {
+	user := &models.User{ID: uuid.New(), ClientID: "acme", Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	revocations := newTestRevocationList(t)
+
+	svc := NewAuthService(&mockUserRepo{user: user}, newMockTokenRepo(), revocations, fakeCfg, fakeSecrets)
+
+	_, refresh, _ := svc.Login("elon", "correct-password")
+	if err := svc.RevokeAllForUser("acme", user.ID); err != nil {
+		t.Fatalf("expected revoke to succeed, got err: %v", err)
+	}
+
+	if !revocations.IsRevoked("", user.ID.String(), time.Now().Add(-time.Second)) {
+		t.Error("expected existing access tokens to be revoked")
+	}
+	if _, _, err := svc.Refresh(refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected refresh token to be revoked, got %v", err)
+	}
+}
+
+func TestAuthService_RevokeAllForUser_OtherTenant(t *testing.T) {
+	user := &models.User{ID: uuid.New(), ClientID: "acme", Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	revocations := newTestRevocationList(t)
+
+	svc := NewAuthService(&mockUserRepo{user: user}, newMockTokenRepo(), revocations, fakeCfg, fakeSecrets)
+
+	if err := svc.RevokeAllForUser("globex", user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if revocations.IsRevoked("", user.ID.String(), time.Now().Add(-time.Second)) {
+		t.Error("expected tokens of another tenant's user to stay valid")
+	}
+}
+
+func TestAuthService_Login_InactiveUser(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 0}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+
+	svc := NewAuthService(&mockUserRepo{user: user}, newMockTokenRepo(), newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	_, _, err := svc.Login("elon", "correct-password")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for inactive user, got %v", err)
+	}
+}
+
+func TestAuthService_Refresh_InactiveUser(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Username: "elon", Role: models.RoleUser, IsActive: 1}
+
+	fakeCfg := &config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}
+	fakeSecrets := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+
+	svc := NewAuthService(&mockUserRepo{user: user}, newMockTokenRepo(), newTestRevocationList(t), fakeCfg, fakeSecrets)
+
+	_, refresh, _ := svc.Login("elon", "correct-password")
+	user.IsActive = 0
+
+	if _, _, err := svc.Refresh(refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken for inactive user, got %v", err)
+	}
+}
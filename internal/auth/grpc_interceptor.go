@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sass.com/configsvc/internal/secrets"
+)
+
+// Identity is the caller of a gRPC call, what AuthMiddleware stores as
+// "user_id", "role" and "client_id" for HTTP requests
+type Identity struct {
+	UserID   string
+	Role     string
+	ClientID string
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity put in ctx by the auth interceptors
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// UnaryAuthInterceptor checks the "authorization" metadata of unary calls like
+// AuthMiddleware checks the Authorization header
+func UnaryAuthInterceptor(secs *secrets.Secrets, revocations *RevocationList) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, secs, revocations)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor is UnaryAuthInterceptor for streaming calls
+func StreamAuthInterceptor(secs *secrets.Secrets, revocations *RevocationList) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), secs, revocations)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticate(ctx context.Context, secs *secrets.Secrets, revocations *RevocationList) (context.Context, error) {
+	var authHeader string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			authHeader = v[0]
+		}
+	}
+
+	claims, err := parseBearer(secs, revocations, authHeader)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	userID, ok := claims["sub"].(string)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidClaims.Error())
+	}
+	role, ok := claims["role"].(string)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidClaims.Error())
+	}
+	return WithIdentity(ctx, Identity{UserID: userID, Role: role, ClientID: claimClientID(claims)}), nil
+}
+
+// identityStream hands the authenticated context to stream handlers
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sass.com/configsvc/internal/config"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/secrets"
+)
+
+func emptyRevocations() *RevocationList {
+	return &RevocationList{
+		tokens: map[string]time.Time{},
+		users:  map[string]models.UserRevocation{},
+	}
+}
+
+func withAuthorization(value string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", value))
+}
+
+func TestUnaryAuthInterceptor(t *testing.T) {
+	secret := []byte("testsecret")
+	secs := &secrets.Secrets{JWTsecret: secret}
+	u := models.User{ID: uuid.New(), ClientID: "acme", Role: models.RoleUser}
+	token, _ := createAccessToken(u, &config.Config{AccessTokenTTLInDays: 1}, secs)
+	interceptor := UnaryAuthInterceptor(secs, emptyRevocations())
+
+	var got Identity
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		got, _ = IdentityFromContext(ctx)
+		return "ok", nil
+	}
+
+	if _, err := interceptor(withAuthorization("Bearer "+token), nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.UserID != u.ID.String() || got.Role != string(models.RoleUser) || got.ClientID != "acme" {
+		t.Fatalf("unexpected identity: %+v", got)
+	}
+
+	for _, ctx := range []context.Context{
+		context.Background(),
+		withAuthorization(token),
+		withAuthorization("Bearer " + generateTestToken(secret, true)),
+		withAuthorization("Bearer " + generateTestToken([]byte("othersecret"), false)),
+	} {
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected Unauthenticated, got %v", err)
+		}
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamAuthInterceptor_RevokedUser(t *testing.T) {
+	secret := []byte("testsecret")
+	secs := &secrets.Secrets{JWTsecret: secret}
+	revocations, err := NewRevocationList(NewRevocationRepo(setupTestDB(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := models.User{ID: uuid.New(), Role: models.RoleUser}
+	token, _ := createAccessToken(u, &config.Config{AccessTokenTTLInDays: 1}, secs)
+	interceptor := StreamAuthInterceptor(secs, revocations)
+
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		if _, ok := IdentityFromContext(ss.Context()); !ok {
+			t.Fatal("expected the stream context to carry the identity")
+		}
+		return nil
+	}
+	ss := &testServerStream{ctx: withAuthorization("Bearer " + token)}
+	if err := interceptor(nil, ss, &grpc.StreamServerInfo{}, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := revocations.RevokeUser(u.ID.String(), time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to revoke user: %v", err)
+	}
+	err = interceptor(nil, ss, &grpc.StreamServerInfo{}, handler)
+	if status.Code(err) != codes.Unauthenticated || status.Convert(err).Message() != ErrTokenRevoked.Error() {
+		t.Fatalf("expected token revoked, got %v", err)
+	}
+}
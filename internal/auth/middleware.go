@@ -1,50 +1,115 @@
 package auth
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"sass.com/configsvc/internal/models"
 	"sass.com/configsvc/internal/secrets"
 )
 
-func AuthMiddleware(secs *secrets.Secrets) gin.HandlerFunc {
+var (
+	ErrMissingToken       = errors.New("missing token")
+	ErrInvalidTokenFormat = errors.New("invalid token format")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidClaims      = errors.New("invalid claims")
+	ErrTokenRevoked       = errors.New("token revoked")
+)
+
+func AuthMiddleware(secs *secrets.Secrets, revocations *RevocationList) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+		claims, err := parseBearer(secs, revocations, c.GetHeader("Authorization"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token format"})
-			return
+		clientID := claimClientID(claims)
+		jti, _ := claims["jti"].(string)
+
+		// Put user info in context
+		c.Set("user_id", claims["sub"])
+		c.Set("role", claims["role"])
+		c.Set("client_id", clientID)
+		c.Set("jti", jti)
+		c.Set("token_exp", claimTime(claims, "exp"))
+		c.Next()
+	}
+}
+
+// parseBearer validates an "Authorization: Bearer <token>" value and returns
+// the token's claims. It is shared by the HTTP middleware and gRPC interceptors.
+func parseBearer(secs *secrets.Secrets, revocations *RevocationList, authHeader string) (jwt.MapClaims, error) {
+	if authHeader == "" {
+		return nil, ErrMissingToken
+	}
+
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, ErrInvalidTokenFormat
+	}
+
+	tokenStr := parts[1]
+
+	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
 		}
+		return secs.JWTsecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
 
-		tokenStr := parts[1]
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidClaims
+	}
+
+	// Reject logged out tokens and tokens of users whose sessions were revoked
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims["sub"].(string)
+	if revocations.IsRevoked(jti, sub, claimTime(claims, "iat")) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
 
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return secs.JWTsecret, nil
-		})
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+// RequireRole only lets users with the given role through. It must run after AuthMiddleware.
+func RequireRole(role models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roleVal, exists := c.Get("role")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "role not found"})
 			return
 		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
+		if r, ok := roleVal.(string); !ok || r != string(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "you are not authorized"})
 			return
 		}
-
-		// Put user info in context
-		c.Set("user_id", claims["sub"])
-		c.Set("role", claims["role"])
 		c.Next()
 	}
 }
+
+// claimClientID is the tenant of a token. Tokens issued before tenancy carry
+// none, their users were moved to the default tenant along with their data.
+func claimClientID(claims jwt.MapClaims) string {
+	clientID, _ := claims["client_id"].(string)
+	if clientID == "" {
+		return models.DefaultClientID
+	}
+	return clientID
+}
+
+func claimTime(claims jwt.MapClaims, key string) time.Time {
+	v, ok := claims[key].(float64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(math.Round(v * 1e3)))
+}
@@ -9,16 +9,24 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"sass.com/configsvc/internal/config"
 	"sass.com/configsvc/internal/models"
 	"sass.com/configsvc/internal/secrets"
 )
 
 func setupTestRouterWithMW(secret []byte) *gin.Engine {
+	return setupTestRouterWithRevocations(secret, &RevocationList{
+		tokens: map[string]time.Time{},
+		users:  map[string]models.UserRevocation{},
+	})
+}
+
+func setupTestRouterWithRevocations(secret []byte, revocations *RevocationList) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 
 	secs := &secrets.Secrets{JWTsecret: secret}
-	r.Use(AuthMiddleware(secs))
+	r.Use(AuthMiddleware(secs, revocations))
 
 	// Dummy handler
 	r.GET("/protected", func(c *gin.Context) {
@@ -103,3 +111,151 @@ func TestAuthMiddleware_ExpiredToken(t *testing.T) {
 		t.Fatalf("expected 401 for expired token, got %d", w.Code)
 	}
 }
+
+func TestAuthMiddleware_RevokedToken(t *testing.T) {
+	secret := []byte("testsecret")
+	revocations, err := NewRevocationList(NewRevocationRepo(setupTestDB(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := setupTestRouterWithRevocations(secret, revocations)
+
+	u := models.User{ID: uuid.New(), Role: models.RoleUser}
+	token, _ := createAccessToken(u, &config.Config{AccessTokenTTLInDays: 1}, &secrets.Secrets{JWTsecret: secret})
+	parsed, _, _ := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	jti := parsed.Claims.(jwt.MapClaims)["jti"].(string)
+
+	if err := revocations.RevokeToken(jti, u.ID.String(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to revoke token: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for revoked token, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddleware_RevokedUser(t *testing.T) {
+	secret := []byte("testsecret")
+	revocations, err := NewRevocationList(NewRevocationRepo(setupTestDB(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := setupTestRouterWithRevocations(secret, revocations)
+
+	u := models.User{ID: uuid.New(), Role: models.RoleUser}
+	token, _ := createAccessToken(u, &config.Config{AccessTokenTTLInDays: 1}, &secrets.Secrets{JWTsecret: secret})
+
+	if err := revocations.RevokeUser(u.ID.String(), time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to revoke user: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for revoked user, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddleware_LoginAfterRevokedUser(t *testing.T) {
+	secret := []byte("testsecret")
+	revocations, err := NewRevocationList(NewRevocationRepo(setupTestDB(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := setupTestRouterWithRevocations(secret, revocations)
+
+	u := models.User{ID: uuid.New(), Role: models.RoleUser}
+	if err := revocations.RevokeUser(u.ID.String(), time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to revoke user: %v", err)
+	}
+	// Logging in again, most likely within the same second
+	time.Sleep(2 * time.Millisecond)
+	token, _ := createAccessToken(u, &config.Config{AccessTokenTTLInDays: 1}, &secrets.Secrets{JWTsecret: secret})
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a token issued after the revocation, got %d", w.Code)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		role string
+		want int
+	}{
+		{role: string(models.RoleAdmin), want: http.StatusOK},
+		{role: string(models.RoleUser), want: http.StatusForbidden},
+	} {
+		r := gin.New()
+		r.GET("/admin", func(c *gin.Context) {
+			c.Set("role", tc.role)
+		}, RequireRole(models.RoleAdmin), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/admin", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("role %s: expected %d, got %d", tc.role, tc.want, w.Code)
+		}
+	}
+}
+
+func TestAuthMiddleware_SetsClientID(t *testing.T) {
+	secret := []byte("testsecret")
+	u := models.User{ID: uuid.New(), ClientID: "acme", Role: models.RoleUser}
+	token, _ := createAccessToken(u, &config.Config{AccessTokenTTLInDays: 1}, &secrets.Secrets{JWTsecret: secret})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(AuthMiddleware(&secrets.Secrets{JWTsecret: secret}, &RevocationList{
+		tokens: map[string]time.Time{},
+		users:  map[string]models.UserRevocation{},
+	}))
+	r.GET("/protected", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("client_id"))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "acme" {
+		t.Fatalf("expected client_id acme, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthMiddleware_TokenWithoutClientID(t *testing.T) {
+	secret := []byte("testsecret")
+	r := setupTestRouterWithMW(secret)
+	r.GET("/tenant", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("client_id"))
+	})
+
+	// Tokens from before tenancy belong to the tenant their users were moved to
+	req, _ := http.NewRequest(http.MethodGet, "/tenant", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken(secret, false))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != models.DefaultClientID {
+		t.Fatalf("expected client_id %s, got %d %q", models.DefaultClientID, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"sass.com/configsvc/internal/models"
+)
+
+// RevocationList keeps the access token deny-list in memory so AuthMiddleware
+// can check it without a DB hit. Every change is written through to the repo
+// and the list is reloaded from it on startup.
+type RevocationList struct {
+	repo RevocationRepository
+
+	mu     sync.RWMutex
+	tokens map[string]time.Time             // jti -> token expiry
+	users  map[string]models.UserRevocation // user id -> revocation
+}
+
+func NewRevocationList(repo RevocationRepository) (*RevocationList, error) {
+	l := &RevocationList{
+		repo:   repo,
+		tokens: map[string]time.Time{},
+		users:  map[string]models.UserRevocation{},
+	}
+
+	tokens, users, err := repo.ListActive(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		l.tokens[t.JTI] = t.ExpiresAt
+	}
+	for _, u := range users {
+		l.users[u.UserID] = u
+	}
+	return l, nil
+}
+
+// RevokeToken deny-lists a single access token until it expires
+func (l *RevocationList) RevokeToken(jti, userID string, expiresAt time.Time) error {
+	if err := l.repo.SaveToken(&models.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.tokens[jti] = expiresAt
+	l.mu.Unlock()
+	return nil
+}
+
+// RevokeUser rejects every token of the user issued at or before revokedAt,
+// to the millisecond.
+// expiresAt is when the last of those tokens would have expired anyway.
+func (l *RevocationList) RevokeUser(userID string, revokedAt, expiresAt time.Time) error {
+	ur := models.UserRevocation{UserID: userID, RevokedAt: revokedAt, ExpiresAt: expiresAt}
+	if err := l.repo.SaveUser(&ur); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.users[userID] = ur
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *RevocationList) IsRevoked(jti, userID string, issuedAt time.Time) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.tokens[jti]; ok && jti != "" {
+		return true
+	}
+	if ur, ok := l.users[userID]; ok && issuedAt.UnixMilli() <= ur.RevokedAt.UnixMilli() {
+		return true
+	}
+	return false
+}
+
+// Prune drops entries whose tokens have expired on their own
+func (l *RevocationList) Prune(now time.Time) error {
+	l.mu.Lock()
+	for jti, exp := range l.tokens {
+		if !exp.After(now) {
+			delete(l.tokens, jti)
+		}
+	}
+	for userID, ur := range l.users {
+		if !ur.ExpiresAt.After(now) {
+			delete(l.users, userID)
+		}
+	}
+	l.mu.Unlock()
+
+	return l.repo.DeleteExpired(now)
+}
+
+// StartPruning runs Prune every interval until the returned stop func is called
+func (l *RevocationList) StartPruning(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := l.Prune(now); err != nil {
+					log.Println("failed to prune revoked tokens:", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sass.com/configsvc/internal/models"
+)
+
+type RevocationRepo struct {
+	db *gorm.DB
+}
+
+func NewRevocationRepo(db *gorm.DB) *RevocationRepo {
+	return &RevocationRepo{db: db}
+}
+
+type RevocationRepository interface {
+	SaveToken(rt *models.RevokedToken) error
+	SaveUser(ur *models.UserRevocation) error
+	ListActive(now time.Time) ([]models.RevokedToken, []models.UserRevocation, error)
+	DeleteExpired(now time.Time) error
+}
+
+func (r *RevocationRepo) SaveToken(rt *models.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(rt).Error
+}
+
+func (r *RevocationRepo) SaveUser(ur *models.UserRevocation) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at", "expires_at"}),
+	}).Create(ur).Error
+}
+
+func (r *RevocationRepo) ListActive(now time.Time) ([]models.RevokedToken, []models.UserRevocation, error) {
+	var tokens []models.RevokedToken
+	if err := r.db.Where("expires_at > ?", now).Find(&tokens).Error; err != nil {
+		return nil, nil, err
+	}
+	var users []models.UserRevocation
+	if err := r.db.Where("expires_at > ?", now).Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+	return tokens, users, nil
+}
+
+func (r *RevocationRepo) DeleteExpired(now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at <= ?", now).Delete(&models.UserRevocation{}).Error
+	})
+}
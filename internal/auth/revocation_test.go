@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevocationList_ReloadsFromRepo(t *testing.T) {
+	repo := NewRevocationRepo(setupTestDB(t))
+
+	first, err := NewRevocationList(repo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := first.RevokeToken("jti-1", "user-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to revoke token: %v", err)
+	}
+
+	// A fresh list, e.g. after a restart, sees the persisted entry
+	second, err := NewRevocationList(repo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !second.IsRevoked("jti-1", "user-1", time.Now()) {
+		t.Fatal("expected token to stay revoked after reload")
+	}
+	if second.IsRevoked("jti-2", "user-1", time.Now()) {
+		t.Fatal("expected other token to be valid")
+	}
+}
+
+func TestRevocationList_RevokeUserOnlyAffectsOlderTokens(t *testing.T) {
+	l, err := NewRevocationList(NewRevocationRepo(setupTestDB(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	revokedAt := time.Now()
+	if err := l.RevokeUser("user-1", revokedAt, revokedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to revoke user: %v", err)
+	}
+
+	if !l.IsRevoked("", "user-1", revokedAt.Add(-time.Minute)) {
+		t.Error("expected token issued before revocation to be rejected")
+	}
+	if l.IsRevoked("", "user-1", revokedAt.Add(time.Minute)) {
+		t.Error("expected token issued after revocation to be accepted")
+	}
+	if l.IsRevoked("", "user-2", revokedAt.Add(-time.Minute)) {
+		t.Error("expected other users to be unaffected")
+	}
+}
+
+func TestRevocationList_RevokeUserSameSecond(t *testing.T) {
+	l, err := NewRevocationList(NewRevocationRepo(setupTestDB(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	revokedAt := time.Now().Truncate(time.Second).Add(200 * time.Millisecond)
+	if err := l.RevokeUser("user-1", revokedAt, revokedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to revoke user: %v", err)
+	}
+
+	if !l.IsRevoked("", "user-1", revokedAt.Add(-100*time.Millisecond)) {
+		t.Error("expected token issued earlier in the second to be rejected")
+	}
+	if l.IsRevoked("", "user-1", revokedAt.Add(100*time.Millisecond)) {
+		t.Error("expected token issued later in the second to be accepted")
+	}
+}
+
+func TestRevocationList_Prune(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRevocationRepo(db)
+	l, err := NewRevocationList(repo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	_ = l.RevokeToken("expired", "user-1", now.Add(-time.Minute))
+	_ = l.RevokeToken("active", "user-1", now.Add(time.Hour))
+	_ = l.RevokeUser("user-2", now.Add(-time.Hour), now.Add(-time.Minute))
+
+	if err := l.Prune(now); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+
+	if len(l.tokens) != 1 || len(l.users) != 0 {
+		t.Fatalf("expected only the active token in memory, got %v %v", l.tokens, l.users)
+	}
+	tokens, users, err := repo.ListActive(time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].JTI != "active" || len(users) != 0 {
+		t.Fatalf("expected expired rows to be deleted, got %+v %+v", tokens, users)
+	}
+}
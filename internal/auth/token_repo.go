@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/models"
+)
+
+type TokenRepo struct {
+	db *gorm.DB
+}
+
+func NewTokenRepo(db *gorm.DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+type TokenRepository interface {
+	Create(rt *models.RefreshToken) error
+	FindByHash(hash string) (*models.RefreshToken, error)
+	MarkUsed(id uuid.UUID, at time.Time) (bool, error)
+	RevokeFamily(familyID uuid.UUID, at time.Time) error
+	RevokeAllForUser(userID uuid.UUID, at time.Time) error
+}
+
+func (r *TokenRepo) Create(rt *models.RefreshToken) error {
+	return r.db.Create(rt).Error
+}
+
+func (r *TokenRepo) FindByHash(hash string) (*models.RefreshToken, error) {
+	var rt models.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&rt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // no error, just not found
+		}
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// MarkUsed flags the token as redeemed. It reports false when the token was
+// already used, so two concurrent refreshes cannot both succeed.
+func (r *TokenRepo) MarkUsed(id uuid.UUID, at time.Time) (bool, error) {
+	res := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *TokenRepo) RevokeFamily(familyID uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+}
+
+func (r *TokenRepo) RevokeAllForUser(userID uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
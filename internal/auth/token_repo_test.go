@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+func TestTokenRepo_CreateAndFindByHash(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTokenRepo(db)
+
+	rt := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		TokenHash: hashRefreshToken("raw-token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := repo.Create(rt); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+
+	found, err := repo.FindByHash(hashRefreshToken("raw-token"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found == nil || found.ID != rt.ID {
+		t.Fatalf("expected token %s, got %+v", rt.ID, found)
+	}
+
+	notFound, err := repo.FindByHash(hashRefreshToken("other"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if notFound != nil {
+		t.Fatalf("expected nil, got %+v", notFound)
+	}
+}
+
+func TestTokenRepo_MarkUsedOnce(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTokenRepo(db)
+
+	rt := &models.RefreshToken{ID: uuid.New(), TokenHash: hashRefreshToken("raw-token"), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repo.Create(rt); err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+
+	ok, err := repo.MarkUsed(rt.ID, time.Now())
+	if err != nil || !ok {
+		t.Fatalf("expected first redeem to succeed, got ok=%v err=%v", ok, err)
+	}
+	ok, err = repo.MarkUsed(rt.ID, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected second redeem to be rejected")
+	}
+}
+
+func TestTokenRepo_RevokeFamily(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTokenRepo(db)
+
+	family := uuid.New()
+	first := &models.RefreshToken{ID: uuid.New(), FamilyID: family, TokenHash: hashRefreshToken("a"), ExpiresAt: time.Now().Add(time.Hour)}
+	second := &models.RefreshToken{ID: uuid.New(), FamilyID: family, TokenHash: hashRefreshToken("b"), ExpiresAt: time.Now().Add(time.Hour)}
+	other := &models.RefreshToken{ID: uuid.New(), FamilyID: uuid.New(), TokenHash: hashRefreshToken("c"), ExpiresAt: time.Now().Add(time.Hour)}
+	for _, rt := range []*models.RefreshToken{first, second, other} {
+		if err := repo.Create(rt); err != nil {
+			t.Fatalf("failed to create refresh token: %v", err)
+		}
+	}
+
+	if err := repo.RevokeFamily(family, time.Now()); err != nil {
+		t.Fatalf("failed to revoke family: %v", err)
+	}
+
+	for _, hash := range []string{hashRefreshToken("a"), hashRefreshToken("b")} {
+		rt, _ := repo.FindByHash(hash)
+		if rt.RevokedAt == nil {
+			t.Errorf("expected token %s to be revoked", rt.ID)
+		}
+	}
+	rt, _ := repo.FindByHash(hashRefreshToken("c"))
+	if rt.RevokedAt != nil {
+		t.Error("expected token of another family to stay valid")
+	}
+}
@@ -2,21 +2,28 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"sass.com/configsvc/internal/config"
 	"sass.com/configsvc/internal/models"
 	"sass.com/configsvc/internal/secrets"
 )
 
 func createAccessToken(u models.User, cfg *config.Config, secrets *secrets.Secrets) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"sub":  u.ID,
-		"role": string(u.Role),
-		"exp":  time.Now().Add(time.Duration(cfg.AccessTokenTTLInDays) * 24 * time.Hour).Unix(),
-		"iat":  time.Now().Unix(),
+		"jti":       uuid.NewString(),
+		"sub":       u.ID,
+		"role":      string(u.Role),
+		"client_id": u.ClientID,
+		"exp":       now.Add(time.Duration(cfg.AccessTokenTTLInDays) * 24 * time.Hour).Unix(),
+		// In milliseconds, so a login right after a revoke-all is not revoked too
+		"iat": float64(now.UnixMilli()) / 1e3,
 	}
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return t.SignedString(secrets.JWTsecret)
@@ -29,3 +36,9 @@ func createRefreshToken() (string, error) {
 	}
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
+
+// Only the hash of a refresh token is persisted
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -37,3 +37,16 @@ func TestCreateRefreshTokenUnique(t *testing.T) {
 		t.Fatal("refresh token is not unique")
 	}
 }
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := createRefreshToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hashRefreshToken(token) != hashRefreshToken(token) {
+		t.Fatal("expected hash to be deterministic")
+	}
+	if hashRefreshToken(token) == token {
+		t.Fatal("expected hash to differ from raw token")
+	}
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+type UserHandler struct {
+	service UserService
+}
+
+func NewUserHandler(svc UserService) *UserHandler {
+	return &UserHandler{service: svc}
+}
+
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req struct {
+		Username string      `json:"username" binding:"required"`
+		Password string      `json:"password" binding:"required"`
+		Role     models.Role `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	// New users always join the tenant of the admin creating them
+	u, err := h.service.CreateUser(c.GetString("client_id"), req.Username, req.Password, req.Role)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, u)
+}
+
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	users, err := h.service.ListUsers(c.GetString("client_id"))
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	u, err := h.service.GetUser(c.GetString("client_id"), id)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *UserHandler) ChangeRole(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role models.Role `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	// An admin demoting themselves could leave nobody able to manage users
+	if id.String() == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot change your own role"})
+		return
+	}
+
+	if err := h.service.ChangeRole(c.GetString("client_id"), id, req.Role); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) DisableUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+func (h *UserHandler) EnableUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+func (h *UserHandler) setActive(c *gin.Context, active bool) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	if !active && id.String() == c.GetString("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot disable yourself"})
+		return
+	}
+
+	if err := h.service.SetActive(c.GetString("client_id"), id, active); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := h.service.ResetPassword(c.GetString("client_id"), id, req.Password); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ChangeMyPassword lets any authenticated user change their own password
+func (h *UserHandler) ChangeMyPassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	id, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := h.service.ChangePassword(id, req.CurrentPassword, req.NewPassword); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "current password is incorrect"})
+			return
+		}
+		writeUserError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func userIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func writeUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidRole), errors.Is(err, ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Println("user service error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+type mockUserService struct {
+	createErr   error
+	user        *models.User
+	userErr     error
+	users       []models.User
+	roleErr     error
+	activeErr   error
+	resetErr    error
+	changeErr   error
+	activeState *bool
+}
+
+func (m *mockUserService) CreateUser(clientID, username, password string, role models.Role) (*models.User, error) {
+	if m.createErr != nil {
+		return nil, m.createErr
+	}
+	return &models.User{ID: uuid.New(), ClientID: clientID, Username: username, Role: role, IsActive: 1}, nil
+}
+func (m *mockUserService) ListUsers(clientID string) ([]models.User, error) {
+	return m.users, nil
+}
+func (m *mockUserService) GetUser(clientID string, id uuid.UUID) (*models.User, error) {
+	return m.user, m.userErr
+}
+func (m *mockUserService) ChangeRole(clientID string, id uuid.UUID, role models.Role) error {
+	return m.roleErr
+}
+func (m *mockUserService) SetActive(clientID string, id uuid.UUID, active bool) error {
+	m.activeState = &active
+	return m.activeErr
+}
+func (m *mockUserService) ResetPassword(clientID string, id uuid.UUID, password string) error {
+	return m.resetErr
+}
+func (m *mockUserService) ChangePassword(id uuid.UUID, currentPassword, newPassword string) error {
+	return m.changeErr
+}
+
+func setupUserRouter(h *UserHandler, userID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("role", string(models.RoleAdmin))
+		c.Set("client_id", "acme")
+	})
+	r.POST("/users", h.CreateUser)
+	r.GET("/users", h.ListUsers)
+	r.GET("/users/:id", h.GetUser)
+	r.PUT("/users/:id/role", h.ChangeRole)
+	r.POST("/users/:id/disable", h.DisableUser)
+	r.POST("/users/:id/reset-password", h.ResetPassword)
+	r.PUT("/me/password", h.ChangeMyPassword)
+	return r
+}
+
+func TestUserHandler_CreateUser_Success(t *testing.T) {
+	h := NewUserHandler(&mockUserService{})
+	r := setupUserRouter(h, uuid.NewString())
+
+	body := bytes.NewBufferString(`{"username":"alice","password":"secret-password","role":"user"}`)
+	req := httptest.NewRequest(http.MethodPost, "/users", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("PasswordHash")) {
+		t.Fatal("password hash must not be returned")
+	}
+}
+
+func TestUserHandler_CreateUser_Duplicate(t *testing.T) {
+	h := NewUserHandler(&mockUserService{createErr: ErrUsernameTaken})
+	r := setupUserRouter(h, uuid.NewString())
+
+	body := bytes.NewBufferString(`{"username":"alice","password":"secret-password"}`)
+	req := httptest.NewRequest(http.MethodPost, "/users", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_CreateUser_InvalidBody(t *testing.T) {
+	h := NewUserHandler(&mockUserService{})
+	r := setupUserRouter(h, uuid.NewString())
+
+	body := bytes.NewBufferString(`{"username":"alice"}`)
+	req := httptest.NewRequest(http.MethodPost, "/users", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_GetUser_NotFound(t *testing.T) {
+	h := NewUserHandler(&mockUserService{userErr: ErrUserNotFound})
+	r := setupUserRouter(h, uuid.NewString())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/"+uuid.NewString(), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_ChangeRole_Self(t *testing.T) {
+	h := NewUserHandler(&mockUserService{})
+	self := uuid.NewString()
+	r := setupUserRouter(h, self)
+
+	body := bytes.NewBufferString(`{"role":"user"}`)
+	req := httptest.NewRequest(http.MethodPut, "/users/"+self+"/role", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_ChangeRole_InvalidRole(t *testing.T) {
+	h := NewUserHandler(&mockUserService{roleErr: ErrInvalidRole})
+	r := setupUserRouter(h, uuid.NewString())
+
+	body := bytes.NewBufferString(`{"role":"root"}`)
+	req := httptest.NewRequest(http.MethodPut, "/users/"+uuid.NewString()+"/role", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_DisableUser(t *testing.T) {
+	svc := &mockUserService{}
+	h := NewUserHandler(svc)
+	r := setupUserRouter(h, uuid.NewString())
+
+	req := httptest.NewRequest(http.MethodPost, "/users/"+uuid.NewString()+"/disable", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if svc.activeState == nil || *svc.activeState {
+		t.Fatal("expected user to be disabled")
+	}
+}
+
+func TestUserHandler_DisableUser_Self(t *testing.T) {
+	h := NewUserHandler(&mockUserService{})
+	self := uuid.NewString()
+	r := setupUserRouter(h, self)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/"+self+"/disable", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_ResetPassword_Weak(t *testing.T) {
+	h := NewUserHandler(&mockUserService{resetErr: ErrWeakPassword})
+	r := setupUserRouter(h, uuid.NewString())
+
+	body := bytes.NewBufferString(`{"password":"short"}`)
+	req := httptest.NewRequest(http.MethodPost, "/users/"+uuid.NewString()+"/reset-password", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_ChangeMyPassword_WrongCurrent(t *testing.T) {
+	h := NewUserHandler(&mockUserService{changeErr: ErrInvalidCredentials})
+	r := setupUserRouter(h, uuid.NewString())
+
+	body := bytes.NewBufferString(`{"current_password":"wrong","new_password":"new-password"}`)
+	req := httptest.NewRequest(http.MethodPut, "/me/password", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_ChangeMyPassword_Success(t *testing.T) {
+	h := NewUserHandler(&mockUserService{})
+	r := setupUserRouter(h, uuid.NewString())
+
+	body := bytes.NewBufferString(`{"current_password":"secret-password","new_password":"new-password"}`)
+	req := httptest.NewRequest(http.MethodPut, "/me/password", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+}
@@ -3,11 +3,17 @@ package auth
 import (
 	"errors"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"sass.com/configsvc/internal/models"
 )
 
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("username already exists")
+)
+
 type UserRepo struct {
 	db *gorm.DB
 }
@@ -18,12 +24,30 @@ func NewUserRepo(db *gorm.DB) *UserRepo {
 
 type UserRepository interface {
 	FindByUsername(username string) (*models.User, error)
+	FindByID(id uuid.UUID) (*models.User, error)
 	VerifyPassword(u *models.User, password string) bool
+	Create(u *models.User, password string) error
+	List(clientID string) ([]models.User, error)
+	UpdateRole(id uuid.UUID, role models.Role) error
+	SetActive(id uuid.UUID, active bool) error
+	ResetPassword(id uuid.UUID, password string) error
 }
 
+// FindByUsername only returns active users, disabled users cannot log in
 func (r *UserRepo) FindByUsername(username string) (*models.User, error) {
 	var user models.User
-	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := r.db.Where("username = ? AND is_active = ?", username, 1).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // no error, just not found
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepo) FindByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil // no error, just not found
 		}
@@ -35,3 +59,62 @@ func (r *UserRepo) FindByUsername(username string) (*models.User, error) {
 func (r *UserRepo) VerifyPassword(u *models.User, password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
 }
+
+// Create stores the user with a bcrypt hash of password
+func (r *UserRepo) Create(u *models.User, password string) error {
+	var count int64
+	if err := r.db.Model(&models.User{}).Where("username = ?", u.Username).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	return r.db.Create(u).Error
+}
+
+func (r *UserRepo) List(clientID string) ([]models.User, error) {
+	var users []models.User
+	if err := r.db.Where("client_id = ?", clientID).
+		Order("username ASC").
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *UserRepo) UpdateRole(id uuid.UUID, role models.Role) error {
+	return r.updateColumn(id, "role", role)
+}
+
+func (r *UserRepo) SetActive(id uuid.UUID, active bool) error {
+	isActive := 0
+	if active {
+		isActive = 1
+	}
+	return r.updateColumn(id, "is_active", isActive)
+}
+
+func (r *UserRepo) ResetPassword(id uuid.UUID, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return r.updateColumn(id, "password_hash", string(hash))
+}
+
+func (r *UserRepo) updateColumn(id uuid.UUID, column string, value interface{}) error {
+	res := r.db.Model(&models.User{}).Where("id = ?", id).Update(column, value)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/google/uuid"
@@ -15,7 +16,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		t.Fatalf("failed to open in-memory sqlite: %v", err)
 	}
-	if err := db.AutoMigrate(&models.User{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.UserRevocation{}); err != nil {
 		t.Fatalf("failed to migrate schema: %v", err)
 	}
 	return db
@@ -68,3 +69,116 @@ func TestUserRepo_VerifyPassword(t *testing.T) {
 		t.Fatal("expected password to fail")
 	}
 }
+
+func TestUserRepo_FindByUsername_Inactive(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepo(db)
+
+	user := models.User{ID: uuid.New(), Username: "alice", Role: models.RoleUser}
+	if err := repo.Create(&user, "secret-password"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := repo.SetActive(user.ID, false); err != nil {
+		t.Fatalf("failed to disable user: %v", err)
+	}
+
+	found, err := repo.FindByUsername("alice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found != nil {
+		t.Fatalf("expected disabled user to be hidden, got %+v", found)
+	}
+
+	byID, err := repo.FindByID(user.ID)
+	if err != nil || byID == nil {
+		t.Fatalf("expected FindByID to return disabled user, got %+v, %v", byID, err)
+	}
+	if byID.IsActive != 0 {
+		t.Errorf("expected IsActive 0, got %d", byID.IsActive)
+	}
+}
+
+func TestUserRepo_Create(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepo(db)
+
+	user := models.User{ID: uuid.New(), Username: "alice", Role: models.RoleUser}
+	if err := repo.Create(&user, "secret-password"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if user.PasswordHash == "" || user.PasswordHash == "secret-password" {
+		t.Fatal("expected password to be stored as bcrypt hash")
+	}
+
+	found, _ := repo.FindByUsername("alice")
+	if found == nil || !repo.VerifyPassword(found, "secret-password") {
+		t.Fatal("expected created user to log in with the given password")
+	}
+
+	dup := models.User{ID: uuid.New(), Username: "alice", Role: models.RoleUser}
+	if err := repo.Create(&dup, "other-password"); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("expected ErrUsernameTaken, got %v", err)
+	}
+}
+
+func TestUserRepo_List(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepo(db)
+
+	for _, name := range []string{"bob", "alice"} {
+		u := models.User{ID: uuid.New(), ClientID: "acme", Username: name, Role: models.RoleUser}
+		if err := repo.Create(&u, "secret-password"); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	other := models.User{ID: uuid.New(), ClientID: "globex", Username: "carol", Role: models.RoleUser}
+	if err := repo.Create(&other, "secret-password"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	users, err := repo.List("acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 2 || users[0].Username != "alice" {
+		t.Fatalf("expected [alice bob], got %+v", users)
+	}
+}
+
+func TestUserRepo_UpdateRoleAndResetPassword(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepo(db)
+
+	user := models.User{ID: uuid.New(), Username: "alice", Role: models.RoleUser}
+	if err := repo.Create(&user, "secret-password"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	if err := repo.UpdateRole(user.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("failed to update role: %v", err)
+	}
+	if err := repo.ResetPassword(user.ID, "new-password"); err != nil {
+		t.Fatalf("failed to reset password: %v", err)
+	}
+
+	found, _ := repo.FindByID(user.ID)
+	if found.Role != models.RoleAdmin {
+		t.Errorf("expected role admin, got %s", found.Role)
+	}
+	if !repo.VerifyPassword(found, "new-password") {
+		t.Error("expected new password to verify")
+	}
+}
+
+func TestUserRepo_UpdateMissingUser(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepo(db)
+
+	if err := repo.UpdateRole(uuid.New(), models.RoleAdmin); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := repo.SetActive(uuid.New(), false); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+const minPasswordLength = 8
+
+var (
+	ErrInvalidRole  = errors.New("invalid role")
+	ErrWeakPassword = errors.New("password must be at least 8 characters")
+)
+
+// Admin operations are scoped by clientID, admins only manage users of their own tenant
+type UserService interface {
+	CreateUser(clientID, username, password string, role models.Role) (*models.User, error)
+	ListUsers(clientID string) ([]models.User, error)
+	GetUser(clientID string, id uuid.UUID) (*models.User, error)
+	ChangeRole(clientID string, id uuid.UUID, role models.Role) error
+	SetActive(clientID string, id uuid.UUID, active bool) error
+	ResetPassword(clientID string, id uuid.UUID, password string) error
+	ChangePassword(id uuid.UUID, currentPassword, newPassword string) error
+}
+
+type UserServiceImpl struct {
+	userRepo    UserRepository
+	authService AuthService
+}
+
+func NewUserService(userRepo UserRepository, authService AuthService) UserService {
+	return &UserServiceImpl{
+		userRepo:    userRepo,
+		authService: authService,
+	}
+}
+
+func (s *UserServiceImpl) CreateUser(clientID, username, password string, role models.Role) (*models.User, error) {
+	if role == "" {
+		role = models.RoleUser
+	}
+	if !isValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if len(password) < minPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	u := &models.User{
+		ID:       uuid.New(),
+		ClientID: clientID,
+		Username: username,
+		Role:     role,
+		IsActive: 1,
+	}
+	if err := s.userRepo.Create(u, password); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *UserServiceImpl) ListUsers(clientID string) ([]models.User, error) {
+	return s.userRepo.List(clientID)
+}
+
+// GetUser treats users of other tenants as not found
+func (s *UserServiceImpl) GetUser(clientID string, id uuid.UUID) (*models.User, error) {
+	u, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.ClientID != clientID {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+// ChangeRole updates the role and revokes existing tokens, which still carry the old role
+func (s *UserServiceImpl) ChangeRole(clientID string, id uuid.UUID, role models.Role) error {
+	if !isValidRole(role) {
+		return ErrInvalidRole
+	}
+	if _, err := s.GetUser(clientID, id); err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateRole(id, role); err != nil {
+		return err
+	}
+	return s.authService.RevokeAllForUser(clientID, id)
+}
+
+func (s *UserServiceImpl) SetActive(clientID string, id uuid.UUID, active bool) error {
+	if _, err := s.GetUser(clientID, id); err != nil {
+		return err
+	}
+	if err := s.userRepo.SetActive(id, active); err != nil {
+		return err
+	}
+	if active {
+		return nil
+	}
+	return s.authService.RevokeAllForUser(clientID, id)
+}
+
+func (s *UserServiceImpl) ResetPassword(clientID string, id uuid.UUID, password string) error {
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	if _, err := s.GetUser(clientID, id); err != nil {
+		return err
+	}
+	if err := s.userRepo.ResetPassword(id, password); err != nil {
+		return err
+	}
+	return s.authService.RevokeAllForUser(clientID, id)
+}
+
+// ChangePassword is the self-service variant of ResetPassword and requires the current password
+func (s *UserServiceImpl) ChangePassword(id uuid.UUID, currentPassword, newPassword string) error {
+	u, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+	if !s.userRepo.VerifyPassword(u, currentPassword) {
+		return ErrInvalidCredentials
+	}
+	return s.ResetPassword(u.ClientID, id, newPassword)
+}
+
+func isValidRole(role models.Role) bool {
+	return role == models.RoleAdmin || role == models.RoleUser
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+func TestUserService_CreateUser(t *testing.T) {
+	svc := NewUserService(NewUserRepo(setupTestDB(t)), &mockAuthService{})
+
+	u, err := svc.CreateUser("acme", "alice", "secret-password", "")
+	if err != nil {
+		t.Fatalf("expected success, got err: %v", err)
+	}
+	if u.Role != models.RoleUser || u.IsActive != 1 {
+		t.Fatalf("expected active user with default role, got %+v", u)
+	}
+}
+
+func TestUserService_CreateUser_Invalid(t *testing.T) {
+	svc := NewUserService(NewUserRepo(setupTestDB(t)), &mockAuthService{})
+
+	if _, err := svc.CreateUser("acme", "alice", "short", models.RoleUser); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("expected ErrWeakPassword, got %v", err)
+	}
+	if _, err := svc.CreateUser("acme", "alice", "secret-password", "root"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+}
+
+func TestUserService_SetActive_RevokesTokensOnDisable(t *testing.T) {
+	authSvc := &mockAuthService{}
+	svc := NewUserService(NewUserRepo(setupTestDB(t)), authSvc)
+
+	u, _ := svc.CreateUser("acme", "alice", "secret-password", models.RoleUser)
+	if err := svc.SetActive("acme", u.ID, false); err != nil {
+		t.Fatalf("expected success, got err: %v", err)
+	}
+	if authSvc.revokedUser != u.ID {
+		t.Fatal("expected tokens of disabled user to be revoked")
+	}
+
+	found, _ := svc.GetUser("acme", u.ID)
+	if found.IsActive != 0 {
+		t.Fatalf("expected user to be disabled, got %+v", found)
+	}
+}
+
+func TestUserService_ChangeRole_RevokesTokens(t *testing.T) {
+	authSvc := &mockAuthService{}
+	svc := NewUserService(NewUserRepo(setupTestDB(t)), authSvc)
+
+	u, _ := svc.CreateUser("acme", "alice", "secret-password", models.RoleAdmin)
+	if err := svc.ChangeRole("acme", u.ID, models.RoleUser); err != nil {
+		t.Fatalf("expected success, got err: %v", err)
+	}
+	if authSvc.revokedUser != u.ID {
+		t.Fatal("expected tokens carrying the old role to be revoked")
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	repo := NewUserRepo(setupTestDB(t))
+	svc := NewUserService(repo, &mockAuthService{})
+
+	u, _ := svc.CreateUser("acme", "alice", "secret-password", models.RoleUser)
+
+	if err := svc.ChangePassword(u.ID, "wrong-password", "new-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if err := svc.ChangePassword(u.ID, "secret-password", "new-password"); err != nil {
+		t.Fatalf("expected success, got err: %v", err)
+	}
+
+	found, _ := repo.FindByID(u.ID)
+	if !repo.VerifyPassword(found, "new-password") {
+		t.Fatal("expected new password to verify")
+	}
+}
+
+func TestUserService_GetUser_NotFound(t *testing.T) {
+	svc := NewUserService(NewUserRepo(setupTestDB(t)), &mockAuthService{})
+
+	if _, err := svc.GetUser("acme", uuid.New()); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUserService_OtherTenant(t *testing.T) {
+	authSvc := &mockAuthService{}
+	svc := NewUserService(NewUserRepo(setupTestDB(t)), authSvc)
+
+	u, _ := svc.CreateUser("acme", "alice", "secret-password", models.RoleUser)
+
+	if _, err := svc.GetUser("globex", u.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := svc.SetActive("globex", u.ID, false); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := svc.ResetPassword("globex", u.ID, "new-password"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if users, _ := svc.ListUsers("globex"); len(users) != 0 {
+		t.Fatalf("expected no users in other tenant, got %+v", users)
+	}
+
+	found, _ := svc.GetUser("acme", u.ID)
+	if found.IsActive != 1 || authSvc.revokedUser == u.ID {
+		t.Fatal("expected user to be untouched by another tenant's admin")
+	}
+}
@@ -8,25 +8,58 @@ import (
 
 var (
 	// Mimicking Redis cache
-	cache *sync.Map
+	mu      sync.Mutex
+	entries map[string]*models.LastConfigurations
+	// Bumped by every Remove, so a read that started before a write cannot
+	// cache what it read after the write has removed the entry
+	generations map[string]uint64
 )
 
 func Init() {
-	cache = &sync.Map{}
+	mu.Lock()
+	defer mu.Unlock()
+	entries = map[string]*models.LastConfigurations{}
+	generations = map[string]uint64{}
 }
 
-func Put(name string, lastCfg *models.LastConfigurations) {
-	cache.Store(name, lastCfg)
+// Entries are scoped per tenant so equally named configs never collide
+func key(clientID, name string) string {
+	return clientID + "/" + name
 }
 
-func Get(name string) (*models.LastConfigurations, bool) {
-	val, ok := cache.Load(name)
-	if !ok {
-		return nil, false
+// Generation is taken before reading what Fill caches
+func Generation(clientID, name string) uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return generations[key(clientID, name)]
+}
+
+// Fill caches lastCfg, read from the database after Generation returned
+// gen, unless the entry was removed since. It reports whether it did.
+func Fill(clientID, name string, lastCfg *models.LastConfigurations, gen uint64) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	k := key(clientID, name)
+	if generations[k] != gen {
+		return false
 	}
-	return val.(*models.LastConfigurations), true
+	entries[k] = lastCfg
+	return true
+}
+
+func Get(clientID, name string) (*models.LastConfigurations, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	lastCfg, ok := entries[key(clientID, name)]
+	return lastCfg, ok
 }
 
-func Remove(name string) {
-	cache.Delete(name)
+// Remove invalidates the entry, writers call it once their change is
+// committed
+func Remove(clientID, name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	k := key(clientID, name)
+	delete(entries, k)
+	generations[k]++
 }
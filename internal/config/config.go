@@ -7,8 +7,10 @@ import (
 
 type Config struct {
 	Port                     int
+	GRPCPort                 int // 0 disables the gRPC server
 	AccessTokenTTLInDays     int
 	RefreshTokenTTLInMinutes int
+	SchemaCacheSize          int // compiled JSON Schemas kept in memory, 0 for the default
 }
 
 func LoadConfig() (*Config, error) {
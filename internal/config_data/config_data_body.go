@@ -0,0 +1,409 @@
+package configdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError is a config body that could not be parsed. Line and Column
+// start at 1 and are 0 when unknown.
+type ParseError struct {
+	Format string
+	Field  string // set when a JSON-encoded schema or input is broken
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	where := e.Format
+	if e.Field != "" {
+		where = e.Field
+	}
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("invalid %s at line %d, column %d: %s", where, e.Line, e.Column, e.Msg)
+	case e.Line > 0:
+		return fmt.Sprintf("invalid %s at line %d: %s", where, e.Line, e.Msg)
+	default:
+		return fmt.Sprintf("invalid %s: %s", where, e.Msg)
+	}
+}
+
+// NormalizeConfigBody rewrites the body of a config write as canonical JSON
+// before it is read by anything else, e.g. policy.NameFromBody. See
+// normalizeConfigBody for what is accepted.
+func NormalizeConfigBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := normalizeRequestBody(c); err != nil {
+			writeBodyError(c, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// bindConfig decodes a config write into v, whatever format it came in
+func bindConfig(c *gin.Context, v interface{}) error {
+	body, err := normalizeRequestBody(c)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// normalizeRequestBody replaces the request body with its canonical JSON
+func normalizeRequestBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil {
+		return nil, &ParseError{Format: "json", Msg: "empty body"}
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	body, err := normalizeConfigBody(c.ContentType(), raw)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return body, nil
+}
+
+// writeBodyError answers a body that could not be parsed, with the position
+// of the problem when known
+func writeBodyError(c *gin.Context, err error) {
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		fmt.Println("Bind error:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	resp := gin.H{"error": perr.Error()}
+	if perr.Line > 0 {
+		resp["line"] = perr.Line
+	}
+	if perr.Column > 0 {
+		resp["column"] = perr.Column
+	}
+	if perr.Field != "" {
+		resp["field"] = perr.Field
+	}
+	c.JSON(http.StatusBadRequest, resp)
+}
+
+// normalizeConfigBody turns a config write into canonical JSON: compact,
+// with sorted keys. The body is YAML for application/yaml, TOML for
+// application/toml and JSON otherwise. Schema and Input may be given as
+// objects or as JSON-encoded strings; either way they end up as canonical
+// JSON strings.
+func normalizeConfigBody(contentType string, raw []byte) ([]byte, error) {
+	var doc interface{}
+	var err error
+	switch mediaType(contentType) {
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		doc, err = parseYAML(raw)
+	case "application/toml":
+		doc, err = parseTOML(raw)
+	default:
+		doc, err = parseJSON("json", raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &ParseError{Format: "body", Msg: "expected an object"}
+	}
+	// Field names are case-insensitive, like ShouldBindJSON's
+	for k, v := range fields {
+		if v == nil || !strings.EqualFold(k, "schema") && !strings.EqualFold(k, "input") {
+			continue
+		}
+		field := strings.ToLower(k)
+		if s, ok := v.(string); ok {
+			// A JSON-encoded document, as the API always accepted
+			if fields[k], err = canonicalDocument(field, s); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		canonical, err := json.Marshal(v)
+		if err != nil {
+			return nil, &ParseError{Format: "body", Field: field, Msg: err.Error()}
+		}
+		fields[k] = string(canonical)
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, &ParseError{Format: "body", Msg: err.Error()}
+	}
+	return body, nil
+}
+
+// canonicalDocument rewrites the JSON-encoded schema or input field as
+// canonical JSON, so a document is stored the same whichever way it came in
+func canonicalDocument(field, s string) (string, error) {
+	v, err := parseJSON("json", []byte(s))
+	if err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.Field = field
+		}
+		return "", err
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", &ParseError{Format: "body", Field: field, Msg: err.Error()}
+	}
+	return string(canonical), nil
+}
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+// parseJSON decodes a single JSON document, keeping numbers as written
+func parseJSON(format string, data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	err := dec.Decode(&doc)
+	if err == nil {
+		if _, err = dec.Token(); err != io.EOF {
+			return nil, jsonParseError(format, data, dec.InputOffset(), "unexpected data after the document")
+		}
+		return doc, nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return nil, jsonParseError(format, data, syntaxErr.Offset, strings.TrimPrefix(err.Error(), "json: "))
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return nil, jsonParseError(format, data, int64(len(data)), "unexpected end of input")
+	default:
+		return nil, &ParseError{Format: format, Msg: err.Error()}
+	}
+}
+
+// jsonParseError locates the byte before offset, the last one read, as line and column
+func jsonParseError(format string, data []byte, offset int64, msg string) *ParseError {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset > 0 {
+		offset--
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return &ParseError{Format: format, Line: line, Column: column, Msg: msg}
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// parseYAML decodes YAML into what encoding/json would produce for the same
+// document. Timestamps stay the strings they were written as.
+func parseYAML(data []byte) (interface{}, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		// The YAML parser reports the line of syntax errors, but not the column
+		msg := strings.TrimPrefix(err.Error(), "yaml: ")
+		perr := &ParseError{Format: "yaml", Msg: msg}
+		if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+			perr.Line, _ = strconv.Atoi(m[1])
+			perr.Msg = strings.TrimPrefix(msg, m[0]+": ")
+		}
+		return nil, perr
+	}
+	if root.Kind == 0 {
+		return nil, &ParseError{Format: "yaml", Msg: "empty document"}
+	}
+	d := &yamlDecoder{budget: maxYAMLExpansion*countYAMLNodes(&root) + minYAMLBudget}
+	return d.value(&root)
+}
+
+// Aliases may expand a document to at most maxYAMLExpansion times its own
+// nodes, so a few nested aliases (a "billion laughs") cannot exhaust memory
+const (
+	maxYAMLExpansion = 10
+	minYAMLBudget    = 10000
+)
+
+// countYAMLNodes counts the nodes as written, aliases once
+func countYAMLNodes(n *yaml.Node) int {
+	count := 1
+	for _, child := range n.Content {
+		count += countYAMLNodes(child)
+	}
+	return count
+}
+
+// yamlDecoder converts nodes until its budget of nodes, aliases expanded,
+// runs out
+type yamlDecoder struct {
+	budget int
+}
+
+func (d *yamlDecoder) value(n *yaml.Node) (interface{}, error) {
+	if d.budget--; d.budget < 0 {
+		return nil, yamlNodeError(n, "aliases expand to too large a document")
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return d.value(n.Content[0])
+	case yaml.AliasNode:
+		return d.value(n.Alias)
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(n.Content))
+		for _, child := range n.Content {
+			v, err := d.value(child)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		if err := d.mapping(n, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool", "!!int", "!!float":
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, yamlNodeError(n, err.Error())
+		}
+		if f, ok := v.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return nil, yamlNodeError(n, "JSON has no infinity or NaN")
+		}
+		return v, nil
+	case "!!str", "!!timestamp":
+		return n.Value, nil
+	default:
+		return nil, yamlNodeError(n, "unsupported tag "+n.ShortTag())
+	}
+}
+
+// mapping adds the entries of n to m, entries given before a merge key
+// (<<) win over the merged ones
+func (d *yamlDecoder) mapping(n *yaml.Node, m map[string]interface{}) error {
+	var merged []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return yamlNodeError(key, "keys must be scalars")
+		}
+		if key.ShortTag() == "!!merge" {
+			merged = append(merged, value)
+			continue
+		}
+		if _, ok := m[key.Value]; ok {
+			return yamlNodeError(key, fmt.Sprintf("key %q is already defined", key.Value))
+		}
+		v, err := d.value(value)
+		if err != nil {
+			return err
+		}
+		m[key.Value] = v
+	}
+
+	for _, src := range merged {
+		if src.Kind == yaml.AliasNode {
+			src = src.Alias
+		}
+		sources := []*yaml.Node{src}
+		if src.Kind == yaml.SequenceNode {
+			sources = src.Content
+		}
+		for _, s := range sources {
+			if s.Kind == yaml.AliasNode {
+				s = s.Alias
+			}
+			if s.Kind != yaml.MappingNode {
+				return yamlNodeError(s, "merge keys take mappings")
+			}
+			extra := map[string]interface{}{}
+			if err := d.mapping(s, extra); err != nil {
+				return err
+			}
+			for k, v := range extra {
+				if _, ok := m[k]; !ok {
+					m[k] = v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func yamlNodeError(n *yaml.Node, msg string) *ParseError {
+	return &ParseError{Format: "yaml", Line: n.Line, Column: n.Column, Msg: msg}
+}
+
+func parseTOML(data []byte) (interface{}, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		var derr *toml.DecodeError
+		if errors.As(err, &derr) {
+			line, column := derr.Position()
+			return nil, &ParseError{Format: "toml", Line: line, Column: column, Msg: derr.Error()}
+		}
+		return nil, &ParseError{Format: "toml", Msg: err.Error()}
+	}
+	if err := finiteNumbers(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// finiteNumbers rejects the inf and nan TOML allows but JSON does not
+func finiteNumbers(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			if err := finiteNumbers(child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if err := finiteNumbers(child); err != nil {
+				return err
+			}
+		}
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return &ParseError{Format: "toml", Msg: "JSON has no infinity or NaN"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package configdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"sass.com/configsvc/internal/models"
+)
+
+const (
+	bodySchema = `{"properties":{"db":{"properties":{"port":{"type":"integer"}},"type":"object"}},"type":"object"}`
+	bodyInput  = `{"db":{"host":"db.local","port":5432},"since":"2024-01-02","tags":["a","b"]}`
+)
+
+func TestNormalizeConfigBody(t *testing.T) {
+	tests := []struct {
+		name, contentType, body string
+	}{
+		{"json strings", "application/json", `{"name":"app","schema":"{\"type\":\"object\",\n \"properties\":{\"db\":{\"type\":\"object\",\"properties\":{\"port\":{\"type\":\"integer\"}}}}}","input":"{\"tags\":[\"a\",\"b\"],\"since\":\"2024-01-02\",\"db\":{\"port\":5432,\"host\":\"db.local\"}}"}`},
+		{"json objects", "", `{"name":"app","Schema":{"type":"object","properties":{"db":{"type":"object","properties":{"port":{"type":"integer"}}}}},"Input":{"tags":["a","b"],"since":"2024-01-02","db":{"port":5432,"host":"db.local"}}}`},
+		{"yaml", "application/yaml; charset=utf-8", `
+name: app
+schema:
+  type: object
+  properties:
+    db:
+      type: object
+      properties:
+        port: {type: integer}
+defaults: &db
+  host: db.local
+input:
+  tags: [a, b]
+  since: 2024-01-02
+  db:
+    <<: *db
+    port: 5432
+`},
+		{"toml", "application/toml", `
+name = "app"
+
+[schema]
+type = "object"
+[schema.properties.db]
+type = "object"
+[schema.properties.db.properties.port]
+type = "integer"
+
+[input]
+tags = ["a", "b"]
+since = "2024-01-02"
+[input.db]
+host = "db.local"
+port = 5432
+`},
+	}
+	for _, tt := range tests {
+		body, err := normalizeConfigBody(tt.contentType, []byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		var cfg models.Configurations
+		if err := json.Unmarshal(body, &cfg); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if cfg.Name != "app" || cfg.Schema != bodySchema || cfg.Input != bodyInput {
+			t.Errorf("%s: unexpected config\n%s\n%s", tt.name, cfg.Schema, cfg.Input)
+		}
+	}
+}
+
+func TestNormalizeConfigBody_ParseErrors(t *testing.T) {
+	tests := []struct {
+		name, contentType, body string
+		line, column            int
+		field                   string
+	}{
+		{"json", "application/json", "{\n  \"name\": \"app\",\n  \"input\": {\"a\": }\n}", 3, 18, ""},
+		{"json trailing", "application/json", `{"name":"app"} {}`, 1, 16, ""},
+		{"json input string", "application/json", `{"name":"app","input":"{\"a\":\n tru}"}`, 2, 5, "input"},
+		{"yaml", "application/yaml", "name: app\ninput:\n  a: b: c\n", 3, 0, ""},
+		{"yaml duplicate", "application/yaml", "name: app\ninput:\n  a: 1\n  a: 2\n", 4, 3, ""},
+		{"yaml tag", "application/yaml", "name: app\ninput:\n  a: !!binary aGk=\n", 3, 6, ""},
+		{"yaml nan", "application/yaml", "name: app\ninput:\n  a: .nan\n", 3, 6, ""},
+		{"toml", "application/toml", "name = \"app\"\n[input]\na = \n", 3, 5, ""},
+		{"toml nan", "application/toml", "[input]\na = nan\n", 0, 0, ""},
+		{"not an object", "application/json", `[1]`, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		_, err := normalizeConfigBody(tt.contentType, []byte(tt.body))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: expected a ParseError, got %v", tt.name, err)
+			continue
+		}
+		if perr.Line != tt.line || perr.Column != tt.column || perr.Field != tt.field {
+			t.Errorf("%s: expected line %d, column %d, field %q, got %+v", tt.name, tt.line, tt.column, tt.field, perr)
+		}
+	}
+}
+
+func TestNormalizeConfigBody_YAMLAliasBomb(t *testing.T) {
+	// Each level refers to the previous one ten times, 10^9 strings in all
+	var b strings.Builder
+	b.WriteString("name: app\na0: &a0 [lol]\n")
+	for i := 1; i <= 9; i++ {
+		fmt.Fprintf(&b, "a%d: &a%d [", i, i)
+		for j := 0; j < 10; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "*a%d", i-1)
+		}
+		b.WriteString("]\n")
+	}
+	b.WriteString("input: *a9\n")
+
+	start := time.Now()
+	_, err := normalizeConfigBody("application/yaml", []byte(b.String()))
+	var perr *ParseError
+	if !errors.As(err, &perr) || !strings.Contains(perr.Msg, "too large") {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected to give up early, took %s", elapsed)
+	}
+
+	// A few aliases are fine
+	if _, err := normalizeConfigBody("application/yaml", []byte("name: app\nbase: &b {a: 1}\ninput: {x: *b, y: *b}\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestConfigHandler_CreateConfig_YAML(t *testing.T) {
+	h := NewConfigHandler(&mockConfigService{})
+	r := setupGin()
+	r.POST("/configs", setIdentity("admin", "tester"), NormalizeConfigBody(), func(c *gin.Context) {
+		// What policy.NameFromBody sees
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		var doc map[string]interface{}
+		if err := json.Unmarshal(body, &doc); err != nil || doc["name"] != "app" {
+			t.Errorf("expected a JSON body with the name, got %s", body)
+		}
+	}, h.CreateConfig)
+
+	body := "name: app\ntype: object\nschema:\n  type: object\n  required: [enabled]\ninput:\n  enabled: true\n"
+	req := httptest.NewRequest(http.MethodPost, "/configs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/yaml")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var cfg models.Configurations
+	if err := json.Unmarshal(w.Body.Bytes(), &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Schema != `{"required":["enabled"],"type":"object"}` || cfg.Input != `{"enabled":true}` {
+		t.Errorf("unexpected schema %s and input %s", cfg.Schema, cfg.Input)
+	}
+}
+
+func TestConfigHandler_UpdateConfig_ParseError(t *testing.T) {
+	h := NewConfigHandler(&mockConfigService{})
+	r := setupGin()
+	r.PUT("/configs/:name", setIdentity("admin", "tester"), h.UpdateConfig)
+
+	req := httptest.NewRequest(http.MethodPut, "/configs/app", strings.NewReader("[input]\nenabled = yes\n"))
+	req.Header.Set("Content-Type", "application/toml")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var resp struct {
+		Error        string
+		Line, Column int
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Line != 2 || resp.Column == 0 || !strings.Contains(resp.Error, "invalid toml at line 2") {
+		t.Errorf("unexpected error response %s", w.Body.String())
+	}
+}
@@ -0,0 +1,436 @@
+package configdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"sass.com/configsvc/internal/models"
+)
+
+var (
+	ErrIncompatibleSchema = errors.New("schema change is not backward compatible")
+	ErrForceNotAllowed    = errors.New("only admins can force a breaking schema change")
+	ErrWriteContended     = errors.New("config kept changing, retry the write")
+)
+
+// Keywords that only document a schema, changing them never rejects an input
+var annotationKeywords = map[string]bool{
+	"title": true, "description": true, "default": true, "examples": true, "$comment": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// Keywords that only ever reject inputs, so removing them is always compatible
+var constraintKeywords = map[string]bool{
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true, "$ref": true,
+	"if": true, "then": true, "else": true, "contains": true, "propertyNames": true,
+	"dependencies": true, "dependentRequired": true, "dependentSchemas": true,
+	"unevaluatedProperties": true, "unevaluatedItems": true,
+}
+
+// Bounds that may only go down, and the ones that may only go up
+var (
+	lowerBounds = []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties", "minContains"}
+	upperBounds = []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties", "maxContains"}
+)
+
+// checkSchemaCompatibility reports whether every input valid under oldSchema
+// is still valid under newSchema. Adding optional properties, widening types
+// and enums, and relaxing or dropping constraints are compatible. Anything
+// that could reject an input, or that cannot be compared keyword by keyword
+// (allOf, $ref, patternProperties...), is not. It returns a *ValidationError
+// of ErrIncompatibleSchema with one violation per incompatibility, whose
+// InstancePath is the part of the input affected, "*" standing for any array
+// element, and whose KeywordPath is the keyword of the new schema.
+func checkSchemaCompatibility(oldSchema, newSchema string) error {
+	var from, to interface{}
+	if err := json.Unmarshal([]byte(oldSchema), &from); err != nil {
+		return &ValidationError{Err: ErrInvalidSchema, Violations: []Violation{{Message: "current schema is not valid JSON: " + err.Error()}}}
+	}
+	if err := json.Unmarshal([]byte(newSchema), &to); err != nil {
+		return &ValidationError{Err: ErrInvalidSchema, Violations: []Violation{{Message: "schema is not valid JSON: " + err.Error()}}}
+	}
+
+	var c compatChecker
+	c.check("", "", from, to)
+	if len(c.violations) == 0 {
+		return nil
+	}
+	sort.SliceStable(c.violations, func(i, j int) bool {
+		if c.violations[i].InstancePath != c.violations[j].InstancePath {
+			return c.violations[i].InstancePath < c.violations[j].InstancePath
+		}
+		return c.violations[i].KeywordPath < c.violations[j].KeywordPath
+	})
+	return &ValidationError{Err: ErrIncompatibleSchema, Violations: c.violations}
+}
+
+// checkSchemaChange is checkSchemaCompatibility for a write: a forced change
+// is let through for admins and fails with ErrForceNotAllowed for everyone else
+func checkSchemaChange(current, schema string, force bool, role string) error {
+	err := checkSchemaCompatibility(current, schema)
+	if err == nil || !force || !errors.Is(err, ErrIncompatibleSchema) {
+		return err
+	}
+	if role != string(models.RoleAdmin) {
+		return ErrForceNotAllowed
+	}
+	return nil
+}
+
+// How often a schema checked write without an expected version is checked
+// and written again after another write got in between
+const maxCheckedWriteAttempts = 3
+
+// writeSchemaChecked runs checkSchemaChange of schema against the latest
+// version, then write over that version only, as the check holds for it
+// alone. A caller's expectedVersion is passed on and fails with
+// ErrVersionConflict as usual. Without one, a lost race is read and checked
+// again, and ErrWriteContended ends the retries. It returns the latest
+// version written over.
+func writeSchemaChecked(svc ConfigService, clientID, name, schema string, force bool, role string, expectedVersion int,
+	write func(expected int) error) (*models.LastConfigurations, error) {
+	for attempt := 1; ; attempt++ {
+		latest, err := svc.GetLastVersionByName(clientID, name)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			return nil, ErrConfigNotFound
+		}
+		if err := checkSchemaChange(latest.Schema, schema, force, role); err != nil {
+			return nil, err
+		}
+
+		expected := expectedVersion
+		if expected == 0 {
+			expected = latest.Version
+		}
+		err = write(expected)
+		switch {
+		case !errors.Is(err, ErrVersionConflict) || expectedVersion != 0:
+			return latest, err
+		case attempt == maxCheckedWriteAttempts:
+			return nil, ErrWriteContended
+		}
+	}
+}
+
+type compatChecker struct {
+	violations []Violation
+}
+
+func (c *compatChecker) fail(instance, keyword, format string, args ...interface{}) {
+	c.violations = append(c.violations, Violation{InstancePath: instance, KeywordPath: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// check compares the subschemas at schemaPath of both schemas, instance is
+// what they validate
+func (c *compatChecker) check(instance, schemaPath string, from, to interface{}) {
+	// true and {} accept everything, false nothing
+	if to == true {
+		return
+	}
+	if from == false {
+		return
+	}
+	if to == false {
+		c.fail(instance, schemaPath, "no value is allowed anymore")
+		return
+	}
+	fm, ok := asSchema(from)
+	if !ok {
+		c.fail(instance, schemaPath, "schema cannot be compared")
+		return
+	}
+	tm, ok := asSchema(to)
+	if !ok {
+		c.fail(instance, schemaPath, "schema cannot be compared")
+		return
+	}
+
+	c.checkType(instance, schemaPath, fm, tm)
+	c.checkEnum(instance, schemaPath, fm, tm)
+	c.checkRequired(instance, schemaPath, fm, tm)
+	c.checkProperties(instance, schemaPath, fm, tm)
+	c.checkItems(instance, schemaPath, fm, tm)
+	for _, k := range lowerBounds {
+		c.checkBound(instance, schemaPath, k, fm, tm, false)
+	}
+	for _, k := range upperBounds {
+		c.checkBound(instance, schemaPath, k, fm, tm, true)
+	}
+	c.checkMultipleOf(instance, schemaPath, fm, tm)
+	for _, k := range []string{"pattern", "format", "const"} {
+		if v, ok := tm[k]; ok && !reflect.DeepEqual(fm[k], v) {
+			if _, had := fm[k]; had {
+				c.fail(instance, schemaPath+"/"+k, "%s changed from %s to %s", k, jsonString(fm[k]), jsonString(v))
+			} else {
+				c.fail(instance, schemaPath+"/"+k, "%s %s was added", k, jsonString(v))
+			}
+		}
+	}
+	if tm["uniqueItems"] == true && fm["uniqueItems"] != true {
+		c.fail(instance, schemaPath+"/uniqueItems", "array items must now be unique")
+	}
+
+	// Whatever is left is compared as is
+	for _, k := range unionKeys(fm, tm) {
+		if annotationKeywords[k] || checkedKeywords[k] || reflect.DeepEqual(fm[k], tm[k]) {
+			continue
+		}
+		if _, kept := tm[k]; !kept && (constraintKeywords[k] || isRegisteredKeyword(k)) {
+			continue
+		}
+		c.fail(instance, schemaPath+"/"+escapePointerToken(k), "changes to %s cannot be checked for compatibility", k)
+	}
+}
+
+var checkedKeywords = func() map[string]bool {
+	m := map[string]bool{
+		"type": true, "enum": true, "required": true, "properties": true, "additionalProperties": true,
+		"items": true, "multipleOf": true, "pattern": true, "format": true, "const": true, "uniqueItems": true,
+	}
+	for _, k := range append(append([]string{}, lowerBounds...), upperBounds...) {
+		m[k] = true
+	}
+	return m
+}()
+
+func (c *compatChecker) checkType(instance, schemaPath string, fm, tm map[string]interface{}) {
+	newTypes, ok := typeSet(tm["type"])
+	if !ok {
+		return
+	}
+	oldTypes, ok := typeSet(fm["type"])
+	if !ok {
+		c.fail(instance, schemaPath+"/type", "type %s was added", jsonString(tm["type"]))
+		return
+	}
+	for _, t := range sortedSet(oldTypes) {
+		if newTypes[t] || t == "integer" && newTypes["number"] {
+			continue
+		}
+		c.fail(instance, schemaPath+"/type", "type %s is not allowed anymore", t)
+	}
+}
+
+func (c *compatChecker) checkEnum(instance, schemaPath string, fm, tm map[string]interface{}) {
+	newEnum, ok := tm["enum"].([]interface{})
+	if !ok {
+		return
+	}
+	oldEnum, ok := fm["enum"].([]interface{})
+	if !ok {
+		c.fail(instance, schemaPath+"/enum", "enum %s was added", jsonString(newEnum))
+		return
+	}
+	for _, v := range oldEnum {
+		if !containsValue(newEnum, v) {
+			c.fail(instance, schemaPath+"/enum", "enum value %s was removed", jsonString(v))
+		}
+	}
+}
+
+func (c *compatChecker) checkRequired(instance, schemaPath string, fm, tm map[string]interface{}) {
+	oldRequired, _ := fm["required"].([]interface{})
+	newRequired, _ := tm["required"].([]interface{})
+	for _, name := range newRequired {
+		if !containsValue(oldRequired, name) {
+			c.fail(instance, schemaPath+"/required", "property %s is now required", jsonString(name))
+		}
+	}
+}
+
+// checkProperties compares the properties both schemas know, new ones are
+// optional unless required says otherwise. Removed properties and unknown
+// ones are validated by additionalProperties from now on.
+func (c *compatChecker) checkProperties(instance, schemaPath string, fm, tm map[string]interface{}) {
+	oldProps, _ := fm["properties"].(map[string]interface{})
+	newProps, _ := tm["properties"].(map[string]interface{})
+	oldExtra, hasOldExtra := fm["additionalProperties"]
+	newExtra, hasNewExtra := tm["additionalProperties"]
+	if !hasOldExtra {
+		oldExtra = true
+	}
+	if !hasNewExtra {
+		newExtra = true
+	}
+
+	for _, name := range sortedKeys(oldProps) {
+		propInstance := instance + "/" + escapePointerToken(name)
+		if newProp, ok := newProps[name]; ok {
+			c.check(propInstance, schemaPath+"/properties/"+escapePointerToken(name), oldProps[name], newProp)
+			continue
+		}
+		if newExtra == false {
+			c.fail(propInstance, schemaPath+"/properties", "property %q was removed and additional properties are not allowed", name)
+			continue
+		}
+		c.check(propInstance, schemaPath+"/additionalProperties", oldProps[name], newExtra)
+	}
+
+	// New properties are optional ones, unless the old schema constrained
+	// unknown properties already
+	if _, constrained := oldExtra.(map[string]interface{}); constrained {
+		for _, name := range sortedKeys(newProps) {
+			if _, ok := oldProps[name]; !ok {
+				c.check(instance+"/"+escapePointerToken(name), schemaPath+"/properties/"+escapePointerToken(name), oldExtra, newProps[name])
+			}
+		}
+	}
+	if oldExtra == false && newExtra == false {
+		return
+	}
+	if oldExtra != false && newExtra == false {
+		c.fail(instance, schemaPath+"/additionalProperties", "additional properties are not allowed anymore")
+		return
+	}
+	c.check(instance+"/*", schemaPath+"/additionalProperties", oldExtra, newExtra)
+}
+
+func (c *compatChecker) checkItems(instance, schemaPath string, fm, tm map[string]interface{}) {
+	oldItems, hasOld := fm["items"]
+	newItems, hasNew := tm["items"]
+	if !hasNew {
+		// Dropping a tuple leaves the extra items to additionalItems
+		if _, tuple := oldItems.([]interface{}); tuple && tm["additionalItems"] != nil {
+			c.fail(instance, schemaPath+"/items", "changes to items cannot be checked for compatibility")
+		}
+		return
+	}
+	if !hasOld {
+		oldItems = true
+	}
+	_, oldTuple := oldItems.([]interface{})
+	_, newTuple := newItems.([]interface{})
+	if oldTuple || newTuple {
+		if !reflect.DeepEqual(oldItems, newItems) {
+			c.fail(instance, schemaPath+"/items", "changes to items cannot be checked for compatibility")
+		}
+		return
+	}
+	c.check(instance+"/*", schemaPath+"/items", oldItems, newItems)
+}
+
+// checkBound rejects bounds that were added or tightened, upper bounds may
+// only go up and lower bounds only down
+func (c *compatChecker) checkBound(instance, schemaPath, keyword string, fm, tm map[string]interface{}, upper bool) {
+	v, ok := tm[keyword]
+	if !ok {
+		return
+	}
+	keywordPath := schemaPath + "/" + keyword
+	newBound, ok := v.(float64)
+	if !ok {
+		// Draft 4 exclusiveMinimum and exclusiveMaximum are booleans
+		if !reflect.DeepEqual(fm[keyword], v) {
+			c.fail(instance, keywordPath, "changes to %s cannot be checked for compatibility", keyword)
+		}
+		return
+	}
+	old, had := fm[keyword]
+	if !had {
+		c.fail(instance, keywordPath, "%s %s was added", keyword, jsonString(v))
+		return
+	}
+	oldBound, ok := old.(float64)
+	if !ok {
+		c.fail(instance, keywordPath, "changes to %s cannot be checked for compatibility", keyword)
+		return
+	}
+	switch {
+	case upper && newBound < oldBound:
+		c.fail(instance, keywordPath, "%s was lowered from %s to %s", keyword, jsonString(old), jsonString(v))
+	case !upper && newBound > oldBound:
+		c.fail(instance, keywordPath, "%s was raised from %s to %s", keyword, jsonString(old), jsonString(v))
+	}
+}
+
+// checkMultipleOf accepts a new divisor that divides the old one
+func (c *compatChecker) checkMultipleOf(instance, schemaPath string, fm, tm map[string]interface{}) {
+	v, ok := tm["multipleOf"]
+	if !ok || reflect.DeepEqual(fm["multipleOf"], v) {
+		return
+	}
+	old, had := fm["multipleOf"]
+	if !had {
+		c.fail(instance, schemaPath+"/multipleOf", "multipleOf %s was added", jsonString(v))
+		return
+	}
+	oldDivisor, okOld := old.(float64)
+	newDivisor, okNew := v.(float64)
+	if okOld && okNew && newDivisor > 0 {
+		ratio := oldDivisor / newDivisor
+		if ratio == math.Trunc(ratio) {
+			return
+		}
+	}
+	c.fail(instance, schemaPath+"/multipleOf", "multipleOf changed from %s to %s", jsonString(old), jsonString(v))
+}
+
+// asSchema treats the boolean schema true as the empty schema
+func asSchema(v interface{}) (map[string]interface{}, bool) {
+	if v == true {
+		return map[string]interface{}{}, true
+	}
+	m, ok := v.(map[string]interface{})
+	return m, ok
+}
+
+// typeSet returns the types allowed by a type keyword, false when there is none
+func typeSet(v interface{}) (map[string]bool, bool) {
+	switch v := v.(type) {
+	case string:
+		return map[string]bool{v: true}, true
+	case []interface{}:
+		set := map[string]bool{}
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				set[s] = true
+			}
+		}
+		return set, true
+	}
+	return nil, false
+}
+
+func sortedSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := sortedKeys(a)
+	for _, k := range sortedKeys(b) {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
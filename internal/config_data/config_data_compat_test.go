@@ -0,0 +1,96 @@
+package configdata
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckSchemaCompatibility(t *testing.T) {
+	const base = `{"type":"object","properties":{"name":{"type":"string","maxLength":10},"mode":{"enum":["a","b"]},"port":{"type":"integer","minimum":1,"maximum":100},"tags":{"type":"array","items":{"type":"string"}}},"required":["name"]}`
+
+	tests := []struct {
+		name       string
+		old, new   string
+		violations []Violation
+	}{
+		{"same schema", base, base, nil},
+		{"annotations", `{"type":"string"}`, `{"type":"string","title":"Name","description":"The name","default":"x"}`, nil},
+		{"optional property", base, `{"type":"object","properties":{"name":{"type":"string","maxLength":10},"mode":{"enum":["a","b"]},"port":{"type":"integer","minimum":1,"maximum":100},"tags":{"type":"array","items":{"type":"string"}},"debug":{"type":"boolean"}},"required":["name"]}`, nil},
+		{"relaxed constraints", base, `{"type":"object","properties":{"name":{"type":"string","maxLength":20},"mode":{"enum":["a","b","c"]},"port":{"type":["integer","string"]},"tags":{"type":"array"}}}`, nil},
+		{"integer to number", `{"type":"integer"}`, `{"type":"number"}`, nil},
+		{"dropped constraint keywords", `{"type":"object","allOf":[{"required":["a"]}],"not":{"required":["b"]}}`, `{"type":"object"}`, nil},
+		{"closed schema opened", `{"type":"object","additionalProperties":false}`, `{"type":"object"}`, nil},
+		{"new property of a closed schema", `{"type":"object","additionalProperties":false}`, `{"type":"object","properties":{"a":{"type":"string"}},"additionalProperties":false}`, nil},
+		{"divisor of the old multipleOf", `{"multipleOf":10}`, `{"multipleOf":5}`, nil},
+		{"dropped x- keyword", `{"type":"string","x-min-duration":"1s"}`, `{"type":"string"}`, nil},
+
+		{"required property", base, `{"type":"object","properties":{"name":{"type":"string","maxLength":10},"mode":{"enum":["a","b"]},"port":{"type":"integer","minimum":1,"maximum":100},"tags":{"type":"array","items":{"type":"string"}}},"required":["name","port"]}`,
+			[]Violation{{"", "/required", `property "port" is now required`}}},
+		{"tightened constraints", base, `{"type":"object","properties":{"name":{"type":"string","maxLength":5,"pattern":"^[a-z]+$"},"mode":{"enum":["a"]},"port":{"type":"integer","minimum":10,"maximum":100},"tags":{"type":"array","items":{"type":"string"},"uniqueItems":true}},"required":["name"]}`,
+			[]Violation{
+				{"/mode", "/properties/mode/enum", `enum value "b" was removed`},
+				{"/name", "/properties/name/maxLength", "maxLength was lowered from 10 to 5"},
+				{"/name", "/properties/name/pattern", `pattern "^[a-z]+$" was added`},
+				{"/port", "/properties/port/minimum", "minimum was raised from 1 to 10"},
+				{"/tags", "/properties/tags/uniqueItems", "array items must now be unique"},
+			}},
+		{"narrower type", base, `{"type":"object","properties":{"name":{"type":"string","maxLength":10},"mode":{"enum":["a","b"]},"port":{"type":"integer","minimum":1,"maximum":100},"tags":{"type":"array","items":{"type":"integer"}}},"required":["name"]}`,
+			[]Violation{{"/tags/*", "/properties/tags/items/type", "type string is not allowed anymore"}}},
+		{"number to integer", `{"type":"number"}`, `{"type":"integer"}`, []Violation{{"", "/type", "type number is not allowed anymore"}}},
+		{"new bound", `{"type":"integer"}`, `{"type":"integer","maximum":5}`, []Violation{{"", "/maximum", "maximum 5 was added"}}},
+		{"removed property of a closed schema", `{"type":"object","properties":{"a":{},"b":{}},"additionalProperties":false}`, `{"type":"object","properties":{"a":{}},"additionalProperties":false}`,
+			[]Violation{{"/b", "/properties", `property "b" was removed and additional properties are not allowed`}}},
+		{"closed schema", `{"type":"object","properties":{"a":{}}}`, `{"type":"object","properties":{"a":{}},"additionalProperties":false}`,
+			[]Violation{{"", "/additionalProperties", "additional properties are not allowed anymore"}}},
+		{"constrained property of a typed map", `{"type":"object","additionalProperties":{"type":"string"}}`, `{"type":"object","properties":{"a":{"type":"integer"}},"additionalProperties":{"type":"string"}}`,
+			[]Violation{{"/a", "/properties/a/type", "type string is not allowed anymore"}}},
+		{"unknown keyword", `{"type":"object"}`, `{"type":"object","anyOf":[{"required":["a"]},{"required":["b"]}]}`,
+			[]Violation{{"", "/anyOf", "changes to anyOf cannot be checked for compatibility"}}},
+		{"new x- keyword", `{"type":"string"}`, `{"type":"string","x-min-duration":"1s"}`,
+			[]Violation{{"", "/x-min-duration", "changes to x-min-duration cannot be checked for compatibility"}}},
+		{"false schema", `{"type":"object","properties":{"a":{}}}`, `{"type":"object","properties":{"a":false}}`,
+			[]Violation{{"/a", "/properties/a", "no value is allowed anymore"}}},
+	}
+	for _, tt := range tests {
+		err := checkSchemaCompatibility(tt.old, tt.new)
+		if tt.violations == nil {
+			if err != nil {
+				t.Errorf("%s: expected a compatible change, got %v", tt.name, err)
+			}
+			continue
+		}
+
+		var ve *ValidationError
+		if !errors.As(err, &ve) || !errors.Is(err, ErrIncompatibleSchema) {
+			t.Errorf("%s: expected ErrIncompatibleSchema, got %v", tt.name, err)
+			continue
+		}
+		if len(ve.Violations) != len(tt.violations) {
+			t.Errorf("%s: expected %d violations, got %+v", tt.name, len(tt.violations), ve.Violations)
+			continue
+		}
+		for i, v := range ve.Violations {
+			if v != tt.violations[i] {
+				t.Errorf("%s: expected %+v, got %+v", tt.name, tt.violations[i], v)
+			}
+		}
+	}
+}
+
+func TestCheckSchemaChange_Force(t *testing.T) {
+	const current, breaking = `{"type":"object"}`, `{"type":"object","required":["a"]}`
+
+	if err := checkSchemaChange(current, breaking, false, "admin"); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("expected ErrIncompatibleSchema without force, got %v", err)
+	}
+	if err := checkSchemaChange(current, breaking, true, "user"); !errors.Is(err, ErrForceNotAllowed) {
+		t.Fatalf("expected ErrForceNotAllowed for a user, got %v", err)
+	}
+	if err := checkSchemaChange(current, breaking, true, "admin"); err != nil {
+		t.Fatalf("expected admins to force the change, got %v", err)
+	}
+	// Force never lets an unparsable schema through
+	if err := checkSchemaChange(current, `{`, true, "admin"); !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("expected ErrInvalidSchema, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package configdata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"sass.com/configsvc/internal/models"
+)
+
+// Cache-Control of config reads. Responses depend on the caller's grants, so
+// only private caches may keep them. Even single versions are revalidated: a
+// purged config may be created again, its versions starting over at 1.
+const cacheRevalidate = "private, no-cache"
+
+// etag is the strong entity tag of a config version: its number, so writes can
+// be made conditional on it, and a hash of its Input, e.g. "3-9f86d081884c7d65"
+func etag(version int, input string) string {
+	sum := sha256.Sum256([]byte(strconv.Itoa(version) + "\x00" + input))
+	return `"` + strconv.Itoa(version) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// listETag tags a page of versions by the version tags it contains
+func listETag(list *VersionList) string {
+	h := sha256.New()
+	for _, cfg := range list.Items {
+		h.Write([]byte(etag(cfg.Version, cfg.Input)))
+	}
+	h.Write([]byte(list.NextCursor))
+	return `"` + hex.EncodeToString(h.Sum(nil)[:8]) + `"`
+}
+
+// notModified sets the caching headers of a read and reports whether the
+// client's copy is still current, in which case it has answered 304.
+// If-None-Match takes precedence over If-Modified-Since.
+func notModified(c *gin.Context, tag string, modified time.Time, cacheControl string) bool {
+	c.Header("ETag", tag)
+	c.Header("Cache-Control", cacheControl)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if !etagListMatches(inm, tag) {
+			return false
+		}
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+		// Last-Modified only has second precision
+		if err != nil || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagListMatches compares an If-None-Match list weakly against tag
+func etagListMatches(list, tag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// lastModified is the newest UpdatedAt of a page of versions
+func lastModified(cfgs []models.Configurations) time.Time {
+	var latest time.Time
+	for _, cfg := range cfgs {
+		if cfg.UpdatedAt.After(latest) {
+			latest = cfg.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// expectedVersion returns the version a write is conditional on, taken from
+// the If-Match header or else from the request's expected_version. It is 0
+// for unconditional writes. field is the body's expected_version if any.
+func expectedVersion(c *gin.Context, field *int) (int, bool) {
+	if ifMatch := strings.TrimSpace(c.GetHeader("If-Match")); ifMatch != "" {
+		if ifMatch == "*" {
+			return 0, true
+		}
+		// If-Match compares strongly, weak tags and lists never match a single version
+		version, err := parseETag(ifMatch)
+		if err != nil {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": ErrVersionConflict.Error()})
+			return 0, false
+		}
+		return version, true
+	}
+
+	if field == nil {
+		v := c.Query("expected_version")
+		if v == "" {
+			return 0, true
+		}
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expected_version"})
+			return 0, false
+		}
+		field = &version
+	}
+	if *field < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expected_version"})
+		return 0, false
+	}
+	return *field, true
+}
+
+// parseETag returns the version number of an etag, older tags carry only the number
+func parseETag(tag string) (int, error) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, ErrVersionConflict
+	}
+	number, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+	version, err := strconv.Atoi(number)
+	if err != nil || version < 1 {
+		return 0, ErrVersionConflict
+	}
+	return version, nil
+}
+
+// forceFlag reads the force flag of a write, from the body or ?force=
+func forceFlag(c *gin.Context, field bool) (bool, bool) {
+	v := c.Query("force")
+	if field || v == "" {
+		return field, true
+	}
+	force, err := strconv.ParseBool(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid force"})
+		return false, false
+	}
+	return force, true
+}
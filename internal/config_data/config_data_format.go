@@ -0,0 +1,401 @@
+package configdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a rendering of the Input of a config. The empty format is the
+// default response, the whole config as JSON.
+type Format string
+
+const (
+	FormatJSON       Format = "json"
+	FormatYAML       Format = "yaml"
+	FormatTOML       Format = "toml"
+	FormatEnv        Format = "env"
+	FormatProperties Format = "properties"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown format, expected json, yaml, toml, env or properties")
+	ErrNotRenderable = errors.New("input cannot be rendered in this format")
+)
+
+var formatContentTypes = map[Format]string{
+	FormatJSON:       "application/json; charset=utf-8",
+	FormatYAML:       "application/yaml; charset=utf-8",
+	FormatTOML:       "application/toml; charset=utf-8",
+	FormatEnv:        "text/x-dotenv; charset=utf-8",
+	FormatProperties: "text/x-java-properties; charset=utf-8",
+}
+
+// mediaTypeFormats are the Accept media types that select a rendering.
+// application/json selects the default response, not the bare Input.
+var mediaTypeFormats = map[string]Format{
+	"application/json":       "",
+	"application/yaml":       FormatYAML,
+	"application/x-yaml":     FormatYAML,
+	"text/yaml":              FormatYAML,
+	"application/toml":       FormatTOML,
+	"text/x-dotenv":          FormatEnv,
+	"application/x-dotenv":   FormatEnv,
+	"text/x-java-properties": FormatProperties,
+}
+
+// negotiateFormat picks the rendering of a config read. ?format= wins over
+// the Accept header. Accept types are tried by quality then order, and
+// without a known one the default response is sent.
+func negotiateFormat(c *gin.Context) (Format, error) {
+	if v := c.Query("format"); v != "" {
+		f := Format(strings.ToLower(v))
+		if _, ok := formatContentTypes[f]; !ok {
+			return "", ErrUnknownFormat
+		}
+		return f, nil
+	}
+
+	best, bestQ := Format(""), 0.0
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		f, ok := mediaTypeFormats[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best, nil
+}
+
+// renderInput renders the Input document of a config in f.
+//
+// Object keys are sorted at every level, so a version always renders to the
+// same bytes. TOML, env and properties need an object at the
+// top. env and properties flatten nested values into one line per scalar:
+//
+//	{"db": {"host": "x", "ports": [1, 2]}, "log-level": "debug"}
+//
+//	env:        DB_HOST=x, DB_PORTS_0=1, DB_PORTS_1=2, LOG_LEVEL=debug
+//	properties: db.host=x, db.ports[0]=1, db.ports[1]=2, log-level=debug
+//
+// env names are the path joined with "_", upper-cased, with every character
+// other than A-Z, 0-9 and "_" replaced by "_", and a leading digit prefixed
+// with "_". Paths that end up with the
+// same name are rejected. Empty objects and arrays are left out; null is
+// written as an empty value, and left out of TOML, which has no null.
+func renderInput(input string, f Format) ([]byte, error) {
+	doc, err := decodeInput(input)
+	if err != nil {
+		return nil, err
+	}
+	if f == FormatJSON {
+		return json.Marshal(doc)
+	}
+
+	_, isObject := doc.(map[string]interface{})
+	if f != FormatYAML && !isObject {
+		return nil, fmt.Errorf("%w: %s needs an object at the top level", ErrNotRenderable, f)
+	}
+
+	switch f {
+	case FormatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(nativeNumbers(doc)); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		if path, ok := nullInArray(doc, ""); ok {
+			return nil, fmt.Errorf("%w: toml arrays cannot hold null, at %s", ErrNotRenderable, path)
+		}
+		return toml.Marshal(nativeNumbers(doc))
+	case FormatEnv:
+		return renderFlat(doc, envKey, envValue)
+	case FormatProperties:
+		return renderFlat(doc, propertiesKey, propertiesValue)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// decodeInput keeps numbers as written, json.Number, so env and properties
+// render them exactly
+func decodeInput(input string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// nativeNumbers replaces json.Number, which YAML and TOML would write as a
+// string, by int64 or float64. Numbers neither can hold stay strings.
+func nativeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = nativeNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = nativeNumbers(child)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil && !math.IsInf(f, 0) {
+			return f
+		}
+		return v.String()
+	}
+	return v
+}
+
+// nullInArray returns the path of the first null array element
+func nullInArray(v interface{}, path string) (string, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			if p, ok := nullInArray(v[k], path+"/"+k); ok {
+				return p, true
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			p := path + "/" + strconv.Itoa(i)
+			if child == nil {
+				return p, true
+			}
+			if p, ok := nullInArray(child, p); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// flatKey appends a segment to a flattened key, index is set for array elements
+type flatKey func(prefix, segment string, index bool) string
+
+// renderFlat writes one key=value line per scalar of doc, depth first
+func renderFlat(doc interface{}, key flatKey, value func(interface{}) string) ([]byte, error) {
+	var buf bytes.Buffer
+	seen := map[string]bool{}
+
+	var walk func(prefix string, v interface{}) error
+	walk = func(prefix string, v interface{}) error {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for _, k := range sortedKeys(v) {
+				if err := walk(key(prefix, k, false), v[k]); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for i, child := range v {
+				if err := walk(key(prefix, strconv.Itoa(i), true), child); err != nil {
+					return err
+				}
+			}
+		default:
+			if prefix == "" {
+				return fmt.Errorf("%w: empty key", ErrNotRenderable)
+			}
+			if seen[prefix] {
+				return fmt.Errorf("%w: more than one value for key %s", ErrNotRenderable, prefix)
+			}
+			seen[prefix] = true
+			buf.WriteString(prefix)
+			buf.WriteByte('=')
+			buf.WriteString(value(v))
+			buf.WriteByte('\n')
+		}
+		return nil
+	}
+
+	if err := walk("", doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var envNameUnsafe = regexp.MustCompile(`[^A-Z0-9_]`)
+
+func envKey(prefix, segment string, _ bool) string {
+	segment = envNameUnsafe.ReplaceAllString(strings.ToUpper(segment), "_")
+	if prefix == "" {
+		if segment != "" && segment[0] >= '0' && segment[0] <= '9' {
+			return "_" + segment
+		}
+		return segment
+	}
+	return prefix + "_" + segment
+}
+
+// envValue quotes values that a shell or dotenv parser would otherwise split or expand
+func envValue(v interface{}) string {
+	s := scalarString(v)
+	if s != "" && !strings.ContainsAny(s, " \t\r\n\"'`\\$#=") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `\$`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+func propertiesKey(prefix, segment string, index bool) string {
+	if index {
+		return prefix + "[" + segment + "]"
+	}
+	segment = escapeProperties(segment, true)
+	if prefix == "" {
+		return segment
+	}
+	return prefix + "." + segment
+}
+
+func propertiesValue(v interface{}) string {
+	return escapeProperties(scalarString(v), false)
+}
+
+// escapeProperties escapes s as java.util.Properties reads it, writing
+// everything outside printable ASCII as \uXXXX
+func escapeProperties(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case key && (r == '=' || r == ':'), (key || i == 0) && (r == '#' || r == '!'):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			var units [2]uint16
+			n := 1
+			if r > 0xffff {
+				units[0], units[1] = utf16Pair(r)
+				n = 2
+			} else {
+				units[0] = uint16(r)
+			}
+			for _, u := range units[:n] {
+				fmt.Fprintf(&b, `\u%04x`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func utf16Pair(r rune) (uint16, uint16) {
+	r -= 0x10000
+	return uint16(0xd800 + (r>>10)&0x3ff), uint16(0xdc00 + r&0x3ff)
+}
+
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if !utf8.ValidString(v) {
+			return strings.ToValidUTF8(v, "�")
+		}
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeConfig answers a read of a single config version in the format the
+// client asked for, or 304 when its copy is current
+func writeConfig(c *gin.Context, version int, input string, cfg interface{}, modified time.Time, cacheControl string) {
+	// The response depends on Accept, shared caches must key on it
+	c.Header("Vary", "Accept")
+	f, err := negotiateFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := etag(version, input)
+	if f == "" {
+		if notModified(c, tag, modified, cacheControl) {
+			return
+		}
+		c.JSON(http.StatusOK, cfg)
+		return
+	}
+
+	body, err := renderInput(input, f)
+	if err != nil {
+		if errors.Is(err, ErrNotRenderable) {
+			c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Println("failed to render config input:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	// Every representation has its own tag, the version number stays first for If-Match
+	if notModified(c, strings.TrimSuffix(tag, `"`)+"-"+string(f)+`"`, modified, cacheControl) {
+		return
+	}
+	c.Header("X-Config-Version", strconv.Itoa(version))
+	c.Data(http.StatusOK, formatContentTypes[f], body)
+}
@@ -0,0 +1,215 @@
+package configdata
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sass.com/configsvc/internal/models"
+)
+
+const formatInput = `{"log-level":"debug","db":{"host":"db.local","ports":[5432,5433],"opts":{"ssl":true,"timeout":1.5}},"big":12345678901234567890,"note":"a b#c","empty":{},"none":null}`
+
+func TestRenderInput(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatJSON, `{"big":12345678901234567890,"db":{"host":"db.local","opts":{"ssl":true,"timeout":1.5},"ports":[5432,5433]},"empty":{},"log-level":"debug","none":null,"note":"a b#c"}`},
+		{FormatYAML, `big: 1.2345678901234567e+19
+db:
+  host: db.local
+  opts:
+    ssl: true
+    timeout: 1.5
+  ports:
+    - 5432
+    - 5433
+empty: {}
+log-level: debug
+none: null
+note: a b#c
+`},
+		{FormatTOML, `big = 12345678901234567168.0
+log-level = 'debug'
+note = 'a b#c'
+
+[db]
+host = 'db.local'
+ports = [5432, 5433]
+
+[db.opts]
+ssl = true
+timeout = 1.5
+
+[empty]
+`},
+		{FormatEnv, `BIG=12345678901234567890
+DB_HOST=db.local
+DB_OPTS_SSL=true
+DB_OPTS_TIMEOUT=1.5
+DB_PORTS_0=5432
+DB_PORTS_1=5433
+LOG_LEVEL=debug
+NONE=""
+NOTE="a b#c"
+`},
+		{FormatProperties, `big=12345678901234567890
+db.host=db.local
+db.opts.ssl=true
+db.opts.timeout=1.5
+db.ports[0]=5432
+db.ports[1]=5433
+log-level=debug
+none=
+note=a b#c
+`},
+	}
+	for _, tt := range tests {
+		got, err := renderInput(formatInput, tt.format)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.format, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.format, tt.want, got)
+		}
+	}
+}
+
+func TestRenderInput_Escaping(t *testing.T) {
+	input := `{"key with=sign":" lead\nnaïve $HOME","1st":"x"}`
+
+	got, err := renderInput(input, FormatProperties)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "1st=x\nkey\\ with\\=sign=\\ lead\\nna\\u00efve $HOME\n"
+	if string(got) != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	got, err = renderInput(input, FormatEnv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "_1ST=x\nKEY_WITH_SIGN=\" lead\\nnaïve \\$HOME\"\n"
+	if string(got) != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRenderInput_NotRenderable(t *testing.T) {
+	tests := []struct {
+		input  string
+		format Format
+	}{
+		{`[1,2]`, FormatEnv},
+		{`"text"`, FormatProperties},
+		{`5`, FormatTOML},
+		{`{"a":[1,null]}`, FormatTOML},
+		{`{"a.b":1,"a_b":2}`, FormatEnv},
+		{`{"":1}`, FormatProperties},
+	}
+	for _, tt := range tests {
+		if _, err := renderInput(tt.input, tt.format); !errors.Is(err, ErrNotRenderable) {
+			t.Errorf("%s as %s: expected ErrNotRenderable, got %v", tt.input, tt.format, err)
+		}
+	}
+
+	// YAML and JSON take any document
+	if got, err := renderInput(`[1,"a"]`, FormatYAML); err != nil || string(got) != "- 1\n- a\n" {
+		t.Errorf("unexpected yaml %q, %v", got, err)
+	}
+}
+
+func TestConfigHandler_GetLastVersionByName_Formats(t *testing.T) {
+	cfg := &models.LastConfigurations{Name: "app", Version: 3, Input: `{"db":{"host":"x"}}`}
+	h := NewConfigHandler(&mockConfigService{lastCfg: cfg})
+	r := setupGin()
+	r.GET("/configs/:name/latest", h.GetLastVersionByName)
+
+	tests := []struct {
+		query, accept string
+		status        int
+		contentType   string
+		body          string
+	}{
+		{"", "", http.StatusOK, "application/json", `"Name":"app"`},
+		{"", "application/json, application/yaml;q=0.5", http.StatusOK, "application/json", `"Name":"app"`},
+		{"", "text/html, application/yaml;q=0.9, application/json;q=0.8", http.StatusOK, "application/yaml", "db:\n  host: x\n"},
+		{"", "application/toml", http.StatusOK, "application/toml", "[db]\nhost = 'x'\n"},
+		{"?format=json", "", http.StatusOK, "application/json", `{"db":{"host":"x"}}`},
+		{"?format=env", "application/yaml", http.StatusOK, "text/x-dotenv", "DB_HOST=x\n"},
+		{"?format=PROPERTIES", "", http.StatusOK, "text/x-java-properties", "db.host=x\n"},
+		{"?format=xml", "", http.StatusBadRequest, "application/json", "unknown format"},
+	}
+	tags := map[string]bool{}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/configs/app/latest"+tt.query, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.query, tt.accept, tt.status, w.Code, w.Body.String())
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+			t.Errorf("%s %s: expected content type %s, got %s", tt.query, tt.accept, tt.contentType, got)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s %s: expected body with %q, got %q", tt.query, tt.accept, tt.body, w.Body.String())
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s %s: expected Vary: Accept", tt.query, tt.accept)
+		}
+		if tag := w.Header().Get("ETag"); tag != "" {
+			if !strings.HasPrefix(tag, `"3-`) {
+				t.Errorf("%s %s: expected the version first in ETag, got %s", tt.query, tt.accept, tag)
+			}
+			tags[tag] = true
+		}
+	}
+	// the default response, yaml, toml, json, env and properties
+	if len(tags) != 6 {
+		t.Errorf("expected a distinct ETag per representation, got %v", tags)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/app/latest?format=yaml", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	req = httptest.NewRequest(http.MethodGet, "/configs/app/latest?format=yaml", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for the yaml ETag, got %d", w.Code)
+	}
+}
+
+func TestConfigHandler_GetConfigByNameByVersion_NotAcceptable(t *testing.T) {
+	cfg := &models.Configurations{Name: "app", Version: 1, Input: `["a","b"]`}
+	h := NewConfigHandler(&mockConfigService{byVerCfg: cfg})
+	r := setupGin()
+	r.GET("/configs/:name/versions/:version", h.GetConfigByNameByVersion)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/app/versions/1?format=env", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected 406, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/configs/app/versions/1", nil)
+	req.Header.Set("Accept", "application/yaml")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "- a\n- b\n" {
+		t.Errorf("unexpected response %d: %q", w.Code, w.Body.String())
+	}
+}
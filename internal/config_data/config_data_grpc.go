@@ -0,0 +1,314 @@
+package configdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"sass.com/configsvc/internal/auth"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
+	"sass.com/configsvc/internal/policy"
+	"sass.com/configsvc/pkg/configpb"
+)
+
+// ConfigGRPCServer serves the config endpoints over gRPC. Callers are
+// authenticated by the auth interceptors and authorized here like
+// policy.RequireConfigAccess authorizes the REST routes.
+type ConfigGRPCServer struct {
+	configpb.UnimplementedConfigServiceServer
+	service ConfigService
+	policy  policy.PolicyService
+}
+
+func NewConfigGRPCServer(service ConfigService, policyService policy.PolicyService) *ConfigGRPCServer {
+	return &ConfigGRPCServer{service: service, policy: policyService}
+}
+
+func (s *ConfigGRPCServer) Create(ctx context.Context, req *configpb.CreateRequest) (*configpb.Config, error) {
+	id, err := s.authorize(ctx, policy.ActionWrite, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	schema, input, err := canonicalConfig(req.GetSchema(), req.GetInput())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if err := validateInput(id.ClientID, schema, input); err != nil {
+		return nil, grpcError(err)
+	}
+
+	existing, _ := s.service.GetLastVersionByName(id.ClientID, req.GetName())
+	if existing != nil {
+		return nil, status.Error(codes.AlreadyExists, "config already exists")
+	}
+
+	cfg := &models.Configurations{
+		ID:        uuid.New(),
+		ClientID:  id.ClientID,
+		Name:      req.GetName(),
+		Type:      models.Type(req.GetType()),
+		Schema:    schema,
+		Input:     input,
+		CreatedBy: id.UserID,
+		Version:   1,
+		IsActive:  1,
+	}
+	if err := s.service.Create(cfg); err != nil {
+		if errors.Is(err, ErrConfigDeleted) {
+			return nil, status.Error(codes.Aborted, "config is deleted, restore it instead")
+		}
+		return nil, grpcError(err)
+	}
+	return configToProto(cfg), nil
+}
+
+func (s *ConfigGRPCServer) Update(ctx context.Context, req *configpb.UpdateRequest) (*configpb.Config, error) {
+	id, err := s.authorize(ctx, policy.ActionWrite, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	schema, input, err := canonicalConfig(req.GetSchema(), req.GetInput())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if err := validateInput(id.ClientID, schema, input); err != nil {
+		return nil, grpcError(err)
+	}
+
+	cfg := &models.Configurations{
+		ClientID:  id.ClientID,
+		Name:      req.GetName(),
+		Type:      models.Type(req.GetType()),
+		Schema:    schema,
+		Input:     input,
+		CreatedBy: id.UserID,
+		IsActive:  1,
+	}
+	lastCfg, err := writeSchemaChecked(s.service, id.ClientID, req.GetName(), cfg.Schema, req.GetForce(), id.Role, int(req.GetExpectedVersion()),
+		func(expected int) error { return s.service.CreateVersion(cfg, expected) })
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if req.GetForce() && !equalSchemas(lastCfg.Schema, cfg.Schema) {
+		fmt.Println("schema change of", req.GetName(), "forced by", id.UserID)
+	}
+	return configToProto(cfg), nil
+}
+
+func (s *ConfigGRPCServer) Rollback(ctx context.Context, req *configpb.RollbackRequest) (*configpb.Config, error) {
+	id, err := s.authorize(ctx, policy.ActionPublish, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.service.GetByNameByVersion(id.ClientID, req.GetName(), int(req.GetVersion()))
+	if err != nil || cfg == nil {
+		return nil, status.Error(codes.NotFound, ErrVersionNotFound.Error())
+	}
+	cfg.CreatedBy = id.UserID
+
+	latest, err := writeSchemaChecked(s.service, id.ClientID, req.GetName(), cfg.Schema, req.GetForce(), id.Role, int(req.GetExpectedVersion()),
+		func(expected int) error { return s.service.RollbackConfig(cfg, expected) })
+	if errors.Is(err, ErrConfigDeleted) {
+		return nil, status.Error(codes.NotFound, ErrConfigNotFound.Error())
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if req.GetForce() && !equalSchemas(latest.Schema, cfg.Schema) {
+		fmt.Println("schema change of", req.GetName(), "forced by", id.UserID, "on rollback")
+	}
+	return configToProto(cfg), nil
+}
+
+func (s *ConfigGRPCServer) GetLatest(ctx context.Context, req *configpb.GetLatestRequest) (*configpb.Config, error) {
+	id, err := s.authorize(ctx, policy.ActionRead, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.service.GetLastVersionByName(id.ClientID, req.GetName())
+	if err != nil || cfg == nil {
+		return nil, status.Error(codes.NotFound, ErrConfigNotFound.Error())
+	}
+	return lastConfigToProto(cfg), nil
+}
+
+func (s *ConfigGRPCServer) GetVersion(ctx context.Context, req *configpb.GetVersionRequest) (*configpb.Config, error) {
+	id, err := s.authorize(ctx, policy.ActionRead, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.service.GetByNameByVersion(id.ClientID, req.GetName(), int(req.GetVersion()))
+	if err != nil || cfg == nil {
+		return nil, status.Error(codes.NotFound, ErrVersionNotFound.Error())
+	}
+	return configToProto(cfg), nil
+}
+
+func (s *ConfigGRPCServer) ListVersions(ctx context.Context, req *configpb.ListVersionsRequest) (*configpb.ListVersionsResponse, error) {
+	id, err := s.authorize(ctx, policy.ActionRead, req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+
+	list, err := s.service.GetConfigVersions(id.ClientID, req.GetName(), int(req.GetLimit()), req.GetCursor())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &configpb.ListVersionsResponse{NextCursor: list.NextCursor}
+	for i := range list.Items {
+		resp.Items = append(resp.Items, configToProto(&list.Items[i]))
+	}
+	return resp, nil
+}
+
+// Watch streams every new version of a config until the client goes away
+func (s *ConfigGRPCServer) Watch(req *configpb.WatchRequest, stream configpb.ConfigService_WatchServer) error {
+	ctx := stream.Context()
+	id, err := s.authorize(ctx, policy.ActionRead, req.GetName())
+	if err != nil {
+		return err
+	}
+	if req.GetAfterVersion() < 0 {
+		return status.Error(codes.InvalidArgument, "invalid after_version")
+	}
+
+	after := int(req.GetAfterVersion())
+	for {
+		cfg, err := s.service.WatchConfig(ctx, id.ClientID, req.GetName(), after)
+		if err != nil {
+			return grpcError(err)
+		}
+		if cfg == nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		if err := stream.Send(lastConfigToProto(cfg)); err != nil {
+			return err
+		}
+		after = cfg.Version
+	}
+}
+
+// authorize returns the caller when it may perform action on the named config
+func (s *ConfigGRPCServer) authorize(ctx context.Context, action policy.Action, name string) (auth.Identity, error) {
+	id, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return auth.Identity{}, status.Error(codes.Unauthenticated, "user not found")
+	}
+	if name == "" {
+		return auth.Identity{}, status.Error(codes.InvalidArgument, "config name is required")
+	}
+
+	allowed, err := s.policy.Authorize(id.UserID, id.Role, name, action)
+	if err != nil {
+		fmt.Println("failed to authorize request:", err)
+		return auth.Identity{}, status.Error(codes.Internal, "internal server error")
+	}
+	if !allowed {
+		return auth.Identity{}, status.Error(codes.PermissionDenied, "you are not authorized")
+	}
+	return id, nil
+}
+
+// canonicalConfig rewrites a schema and input as NormalizeConfigBody does
+// for REST, so both store the same bytes and etag
+func canonicalConfig(schema, input string) (string, string, error) {
+	schema, err := canonicalDocument("schema", schema)
+	if err != nil {
+		return "", "", err
+	}
+	input, err = canonicalDocument("input", input)
+	if err != nil {
+		return "", "", err
+	}
+	return schema, input, nil
+}
+
+// grpcError is writeConfigError for gRPC
+func grpcError(err error) error {
+	var ve *ValidationError
+	var perr *ParseError
+	switch {
+	case errors.As(err, &ve):
+		return validationStatus(ve)
+	case errors.As(err, &perr):
+		return status.Error(codes.InvalidArgument, perr.Error())
+	case errors.Is(err, ErrConfigNotFound), errors.Is(err, ErrVersionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrConfigDeleted), errors.Is(err, ErrConfigNotDeleted), errors.Is(err, ErrWriteContended):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, ErrInvalidCursor), errors.Is(err, ErrInputMismatch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrVersionConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrForceNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, notify.ErrTooManyWaiters):
+		return status.Error(codes.ResourceExhausted, "too many watchers, retry later")
+	default:
+		fmt.Println("config service error:", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// validationStatus carries the violations as BadRequest details, one field
+// violation per instance path
+func validationStatus(ve *ValidationError) error {
+	st := status.New(codes.InvalidArgument, ve.Error())
+	details := &errdetails.BadRequest{}
+	for _, v := range ve.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.InstancePath,
+			Description: v.Message + " (" + v.KeywordPath + ")",
+		})
+	}
+	if withDetails, err := st.WithDetails(details); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+func configToProto(cfg *models.Configurations) *configpb.Config {
+	return &configpb.Config{
+		Id:            cfg.ID.String(),
+		Name:          cfg.Name,
+		Type:          string(cfg.Type),
+		Schema:        cfg.Schema,
+		Input:         cfg.Input,
+		Version:       int32(cfg.Version),
+		CreatedBy:     cfg.CreatedBy,
+		CreatedAt:     timestamppb.New(cfg.CreatedAt),
+		UpdatedAt:     timestamppb.New(cfg.UpdatedAt),
+		Active:        cfg.IsActive == 1,
+		Etag:          etag(cfg.Version, cfg.Input),
+		SchemaVersion: int32(cfg.SchemaVersion),
+	}
+}
+
+func lastConfigToProto(cfg *models.LastConfigurations) *configpb.Config {
+	return &configpb.Config{
+		Id:            cfg.ID.String(),
+		Name:          cfg.Name,
+		Type:          string(cfg.Type),
+		Schema:        cfg.Schema,
+		Input:         cfg.Input,
+		Version:       int32(cfg.Version),
+		CreatedBy:     cfg.CreatedBy,
+		CreatedAt:     timestamppb.New(cfg.CreatedAt),
+		UpdatedAt:     timestamppb.New(cfg.UpdatedAt),
+		Active:        cfg.IsActive == 1,
+		Etag:          etag(cfg.Version, cfg.Input),
+		SchemaVersion: int32(cfg.SchemaVersion),
+	}
+}
@@ -0,0 +1,250 @@
+package configdata
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"sass.com/configsvc/internal/auth"
+	"sass.com/configsvc/internal/notify"
+	"sass.com/configsvc/internal/policy"
+	"sass.com/configsvc/pkg/configpb"
+)
+
+const grpcTestSchema = `{"type":"object","properties":{"enabled":{"type":"boolean"}},"required":["enabled"]}`
+
+// stubPolicy allows every action on configs named allowed
+type stubPolicy struct {
+	policy.PolicyService
+	allowed string
+}
+
+func (p *stubPolicy) Authorize(userID, role, name string, action policy.Action) (bool, error) {
+	return name == p.allowed, nil
+}
+
+// setupGRPC serves the config service over an in-memory listener. Calls are
+// made as bob of the test tenant, standing in for the auth interceptors.
+func setupGRPC(t *testing.T, allowed string) configpb.ConfigServiceClient {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+	id := auth.Identity{UserID: "bob", Role: "user", ClientID: testClientID}
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(auth.WithIdentity(ctx, id), req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(srv, &identityServerStream{ServerStream: ss, ctx: auth.WithIdentity(ss.Context(), id)})
+		}),
+	)
+	configpb.RegisterConfigServiceServer(srv, NewConfigGRPCServer(svc, &stubPolicy{allowed: allowed}))
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return configpb.NewConfigServiceClient(conn)
+}
+
+type identityServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestConfigGRPC_Lifecycle(t *testing.T) {
+	client := setupGRPC(t, "grpc.flags")
+	ctx := context.Background()
+
+	created, err := client.Create(ctx, &configpb.CreateRequest{Name: "grpc.flags", Type: "object", Schema: grpcTestSchema, Input: `{"enabled":true}`})
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if created.Version != 1 || created.CreatedBy != "bob" || created.Etag == "" {
+		t.Fatalf("unexpected config: %+v", created)
+	}
+	_, err = client.Create(ctx, &configpb.CreateRequest{Name: "grpc.flags", Schema: grpcTestSchema, Input: `{"enabled":true}`})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+
+	updated, err := client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.flags", Schema: grpcTestSchema, Input: `{"enabled":false}`, ExpectedVersion: 1})
+	if err != nil || updated.Version != 2 || updated.SchemaVersion != 1 {
+		t.Fatalf("expected version 2 of schema version 1, got %+v, %v", updated, err)
+	}
+	_, err = client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.flags", Schema: grpcTestSchema, Input: `{"enabled":true}`, ExpectedVersion: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+	breaking := `{"type":"object","properties":{"enabled":{"type":"boolean"},"limit":{"type":"integer"}},"required":["enabled","limit"]}`
+	_, err = client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.flags", Schema: breaking, Input: `{"enabled":true,"limit":1}`})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a breaking schema change, got %v", err)
+	}
+	_, err = client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.flags", Schema: breaking, Input: `{"enabled":true,"limit":1}`, Force: true})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for a forced change by a user, got %v", err)
+	}
+
+	rolledBack, err := client.Rollback(ctx, &configpb.RollbackRequest{Name: "grpc.flags", Version: 1})
+	if err != nil || rolledBack.Version != 3 || rolledBack.Input != `{"enabled":true}` {
+		t.Fatalf("expected version 1 as version 3, got %+v, %v", rolledBack, err)
+	}
+
+	latest, err := client.GetLatest(ctx, &configpb.GetLatestRequest{Name: "grpc.flags"})
+	if err != nil || latest.Version != 3 || latest.Etag != rolledBack.Etag {
+		t.Fatalf("expected latest version 3, got %+v, %v", latest, err)
+	}
+	v2, err := client.GetVersion(ctx, &configpb.GetVersionRequest{Name: "grpc.flags", Version: 2})
+	if err != nil || v2.Input != `{"enabled":false}` {
+		t.Fatalf("unexpected version 2: %+v, %v", v2, err)
+	}
+	if _, err := client.GetVersion(ctx, &configpb.GetVersionRequest{Name: "grpc.flags", Version: 9}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	page, err := client.ListVersions(ctx, &configpb.ListVersionsRequest{Name: "grpc.flags", Limit: 2})
+	if err != nil || len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected a first page of 2, got %+v, %v", page, err)
+	}
+	page, err = client.ListVersions(ctx, &configpb.ListVersionsRequest{Name: "grpc.flags", Limit: 2, Cursor: page.NextCursor})
+	if err != nil || len(page.Items) != 1 {
+		t.Fatalf("expected a last page of 1, got %+v, %v", page, err)
+	}
+}
+
+func TestConfigGRPC_Watch(t *testing.T) {
+	client := setupGRPC(t, "grpc.watched")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.Create(ctx, &configpb.CreateRequest{Name: "grpc.watched", Schema: grpcTestSchema, Input: `{"enabled":true}`}); err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	stream, err := client.Watch(ctx, &configpb.WatchRequest{Name: "grpc.watched"})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	first, err := stream.Recv()
+	if err != nil || first.Version != 1 {
+		t.Fatalf("expected the current version first, got %+v, %v", first, err)
+	}
+
+	if _, err := client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.watched", Schema: grpcTestSchema, Input: `{"enabled":false}`}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	next, err := stream.Recv()
+	if err != nil || next.Version != 2 || next.Input != `{"enabled":false}` {
+		t.Fatalf("expected version 2 to be streamed, got %+v, %v", next, err)
+	}
+}
+
+func TestConfigGRPC_RollbackSchemaChange(t *testing.T) {
+	client := setupGRPC(t, "grpc.rolled")
+	ctx := context.Background()
+
+	if _, err := client.Create(ctx, &configpb.CreateRequest{Name: "grpc.rolled", Schema: grpcTestSchema, Input: `{"enabled":true}`}); err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	relaxed := `{"type":"object","properties":{"enabled":{"type":"boolean"}}}`
+	if _, err := client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.rolled", Schema: relaxed, Input: `{}`}); err != nil {
+		t.Fatalf("failed to relax the schema: %v", err)
+	}
+
+	// Version 1 brings back a schema that requires enabled again
+	_, err := client.Rollback(ctx, &configpb.RollbackRequest{Name: "grpc.rolled", Version: 1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a breaking rollback, got %v", err)
+	}
+	_, err = client.Rollback(ctx, &configpb.RollbackRequest{Name: "grpc.rolled", Version: 1, Force: true})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for a forced rollback by a user, got %v", err)
+	}
+	rolledBack, err := client.Rollback(ctx, &configpb.RollbackRequest{Name: "grpc.rolled", Version: 2})
+	if err != nil || rolledBack.Version != 3 {
+		t.Fatalf("expected version 2 as version 3, got %+v, %v", rolledBack, err)
+	}
+}
+
+func TestConfigGRPC_CanonicalDocuments(t *testing.T) {
+	client := setupGRPC(t, "grpc.canonical")
+	ctx := context.Background()
+
+	schema := `{ "required": ["enabled"], "type": "object" }`
+	input := `{ "limit": 1.50, "enabled": true }`
+	body, err := normalizeConfigBody("application/json", []byte(`{"schema":`+strconv.Quote(schema)+`,"input":`+strconv.Quote(input)+`}`))
+	if err != nil {
+		t.Fatalf("failed to normalize: %v", err)
+	}
+	var rest struct{ Schema, Input string }
+	if err := json.Unmarshal(body, &rest); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+
+	created, err := client.Create(ctx, &configpb.CreateRequest{Name: "grpc.canonical", Schema: schema, Input: input})
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if created.Schema != rest.Schema || created.Input != `{"enabled":true,"limit":1.50}` || created.Etag != etag(1, rest.Input) {
+		t.Fatalf("expected the documents stored as over REST, got %+v", created)
+	}
+	updated, err := client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.canonical", Schema: schema, Input: "{\n  \"enabled\": false\n}"})
+	if err != nil || updated.Input != `{"enabled":false}` {
+		t.Fatalf("expected a canonical input, got %+v, %v", updated, err)
+	}
+	_, err = client.Update(ctx, &configpb.UpdateRequest{Name: "grpc.canonical", Schema: schema, Input: `{"enabled":`})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "invalid input") {
+		t.Fatalf("expected InvalidArgument for a broken input, got %v", err)
+	}
+}
+
+func TestConfigGRPC_PermissionDenied(t *testing.T) {
+	client := setupGRPC(t, "grpc.allowed")
+	ctx := context.Background()
+
+	_, err := client.GetLatest(ctx, &configpb.GetLatestRequest{Name: "grpc.secret"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+	stream, err := client.Watch(ctx, &configpb.WatchRequest{Name: "grpc.secret"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+	_, err = client.Create(ctx, &configpb.CreateRequest{Name: "grpc.allowed", Schema: `{"type":`, Input: `{}`})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a broken schema, got %v", err)
+	}
+	_, err = client.Create(ctx, &configpb.CreateRequest{Name: "grpc.allowed", Schema: `{"required":["a","b"]}`, Input: `{}`})
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			violations = br.GetFieldViolations()
+		}
+	}
+	if status.Code(err) != codes.InvalidArgument || len(violations) != 1 || !strings.Contains(violations[0].GetDescription(), "'a', 'b'") {
+		t.Fatalf("expected the violation in the details, got %v", err)
+	}
+}
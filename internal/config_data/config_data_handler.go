@@ -1,13 +1,27 @@
 package configdata
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
+)
+
+const (
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 2 * time.Minute
+
+	// An idle event stream sends a comment this often so proxies keep it open
+	eventHeartbeat = 15 * time.Second
 )
 
 type ConfigHandler struct {
@@ -21,34 +35,14 @@ func NewConfigHandler(service ConfigService) *ConfigHandler {
 func (h *ConfigHandler) CreateConfig(c *gin.Context) {
 	var newCfg models.Configurations
 
-	if err := c.ShouldBindJSON(&newCfg); err != nil {
-		fmt.Println("Bind error:", err)
-		fmt.Println("Raw body:", newCfg)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+	if err := bindConfig(c, &newCfg); err != nil {
+		writeBodyError(c, err)
 		return
 	}
 
 	// Reject invalid input and schema pair
-	if !isValidInput(newCfg.Schema, newCfg.Input) {
-		fmt.Println("Invalid schema input pair")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
-		return
-	}
-
-	// Only Admin is allowed to create config
-	roleVal, exists := c.Get("role")
-	if !exists {
-		// key not set
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "role not found"})
-		return
-	}
-	role, ok := roleVal.(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid role type"})
-		return
-	}
-	if role != "admin" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "you are not authorized"})
+	if err := validateInput(c.GetString("client_id"), newCfg.Schema, newCfg.Input); err != nil {
+		writeValidationError(c, err)
 		return
 	}
 
@@ -66,7 +60,10 @@ func (h *ConfigHandler) CreateConfig(c *gin.Context) {
 		return
 	}
 
-	existingCfg, _ := h.service.GetLastVersionByName(newCfg.Name)
+	// The tenant always comes from the token, never from the request body
+	newCfg.ClientID = c.GetString("client_id")
+
+	existingCfg, _ := h.service.GetLastVersionByName(newCfg.ClientID, newCfg.Name)
 
 	// If config already exist, reject
 	if existingCfg != nil {
@@ -83,6 +80,10 @@ func (h *ConfigHandler) CreateConfig(c *gin.Context) {
 	newCfg.Version = 1
 	newCfg.IsActive = 1
 	if err := h.service.Create(&newCfg); err != nil {
+		if errors.Is(err, ErrConfigDeleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "config is deleted, restore it instead"})
+			return
+		}
 		fmt.Println("service failed to create config")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
@@ -98,33 +99,20 @@ func (h *ConfigHandler) UpdateConfig(c *gin.Context) {
 		return
 	}
 
-	var updatedCfg models.Configurations
-	if err := c.ShouldBindJSON(&updatedCfg); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
-		return
+	var req struct {
+		models.Configurations
+		ExpectedVersion *int `json:"expected_version"`
+		Force           bool `json:"force"`
 	}
-
-	// Reject invalid input and schema pair
-	if !isValidInput(updatedCfg.Schema, updatedCfg.Input) {
-		fmt.Println("Invalid schema input pair")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+	if err := bindConfig(c, &req); err != nil {
+		writeBodyError(c, err)
 		return
 	}
+	updatedCfg := req.Configurations
 
-	// Only Admin is allowed to update config
-	roleVal, exists := c.Get("role")
-	if !exists {
-		// key not set
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "role not found"})
-		return
-	}
-	role, ok := roleVal.(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid role type"})
-		return
-	}
-	if role != "admin" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "you are not authorized"})
+	// Reject invalid input and schema pair
+	if err := validateInput(c.GetString("client_id"), updatedCfg.Schema, updatedCfg.Input); err != nil {
+		writeValidationError(c, err)
 		return
 	}
 
@@ -135,60 +123,176 @@ func (h *ConfigHandler) UpdateConfig(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
 		return
 	}
-	_, ok = userIdVal.(string)
+	userId, ok := userIdVal.(string)
 	if !ok {
 		fmt.Println("User is not authorized)")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
 		return
 	}
 
-	lastCfg, err := h.service.GetLastVersionByName(name)
-	if err != nil {
-		fmt.Println("failed to get last version")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	expected, ok := expectedVersion(c, req.ExpectedVersion)
+	if !ok {
 		return
 	}
-
-	// Reject if the update using different schema
-	if !equalSchemas(lastCfg.Schema, updatedCfg.Schema) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "schema cannot be modified"})
+	force, ok := forceFlag(c, req.Force)
+	if !ok {
 		return
 	}
 
+	clientID := c.GetString("client_id")
+	updatedCfg.ClientID = clientID
 	updatedCfg.Name = name
 	updatedCfg.IsActive = 1
 
-	if err := h.service.Create(&updatedCfg); err != nil {
-		fmt.Println("service failed to update config")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update config"})
+	// Reject schema changes that could break inputs written for the current
+	// schema, unless an admin forces them
+	lastCfg, err := writeSchemaChecked(h.service, clientID, name, updatedCfg.Schema, force, c.GetString("role"), expected,
+		func(expected int) error { return h.service.CreateVersion(&updatedCfg, expected) })
+	if err != nil {
+		writeValidationError(c, err)
 		return
 	}
+	if force && !equalSchemas(lastCfg.Schema, updatedCfg.Schema) {
+		fmt.Println("schema change of", name, "forced by", userId)
+	}
+	c.Header("ETag", etag(updatedCfg.Version, updatedCfg.Input))
 	c.JSON(http.StatusCreated, updatedCfg)
 }
 
-func (h *ConfigHandler) RollbackConfig(c *gin.Context) {
+// ValidationResult is the answer of the dry-run endpoints: what the write
+// would store and everything that would stop it
+type ValidationResult struct {
+	Valid bool   `json:"valid"`
+	Name  string `json:"name"`
+	// Version is the version the write would store, CurrentVersion the latest
+	// one, 0 for new configs, and SchemaVersion the schema version it would store
+	Version        int                 `json:"version"`
+	CurrentVersion int                 `json:"current_version"`
+	SchemaVersion  int                 `json:"schema_version"`
+	Diff           *ConfigDiff         `json:"diff"`
+	Errors         []ValidationFailure `json:"errors"`
+}
+
+type ValidationFailure struct {
+	Error      string      `json:"error"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// ValidateNewConfig runs a create through every check of CreateConfig
+// without storing it
+func (h *ConfigHandler) ValidateNewConfig(c *gin.Context) {
+	h.validateWrite(c, "")
+}
+
+// ValidateConfig runs an update through every check of UpdateConfig
+// without storing it
+func (h *ConfigHandler) ValidateConfig(c *gin.Context) {
 	name := c.Param("name")
-	versionStr := c.Param("version")
-	version, err := strconv.Atoi(versionStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"})
 		return
 	}
+	h.validateWrite(c, name)
+}
 
-	// Only Admin is allowed to rollback config
-	roleVal, exists := c.Get("role")
-	if !exists {
-		// key not set
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "role not found"})
+// validateWrite answers 200 when the write would succeed and 422 otherwise,
+// listing every failed check. Requests the write would reject before
+// looking at the config (bad body, missing config) fail as the write does.
+func (h *ConfigHandler) validateWrite(c *gin.Context, name string) {
+	var req struct {
+		models.Configurations
+		ExpectedVersion *int `json:"expected_version"`
+		Force           bool `json:"force"`
+	}
+	if err := bindConfig(c, &req); err != nil {
+		writeBodyError(c, err)
+		return
+	}
+	cfg := req.Configurations
+	create := name == ""
+	if create {
+		if cfg.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+	} else {
+		cfg.Name = name
+	}
+	cfg.ClientID = c.GetString("client_id")
+
+	expected, ok := expectedVersion(c, req.ExpectedVersion)
+	if !ok {
 		return
 	}
-	role, ok := roleVal.(string)
+	force, ok := forceFlag(c, req.Force)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid role type"})
 		return
 	}
-	if role != "admin" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "you are not authorized"})
+
+	result := ValidationResult{Name: cfg.Name, Errors: []ValidationFailure{}}
+	fail := func(err error) {
+		var ve *ValidationError
+		if errors.As(err, &ve) {
+			result.Errors = append(result.Errors, ValidationFailure{Error: ve.Err.Error(), Violations: ve.Violations})
+			return
+		}
+		result.Errors = append(result.Errors, ValidationFailure{Error: err.Error()})
+	}
+
+	if err := validateInput(cfg.ClientID, cfg.Schema, cfg.Input); err != nil {
+		fail(err)
+	}
+
+	// Without a JSON input there is nothing to compare with the latest version
+	if _, err := decodeJSON(cfg.Input); err == nil {
+		preview, err := h.service.PreviewWrite(&cfg, expected)
+		if preview == nil {
+			fmt.Println("failed to preview config write:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		latest := preview.Latest
+		switch {
+		case !create && (latest == nil || latest.IsActive == 0):
+			c.JSON(http.StatusNotFound, gin.H{"error": "config not found"})
+			return
+		case errors.Is(err, ErrConfigDeleted):
+			fail(errors.New("config is deleted, restore it instead"))
+		case create && latest != nil:
+			fail(errors.New("config already exists"))
+		case err != nil:
+			fail(err)
+		}
+		result.SchemaVersion = 1
+		if !create {
+			if err := checkSchemaChange(latest.Schema, cfg.Schema, force, c.GetString("role")); err != nil {
+				fail(err)
+			}
+			result.SchemaVersion = max(latest.SchemaVersion, 1)
+			if !equalSchemas(latest.Schema, cfg.Schema) {
+				result.SchemaVersion++
+			}
+		}
+		result.Version = preview.Version
+		result.CurrentVersion = preview.Version - 1
+		result.Diff = preview.Diff
+	}
+
+	result.Valid = len(result.Errors) == 0
+	if !result.Valid {
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *ConfigHandler) RollbackConfig(c *gin.Context) {
+	name := c.Param("name")
+	versionStr := c.Param("version")
+	version, err := strconv.Atoi(versionStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
 		return
 	}
 
@@ -206,32 +310,98 @@ func (h *ConfigHandler) RollbackConfig(c *gin.Context) {
 		return
 	}
 
+	expected, ok := expectedVersion(c, nil)
+	if !ok {
+		return
+	}
+	force, ok := forceFlag(c, false)
+	if !ok {
+		return
+	}
+
 	// Get target version
-	cfg, err := h.service.GetByNameByVersion(name, version)
+	clientID := c.GetString("client_id")
+	cfg, err := h.service.GetByNameByVersion(clientID, name, version)
 	if err != nil || cfg == nil {
 		fmt.Println("Service failed to get target version")
 		c.JSON(http.StatusNotFound, gin.H{"error": "config version not found"})
 		return
 	}
-
 	cfg.CreatedBy = userId
 
-	if err := h.service.Create(cfg); err != nil {
-		fmt.Println("failed to rollback config")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rollback config"})
+	// Going back to an older schema breaks inputs like any other schema
+	// change, so it is checked the same way as an update
+	latest, err := writeSchemaChecked(h.service, clientID, name, cfg.Schema, force, c.GetString("role"), expected,
+		func(expected int) error { return h.service.RollbackConfig(cfg, expected) })
+	if errors.Is(err, ErrConfigDeleted) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "config not found"})
+		return
+	}
+	if err != nil {
+		writeValidationError(c, err)
 		return
 	}
+	if force && !equalSchemas(latest.Schema, cfg.Schema) {
+		fmt.Println("schema change of", name, "forced by", userId, "on rollback")
+	}
 
+	c.Header("ETag", etag(cfg.Version, cfg.Input))
 	c.JSON(http.StatusCreated, cfg)
 }
 
 func (h *ConfigHandler) GetLastVersionByName(c *gin.Context) {
 	name := c.Param("name")
-	cfg, err := h.service.GetLastVersionByName(name)
-	if err != nil {
+	cfg, err := h.service.GetLastVersionByName(c.GetString("client_id"), name)
+	if err != nil || cfg == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "config not found"})
 		return
 	}
+	// Pollers send back the ETag and get an empty 304 until the config changes
+	writeConfig(c, cfg.Version, cfg.Input, cfg, cfg.UpdatedAt, cacheRevalidate)
+}
+
+// WatchConfig long-polls for a version newer than ?after_version=. It answers
+// 200 with the new latest config as soon as there is one, or 304 after ?timeout=.
+func (h *ConfigHandler) WatchConfig(c *gin.Context) {
+	afterVersion := 0
+	if v := c.Query("after_version"); v != "" {
+		var err error
+		afterVersion, err = strconv.Atoi(v)
+		if err != nil || afterVersion < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid after_version"})
+			return
+		}
+	}
+	timeout := defaultWatchTimeout
+	if v := c.Query("timeout"); v != "" {
+		var err error
+		timeout, err = time.ParseDuration(v)
+		if err != nil || timeout <= 0 || timeout > maxWatchTimeout {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout, expected a duration up to " + maxWatchTimeout.String()})
+			return
+		}
+	}
+
+	// The request context is also cancelled when the client goes away
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	cfg, err := h.service.WatchConfig(ctx, c.GetString("client_id"), c.Param("name"), afterVersion)
+	if err != nil {
+		if errors.Is(err, notify.ErrTooManyWaiters) {
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "too many watchers, retry later"})
+			return
+		}
+		writeConfigError(c, err)
+		return
+	}
+	if cfg == nil {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Header("ETag", etag(cfg.Version, cfg.Input))
+	c.Header("Cache-Control", "no-store")
 	c.JSON(http.StatusOK, cfg)
 }
 
@@ -244,20 +414,329 @@ func (h *ConfigHandler) GetConfigByNameByVersion(c *gin.Context) {
 		return
 	}
 
-	cfg, err := h.service.GetByNameByVersion(name, version)
-	if err != nil {
+	cfg, err := h.service.GetByNameByVersion(c.GetString("client_id"), name, version)
+	if err != nil || cfg == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "config version not found"})
 		return
 	}
-	c.JSON(http.StatusOK, cfg)
+	writeConfig(c, cfg.Version, cfg.Input, cfg, cfg.UpdatedAt, cacheRevalidate)
 }
 
 func (h *ConfigHandler) GetConfigVersions(c *gin.Context) {
 	name := c.Param("name")
-	cfgs, err := h.service.GetConfigVersions(name)
+	limit, ok := limitParam(c)
+	if !ok {
+		return
+	}
+
+	cfgs, err := h.service.GetConfigVersions(c.GetString("client_id"), name, limit, c.Query("cursor"))
 	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get config versions"})
 		return
 	}
+	if notModified(c, listETag(cfgs), lastModified(cfgs.Items), cacheRevalidate) {
+		return
+	}
 	c.JSON(http.StatusOK, cfgs)
 }
+
+// ListConfigs lists the latest version of every config the caller may read
+func (h *ConfigHandler) ListConfigs(c *gin.Context) {
+	// Set by policy.ScopeConfigAccess
+	patterns, ok := c.Get("name_patterns")
+	if !ok {
+		fmt.Println("name patterns not set, is the route missing ScopeConfigAccess?")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	limit, ok := limitParam(c)
+	if !ok {
+		return
+	}
+	q := ListQuery{
+		ClientID:  c.GetString("client_id"),
+		Search:    c.Query("q"),
+		Prefix:    c.Query("prefix"),
+		Type:      models.Type(c.Query("type")),
+		CreatedBy: c.Query("created_by"),
+		Sort:      c.Query("sort"),
+		Limit:     limit,
+		Cursor:    c.Query("cursor"),
+	}
+	q.Patterns, _ = patterns.([]string)
+
+	if v := c.Query("is_active"); v != "" {
+		isActive, err := strconv.Atoi(v)
+		if err != nil || (isActive != 0 && isActive != 1) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid is_active"})
+			return
+		}
+		q.IsActive = &isActive
+	}
+	if v := c.Query("updated_since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid updated_since, expected RFC 3339"})
+			return
+		}
+		// Timestamps are stored as text in local time, compare in the same zone
+		since = since.Local()
+		q.UpdatedSince = &since
+	}
+
+	list, err := h.service.ListConfigs(q)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSort) || errors.Is(err, ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Println("failed to list configs:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list configs"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func limitParam(c *gin.Context) (int, bool) {
+	v := c.Query("limit")
+	if v == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// PatchConfig updates part of the latest Input, the media type selects
+// JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902).
+func (h *ConfigHandler) PatchConfig(c *gin.Context) {
+	patchType := c.ContentType()
+	if patchType != MergePatchType && patchType != JSONPatchType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "content type must be " + MergePatchType + " or " + JSONPatchType})
+		return
+	}
+	actor, ok := actorFromContext(c)
+	if !ok {
+		return
+	}
+	expected, ok := expectedVersion(c, nil)
+	if !ok {
+		return
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	cfg, err := h.service.PatchConfig(c.GetString("client_id"), c.Param("name"), patchType, patch, expected, actor)
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	c.Header("ETag", etag(cfg.Version, cfg.Input))
+	c.JSON(http.StatusCreated, cfg)
+}
+
+// DiffVersions diffs the Input of ?from= against ?to=, which defaults to the latest version
+func (h *ConfigHandler) DiffVersions(c *gin.Context) {
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from version"})
+		return
+	}
+	to := 0
+	if v := c.Query("to"); v != "" && v != "latest" {
+		to, err = strconv.Atoi(v)
+		if err != nil || to < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to version"})
+			return
+		}
+	}
+
+	diff, err := h.service.DiffVersions(c.GetString("client_id"), c.Param("name"), from, to)
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// DeleteConfig soft deletes a config, it can be restored until purged
+func (h *ConfigHandler) DeleteConfig(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteConfig(c.GetString("client_id"), c.Param("name"), actor); err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ConfigHandler) RestoreConfig(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		return
+	}
+	cfg, err := h.service.RestoreConfig(c.GetString("client_id"), c.Param("name"), actor)
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	c.Header("ETag", etag(cfg.Version, cfg.Input))
+	c.JSON(http.StatusOK, cfg)
+}
+
+// PurgeConfig permanently removes a deleted config and its history
+func (h *ConfigHandler) PurgeConfig(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		return
+	}
+	if err := h.service.PurgeConfig(c.GetString("client_id"), c.Param("name"), actor); err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ConfigHandler) GetConfigEvents(c *gin.Context) {
+	events, err := h.service.GetConfigEvents(c.GetString("client_id"), c.Param("name"))
+	if err != nil {
+		writeConfigError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// StreamEvents sends the events of every config the caller may read as
+// Server-Sent Events, optionally only those under ?prefix=. A reconnecting
+// client resumes after its Last-Event-ID, new clients only get new events.
+func (h *ConfigHandler) StreamEvents(c *gin.Context) {
+	// Set by policy.ScopeConfigAccess
+	patterns, ok := c.Get("name_patterns")
+	if !ok {
+		fmt.Println("name patterns not set, is the route missing ScopeConfigAccess?")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	q := EventQuery{ClientID: c.GetString("client_id"), Prefix: c.Query("prefix")}
+	q.Patterns, _ = patterns.([]string)
+
+	// Browsers resend the header, other clients may use the query parameter
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+		q.AfterID = uint(id)
+	} else {
+		id, err := h.service.LastEventID()
+		if err != nil {
+			writeConfigError(c, err)
+			return
+		}
+		q.AfterID = id
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// Ends when the client disconnects
+	ctx := c.Request.Context()
+	for ctx.Err() == nil {
+		waitCtx, cancel := context.WithTimeout(ctx, eventHeartbeat)
+		events, err := h.service.WaitForEvents(waitCtx, q)
+		cancel()
+		if err != nil {
+			// The client reconnects and resumes after the last event it got
+			fmt.Println("event stream error:", err)
+			return
+		}
+
+		if len(events) == 0 {
+			_, err = io.WriteString(c.Writer, ": ping\n\n")
+		}
+		for _, e := range events {
+			if err = sse.Encode(c.Writer, sse.Event{Id: strconv.FormatUint(uint64(e.ID), 10), Event: string(e.Type), Data: e}); err != nil {
+				break
+			}
+			q.AfterID = e.ID
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func actorFromContext(c *gin.Context) (string, bool) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return "", false
+	}
+	actor, ok := userID.(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return "", false
+	}
+	return actor, true
+}
+
+func writeConfigError(c *gin.Context, err error) {
+	var ve *ValidationError
+	switch {
+	case errors.As(err, &ve):
+		writeValidationError(c, ve)
+	case errors.Is(err, ErrConfigNotFound), errors.Is(err, ErrVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrConfigDeleted), errors.Is(err, ErrConfigNotDeleted), errors.Is(err, ErrPatchTestFailed),
+		errors.Is(err, ErrWriteContended):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidPatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInputMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "violations": []Violation{}})
+	case errors.Is(err, ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrForceNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		fmt.Println("config service error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// writeValidationError answers a rejected schema or input with every violation
+func writeValidationError(c *gin.Context, err error) {
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		writeConfigError(c, err)
+		return
+	}
+	violations := ve.Violations
+	if violations == nil {
+		violations = []Violation{}
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": ve.Err.Error(), "violations": violations})
+}
@@ -2,44 +2,145 @@ package configdata
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
+	"sass.com/configsvc/internal/policy"
 )
 
 type mockConfigService struct {
-	createErr   error
-	updateErr   error
-	rollbackErr error
-	lastCfg     *models.LastConfigurations
-	lastErr     error
-	byVerCfg    *models.Configurations
-	byVerErr    error
-	versions    []models.Configurations
-	versionsErr error
+	createErr    error
+	updateErr    error
+	rollbackErr  error
+	lastCfg      *models.LastConfigurations
+	lastErr      error
+	byVerCfg     *models.Configurations
+	byVerErr     error
+	versions     []models.Configurations
+	versionsErr  error
+	list         *ConfigList
+	listErr      error
+	listQuery    ListQuery
+	lifecycleErr error
+	events       []models.ConfigEvent
+	eventQuery   EventQuery
+	expected     int
+	preview      *WritePreview
+	previewErr   error
+	// conflicts is how many writes lose a race before one succeeds
+	conflicts int
+	writes    int
 }
 
 func (m *mockConfigService) Create(cfg *models.Configurations) error {
 	return m.createErr
 }
+func (m *mockConfigService) CreateVersion(cfg *models.Configurations, expectedVersion int) error {
+	m.expected = expectedVersion
+	return m.write(m.createErr)
+}
+func (m *mockConfigService) PreviewWrite(cfg *models.Configurations, expectedVersion int) (*WritePreview, error) {
+	m.expected = expectedVersion
+	return m.preview, m.previewErr
+}
 func (m *mockConfigService) Update(cfg *models.Configurations) error {
 	return m.updateErr
 }
-func (m *mockConfigService) RollbackConfig(cfg *models.Configurations) error {
-	return m.rollbackErr
+func (m *mockConfigService) RollbackConfig(cfg *models.Configurations, expectedVersion int) error {
+	m.expected = expectedVersion
+	return m.write(m.rollbackErr)
+}
+func (m *mockConfigService) write(err error) error {
+	m.writes++
+	if m.conflicts > 0 {
+		m.conflicts--
+		return ErrVersionConflict
+	}
+	return err
 }
-func (m *mockConfigService) GetLastVersionByName(name string) (*models.LastConfigurations, error) {
+func (m *mockConfigService) GetLastVersionByName(clientID, name string) (*models.LastConfigurations, error) {
 	return m.lastCfg, m.lastErr
 }
-func (m *mockConfigService) GetByNameByVersion(name string, version int) (*models.Configurations, error) {
+func (m *mockConfigService) GetByNameByVersion(clientID, name string, version int) (*models.Configurations, error) {
 	return m.byVerCfg, m.byVerErr
 }
-func (m *mockConfigService) GetConfigVersions(name string) ([]models.Configurations, error) {
-	return m.versions, m.versionsErr
+func (m *mockConfigService) GetConfigVersions(clientID, name string, limit int, cursor string) (*VersionList, error) {
+	if m.versionsErr != nil {
+		return nil, m.versionsErr
+	}
+	return &VersionList{Items: m.versions}, nil
+}
+func (m *mockConfigService) ListConfigs(q ListQuery) (*ConfigList, error) {
+	m.listQuery = q
+	return m.list, m.listErr
+}
+func (m *mockConfigService) DeleteConfig(clientID, name, actor string) error {
+	return m.lifecycleErr
+}
+func (m *mockConfigService) RestoreConfig(clientID, name, actor string) (*models.LastConfigurations, error) {
+	if m.lifecycleErr != nil {
+		return nil, m.lifecycleErr
+	}
+	return &models.LastConfigurations{Name: name, IsActive: 1}, nil
+}
+func (m *mockConfigService) PurgeConfig(clientID, name, actor string) error {
+	return m.lifecycleErr
+}
+func (m *mockConfigService) DiffVersions(clientID, name string, from, to int) (*ConfigDiff, error) {
+	if m.lifecycleErr != nil {
+		return nil, m.lifecycleErr
+	}
+	return &ConfigDiff{Name: name, From: from, To: to}, nil
+}
+func (m *mockConfigService) PatchConfig(clientID, name, patchType string, patch []byte, expectedVersion int, actor string) (*models.Configurations, error) {
+	m.expected = expectedVersion
+	if m.lifecycleErr != nil {
+		return nil, m.lifecycleErr
+	}
+	return &models.Configurations{Name: name, Input: string(patch), CreatedBy: actor}, nil
+}
+func (m *mockConfigService) WatchConfig(ctx context.Context, clientID, name string, afterVersion int) (*models.LastConfigurations, error) {
+	if m.lifecycleErr != nil {
+		return nil, m.lifecycleErr
+	}
+	// Pretend nothing changes until the watch times out
+	if m.lastCfg == nil || m.lastCfg.Version <= afterVersion {
+		<-ctx.Done()
+		return nil, nil
+	}
+	return m.lastCfg, nil
+}
+func (m *mockConfigService) WaitForEvents(ctx context.Context, q EventQuery) ([]models.ConfigEvent, error) {
+	m.eventQuery = q
+	var events []models.ConfigEvent
+	for _, e := range m.events {
+		if e.ID > q.AfterID {
+			events = append(events, e)
+		}
+	}
+	if len(events) == 0 {
+		<-ctx.Done()
+	}
+	return events, nil
+}
+func (m *mockConfigService) LastEventID() (uint, error) {
+	if len(m.events) == 0 {
+		return 0, nil
+	}
+	return m.events[len(m.events)-1].ID, nil
+}
+func (m *mockConfigService) GetConfigEvents(clientID, name string) ([]models.ConfigEvent, error) {
+	return m.events, nil
 }
 
 func setupGin() *gin.Engine {
@@ -47,6 +148,23 @@ func setupGin() *gin.Engine {
 	return gin.New()
 }
 
+// setIdentity mimics the context values set by AuthMiddleware
+func setIdentity(role, userID interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("role", role)
+		c.Set("user_id", userID)
+	}
+}
+
+// adminOnlyPolicy behaves like a policy without any grants: only admins get through
+type adminOnlyPolicy struct {
+	policy.PolicyService
+}
+
+func (adminOnlyPolicy) Authorize(userID, role, name string, action policy.Action) (bool, error) {
+	return role == "admin", nil
+}
+
 func TestConfigHandler_CreateConfig_Success(t *testing.T) {
 	svc := &mockConfigService{}
 	h := NewConfigHandler(svc)
@@ -169,11 +287,9 @@ func TestConfigHandler_CreateConfig_UserFailed(t *testing.T) {
 	svc := &mockConfigService{}
 	h := NewConfigHandler(svc)
 	r := setupGin()
-	r.POST("/configs", func(c *gin.Context) {
-		c.Set("role", "user") // 👈 non-admin
-		c.Set("user_id", "tester")
-		h.CreateConfig(c)
-	})
+	r.POST("/configs", setIdentity("user", "tester"), // 👈 non-admin
+		policy.RequireConfigAccess(adminOnlyPolicy{}, policy.ActionWrite, policy.NameFromBody("name")),
+		h.CreateConfig)
 
 	body := bytes.NewBufferString(`{
 		"name":"feature_flag",
@@ -187,8 +303,8 @@ func TestConfigHandler_CreateConfig_UserFailed(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	if w.Result().StatusCode != http.StatusUnauthorized {
-		t.Fatalf("expected 401, got %d", w.Result().StatusCode)
+	if w.Result().StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Result().StatusCode)
 	}
 }
 
@@ -233,11 +349,9 @@ func TestConfigHandler_UpdateConfig_UserUnauthorized(t *testing.T) {
 	}
 	h := NewConfigHandler(svc)
 	r := setupGin()
-	r.PUT("/configs/:name", func(c *gin.Context) {
-		c.Set("role", "user") // non-admin
-		c.Set("user_id", "tester")
-		h.UpdateConfig(c)
-	})
+	r.PUT("/configs/:name", setIdentity("user", "tester"), // non-admin
+		policy.RequireConfigAccess(adminOnlyPolicy{}, policy.ActionWrite, policy.NameFromParam("name")),
+		h.UpdateConfig)
 
 	body := bytes.NewBufferString(`{
 		"schema":"{\"type\":\"object\",\"properties\":{\"enabled\":{\"type\":\"boolean\"}},\"required\":[\"enabled\"]}",
@@ -249,8 +363,8 @@ func TestConfigHandler_UpdateConfig_UserUnauthorized(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	if w.Result().StatusCode != http.StatusUnauthorized {
-		t.Fatalf("expected 401, got %d", w.Result().StatusCode)
+	if w.Result().StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Result().StatusCode)
 	}
 }
 
@@ -281,8 +395,39 @@ func TestConfigHandler_UpdateConfig_SchemaChanged(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	if w.Result().StatusCode != http.StatusBadRequest {
-		t.Fatalf("expected 400, got %d", w.Result().StatusCode)
+	if w.Result().StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Result().StatusCode)
+	}
+	if !strings.Contains(w.Body.String(), `property \"max_limit\" is now required`) {
+		t.Fatalf("expected the incompatibility to be explained, got %s", w.Body.String())
+	}
+}
+
+func TestConfigHandler_UpdateConfig_SchemaEvolution(t *testing.T) {
+	const current = `{"type":"object","properties":{"mode":{"enum":["a","b"]}},"required":["mode"]}`
+	tests := []struct {
+		name, role, query, schema string
+		status                    int
+	}{
+		{"optional property", "user", "", `{"type":"object","properties":{"mode":{"enum":["a","b"]},"limit":{"type":"integer"}},"required":["mode"]}`, http.StatusCreated},
+		{"wider enum", "user", "", `{"type":"object","properties":{"mode":{"enum":["a","b","c"]}},"required":["mode"]}`, http.StatusCreated},
+		{"narrower enum", "user", "", `{"type":"object","properties":{"mode":{"enum":["a"]}},"required":["mode"]}`, http.StatusUnprocessableEntity},
+		{"forced by a user", "user", "?force=true", `{"type":"object","properties":{"mode":{"enum":["a"]}},"required":["mode"]}`, http.StatusForbidden},
+		{"forced by an admin", "admin", "?force=true", `{"type":"object","properties":{"mode":{"enum":["a"]}},"required":["mode"]}`, http.StatusCreated},
+		{"invalid force", "admin", "?force=maybe", current, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		svc := &mockConfigService{lastCfg: &models.LastConfigurations{Name: "modes", Schema: current, Version: 1, IsActive: 1}}
+		h := NewConfigHandler(svc)
+		r := setupGin()
+		r.PUT("/configs/:name", setIdentity(tt.role, "tester"), h.UpdateConfig)
+
+		body := `{"schema":` + tt.schema + `,"input":{"mode":"a"}}`
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/configs/modes"+tt.query, strings.NewReader(body)))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+		}
 	}
 }
 
@@ -294,6 +439,11 @@ func TestConfigHandler_RollbackConfig_AdminSuccess(t *testing.T) {
 			Input:   `{"enabled":true}`,
 			Version: 1,
 		},
+		lastCfg: &models.LastConfigurations{
+			Name:    "feature_flag",
+			Schema:  `{"type":"object","properties":{"enabled":{"type":"boolean"}},"required":["enabled"]}`,
+			Version: 2,
+		},
 	}
 	h := NewConfigHandler(svc)
 	r := setupGin()
@@ -312,24 +462,55 @@ func TestConfigHandler_RollbackConfig_AdminSuccess(t *testing.T) {
 	}
 }
 
+func TestConfigHandler_RollbackConfig_SchemaChange(t *testing.T) {
+	const (
+		current = `{"type":"object","properties":{"mode":{"enum":["a","b"]}},"required":["mode"]}`
+		older   = `{"type":"object","properties":{"mode":{"enum":["a"]}},"required":["mode"]}`
+	)
+	tests := []struct {
+		name, role, query, schema string
+		status, expected          int
+	}{
+		{"same schema", "user", "", current, http.StatusCreated, 3},
+		{"narrower enum", "user", "", older, http.StatusUnprocessableEntity, 0},
+		{"forced by a user", "user", "?force=true", older, http.StatusForbidden, 0},
+		{"forced by an admin", "admin", "?force=true", older, http.StatusCreated, 3},
+		{"expected version", "user", "?expected_version=2", current, http.StatusCreated, 2},
+		{"invalid force", "admin", "?force=maybe", current, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		svc := &mockConfigService{
+			byVerCfg: &models.Configurations{Name: "modes", Schema: tt.schema, Input: `{"mode":"a"}`, Version: 1},
+			lastCfg:  &models.LastConfigurations{Name: "modes", Schema: current, Version: 3, IsActive: 1},
+		}
+		h := NewConfigHandler(svc)
+		r := setupGin()
+		r.POST("/configs/:name/rollback/:version", setIdentity(tt.role, "tester"), h.RollbackConfig)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/configs/modes/rollback/1"+tt.query, nil))
+		if w.Code != tt.status || svc.expected != tt.expected {
+			t.Errorf("%s: expected %d with expected version %d, got %d with %d: %s", tt.name, tt.status, tt.expected, w.Code, svc.expected, w.Body.String())
+		}
+	}
+}
+
 func TestConfigHandler_RollbackConfig_UserUnauthorized(t *testing.T) {
 	svc := &mockConfigService{
 		byVerCfg: &models.Configurations{Name: "feature_flag", Version: 1},
 	}
 	h := NewConfigHandler(svc)
 	r := setupGin()
-	r.POST("/configs/:name/rollback/:version", func(c *gin.Context) {
-		c.Set("role", "user") // not admin
-		c.Set("user_id", "tester")
-		h.RollbackConfig(c)
-	})
+	r.POST("/configs/:name/rollback/:version", setIdentity("user", "tester"), // not admin
+		policy.RequireConfigAccess(adminOnlyPolicy{}, policy.ActionPublish, policy.NameFromParam("name")),
+		h.RollbackConfig)
 
 	req := httptest.NewRequest(http.MethodPost, "/configs/feature_flag/rollback/1", nil)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	if w.Result().StatusCode != http.StatusUnauthorized {
-		t.Fatalf("expected 401, got %d", w.Result().StatusCode)
+	if w.Result().StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Result().StatusCode)
 	}
 }
 
@@ -394,8 +575,9 @@ func TestConfigHandler_RollbackConfig_UserNotFound(t *testing.T) {
 
 func TestConfigHandler_RollbackConfig_ServiceError(t *testing.T) {
 	svc := &mockConfigService{
-		byVerCfg:  &models.Configurations{Name: "feature_flag", Version: 1},
-		createErr: errors.New("db error"),
+		byVerCfg:    &models.Configurations{Name: "feature_flag", Schema: `{"type":"object"}`, Version: 1},
+		lastCfg:     &models.LastConfigurations{Name: "feature_flag", Schema: `{"type":"object"}`, Version: 2},
+		rollbackErr: errors.New("db error"),
 	}
 	h := NewConfigHandler(svc)
 	r := setupGin()
@@ -573,11 +755,9 @@ func TestConfigHandler_CreateConfig_InvalidRoleType(t *testing.T) {
 	svc := &mockConfigService{}
 	h := NewConfigHandler(svc)
 	r := setupGin()
-	r.POST("/configs", func(c *gin.Context) {
-		c.Set("role", 123) // invalid type
-		c.Set("user_id", "tester")
-		h.CreateConfig(c)
-	})
+	r.POST("/configs", setIdentity(123, "tester"), // invalid type
+		policy.RequireConfigAccess(adminOnlyPolicy{}, policy.ActionWrite, policy.NameFromBody("name")),
+		h.CreateConfig)
 
 	body := bytes.NewBufferString(`{"name":"feature_flag","schema":"{}","input":"{}"}`)
 	req := httptest.NewRequest(http.MethodPost, "/configs", body)
@@ -700,11 +880,9 @@ func TestConfigHandler_RollbackConfig_InvalidRoleType(t *testing.T) {
 	}
 	h := NewConfigHandler(svc)
 	r := setupGin()
-	r.POST("/configs/:name/rollback/:version", func(c *gin.Context) {
-		c.Set("role", 123) // invalid type
-		c.Set("user_id", "tester")
-		h.RollbackConfig(c)
-	})
+	r.POST("/configs/:name/rollback/:version", setIdentity(123, "tester"), // invalid type
+		policy.RequireConfigAccess(adminOnlyPolicy{}, policy.ActionPublish, policy.NameFromParam("name")),
+		h.RollbackConfig)
 
 	req := httptest.NewRequest(http.MethodPost, "/configs/feature_flag/rollback/1", nil)
 	w := httptest.NewRecorder()
@@ -735,3 +913,612 @@ func TestConfigHandler_RollbackConfig_InvalidUserIDType(t *testing.T) {
 		t.Fatalf("expected 401, got %d", w.Result().StatusCode)
 	}
 }
+
+func TestConfigHandler_TenantIsolation(t *testing.T) {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+	cfg := &models.Configurations{
+		ClientID: testClientID,
+		Name:     "isolated",
+		Schema:   `{"type":"object","properties":{"enabled":{"type":"boolean"}},"required":["enabled"]}`,
+		Input:    `{"enabled":true}`,
+	}
+	if err := svc.Create(cfg); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	h := NewConfigHandler(svc)
+	r := setupGin()
+	r.Use(setIdentity("admin", "intruder"), func(c *gin.Context) {
+		c.Set("client_id", "bca-cabang")
+	})
+	r.GET("/configs/:name", h.GetLastVersionByName)
+	r.GET("/configs/:name/versions", h.GetConfigVersions)
+	r.GET("/configs/:name/versions/:version", h.GetConfigByNameByVersion)
+	r.POST("/configs/:name/rollback/:version", h.RollbackConfig)
+
+	for _, path := range []string{"/configs/isolated", "/configs/isolated/versions/1"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/isolated/versions", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"items":[]}` {
+		t.Errorf("expected no versions, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/configs/isolated/rollback/1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("rollback: expected 404, got %d", w.Code)
+	}
+
+	versions, _ := svc.GetConfigVersions(testClientID, "isolated", 0, "")
+	if len(versions.Items) != 1 {
+		t.Fatalf("expected owner's history to be untouched, got %d versions", len(versions.Items))
+	}
+}
+
+func TestConfigHandler_ListConfigs_Success(t *testing.T) {
+	svc := &mockConfigService{list: &ConfigList{Items: []models.LastConfigurations{{Name: "payments.gateway"}}}}
+	h := NewConfigHandler(svc)
+	r := setupGin()
+	r.GET("/configs", func(c *gin.Context) {
+		c.Set("client_id", testClientID)
+		c.Set("name_patterns", []string{"payments.*"})
+		h.ListConfigs(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/configs?prefix=pay&type=object&is_active=1&updated_since=2024-01-02T03:04:05Z&sort=-updated_at&limit=5", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	q := svc.listQuery
+	if q.ClientID != testClientID || q.Prefix != "pay" || q.Type != models.TypeObject || q.Sort != "-updated_at" || q.Limit != 5 {
+		t.Errorf("unexpected query %+v", q)
+	}
+	if len(q.Patterns) != 1 || q.IsActive == nil || *q.IsActive != 1 || q.UpdatedSince == nil {
+		t.Errorf("expected patterns, is_active and updated_since to be passed, got %+v", q)
+	}
+}
+
+func TestConfigHandler_ListConfigs_BadRequest(t *testing.T) {
+	h := NewConfigHandler(&mockConfigService{listErr: ErrInvalidSort})
+	r := setupGin()
+	r.GET("/configs", func(c *gin.Context) {
+		c.Set("name_patterns", []string{"*"})
+		h.ListConfigs(c)
+	})
+
+	for _, query := range []string{"limit=0", "is_active=yes", "updated_since=yesterday", "sort=schema"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestConfigHandler_ListConfigs_NotScoped(t *testing.T) {
+	h := NewConfigHandler(&mockConfigService{})
+	r := setupGin()
+	r.GET("/configs", h.ListConfigs)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 without name patterns, got %d", w.Code)
+	}
+}
+
+func TestConfigHandler_Lifecycle_StatusCodes(t *testing.T) {
+	cases := []struct {
+		method, path string
+		err          error
+		want         int
+	}{
+		{http.MethodDelete, "/configs/feature_flag", nil, http.StatusNoContent},
+		{http.MethodDelete, "/configs/feature_flag", ErrConfigNotFound, http.StatusNotFound},
+		{http.MethodDelete, "/configs/feature_flag", ErrConfigDeleted, http.StatusConflict},
+		{http.MethodPost, "/configs/feature_flag/restore", nil, http.StatusOK},
+		{http.MethodPost, "/configs/feature_flag/restore", ErrConfigNotDeleted, http.StatusConflict},
+		{http.MethodPost, "/configs/feature_flag/purge", nil, http.StatusNoContent},
+		{http.MethodPost, "/configs/feature_flag/purge", errors.New("db error"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		h := NewConfigHandler(&mockConfigService{lifecycleErr: tc.err})
+		r := setupGin()
+		r.Use(setIdentity("user", "tester"))
+		r.DELETE("/configs/:name", h.DeleteConfig)
+		r.POST("/configs/:name/restore", h.RestoreConfig)
+		r.POST("/configs/:name/purge", h.PurgeConfig)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s %s with %v: expected %d, got %d", tc.method, tc.path, tc.err, tc.want, w.Code)
+		}
+	}
+}
+
+func TestConfigHandler_DeleteConfig_UserNotFound(t *testing.T) {
+	h := NewConfigHandler(&mockConfigService{})
+	r := setupGin()
+	r.DELETE("/configs/:name", h.DeleteConfig)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/configs/feature_flag", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestConfigHandler_CreateConfig_Deleted(t *testing.T) {
+	svc := &mockConfigService{createErr: ErrConfigDeleted}
+	h := NewConfigHandler(svc)
+	r := setupGin()
+	r.POST("/configs", setIdentity("admin", "tester"), h.CreateConfig)
+
+	body := bytes.NewBufferString(`{"name":"feature_flag","schema":"{\"type\":\"object\"}","input":"{}"}`)
+	req := httptest.NewRequest(http.MethodPost, "/configs", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+}
+
+func TestConfigHandler_DiffVersions(t *testing.T) {
+	cases := []struct {
+		query string
+		err   error
+		want  int
+	}{
+		{"from=1&to=2", nil, http.StatusOK},
+		{"from=1&to=latest", nil, http.StatusOK},
+		{"from=1", nil, http.StatusOK},
+		{"", nil, http.StatusBadRequest},
+		{"from=0", nil, http.StatusBadRequest},
+		{"from=1&to=x", nil, http.StatusBadRequest},
+		{"from=1&to=9", ErrVersionNotFound, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		h := NewConfigHandler(&mockConfigService{lifecycleErr: tc.err})
+		r := setupGin()
+		r.GET("/configs/:name/diff", h.DiffVersions)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/feature_flag/diff?"+tc.query, nil))
+		if w.Code != tc.want {
+			t.Errorf("%q: expected %d, got %d", tc.query, tc.want, w.Code)
+		}
+	}
+}
+
+func TestConfigHandler_PatchConfig(t *testing.T) {
+	cases := []struct {
+		contentType string
+		err         error
+		want        int
+	}{
+		{MergePatchType, nil, http.StatusCreated},
+		{JSONPatchType, nil, http.StatusCreated},
+		{"application/json", nil, http.StatusUnsupportedMediaType},
+		{JSONPatchType, ErrInvalidPatch, http.StatusBadRequest},
+		{MergePatchType, ErrInputMismatch, http.StatusUnprocessableEntity},
+		{JSONPatchType, ErrPatchTestFailed, http.StatusConflict},
+		{MergePatchType, ErrConfigNotFound, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		h := NewConfigHandler(&mockConfigService{lifecycleErr: tc.err})
+		r := setupGin()
+		r.PATCH("/configs/:name", setIdentity("user", "tester"), h.PatchConfig)
+
+		req := httptest.NewRequest(http.MethodPatch, "/configs/feature_flag", bytes.NewBufferString(`{"enabled":true}`))
+		req.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s with %v: expected %d, got %d", tc.contentType, tc.err, tc.want, w.Code)
+		}
+	}
+}
+
+func TestConfigHandler_ETag(t *testing.T) {
+	svc := &mockConfigService{
+		lastCfg:  &models.LastConfigurations{Name: "feature_flag", Version: 4, Input: `{"a":1}`},
+		byVerCfg: &models.Configurations{Name: "feature_flag", Version: 2, Input: `{"a":0}`},
+	}
+	h := NewConfigHandler(svc)
+	r := setupGin()
+	r.GET("/configs/:name/latest", h.GetLastVersionByName)
+	r.GET("/configs/:name/versions/:version", h.GetConfigByNameByVersion)
+
+	cases := []struct{ path, prefix, cacheControl string }{
+		{"/configs/feature_flag/latest", `"4-`, cacheRevalidate},
+		{"/configs/feature_flag/versions/2", `"2-`, cacheRevalidate},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if got := w.Header().Get("ETag"); !strings.HasPrefix(got, tc.prefix) {
+			t.Errorf("%s: expected ETag starting with %s, got %q", tc.path, tc.prefix, got)
+		}
+		if got := w.Header().Get("Cache-Control"); got != tc.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q, got %q", tc.path, tc.cacheControl, got)
+		}
+	}
+
+	// The tag changes with the Input even when the version does not
+	if etag(4, `{"a":1}`) == etag(4, `{"a":2}`) {
+		t.Fatal("expected different inputs to get different tags")
+	}
+}
+
+func TestConfigHandler_GetLastVersionByName_Conditional(t *testing.T) {
+	updated := time.Date(2026, 3, 1, 10, 0, 0, 500, time.UTC)
+	cfg := &models.LastConfigurations{Name: "feature_flag", Version: 4, Input: `{"a":1}`, UpdatedAt: updated}
+	tag := etag(cfg.Version, cfg.Input)
+
+	cases := []struct {
+		desc, header, value string
+		want                int
+	}{
+		{"no validators", "", "", http.StatusOK},
+		{"matching tag", "If-None-Match", tag, http.StatusNotModified},
+		{"weak matching tag", "If-None-Match", `"1-x", W/` + tag, http.StatusNotModified},
+		{"any tag", "If-None-Match", "*", http.StatusNotModified},
+		{"stale tag", "If-None-Match", etag(3, `{"a":0}`), http.StatusOK},
+		{"not modified since", "If-Modified-Since", updated.Format(http.TimeFormat), http.StatusNotModified},
+		{"modified since", "If-Modified-Since", updated.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"invalid date", "If-Modified-Since", "yesterday", http.StatusOK},
+	}
+	for _, tc := range cases {
+		h := NewConfigHandler(&mockConfigService{lastCfg: cfg})
+		r := setupGin()
+		r.GET("/configs/:name/latest", h.GetLastVersionByName)
+
+		req := httptest.NewRequest(http.MethodGet, "/configs/feature_flag/latest", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.desc, tc.want, w.Code)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty body, got %q", tc.desc, w.Body.String())
+		}
+		if got := w.Header().Get("Last-Modified"); got != updated.Format(http.TimeFormat) {
+			t.Errorf("%s: expected Last-Modified %q, got %q", tc.desc, updated.Format(http.TimeFormat), got)
+		}
+	}
+}
+
+func TestConfigHandler_GetConfigVersions_Conditional(t *testing.T) {
+	svc := &mockConfigService{versions: []models.Configurations{{Name: "feature_flag", Version: 1, Input: `{}`}}}
+	h := NewConfigHandler(svc)
+	r := setupGin()
+	r.GET("/configs/:name/versions", h.GetConfigVersions)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/feature_flag/versions", nil))
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || tag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d, %q", w.Code, tag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/feature_flag/versions", nil)
+	req.Header.Set("If-None-Match", tag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+
+	// A new version changes the page
+	svc.versions = append(svc.versions, models.Configurations{Name: "feature_flag", Version: 2, Input: `{}`})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after a new version, got %d", w.Code)
+	}
+}
+
+func TestConfigHandler_UpdateConfig_Precondition(t *testing.T) {
+	body := `{"Schema":"{\"type\":\"object\"}","Input":"{}"%s}`
+	cases := []struct {
+		desc, ifMatch, field string
+		createErr            error
+		wantCode, wantExp    int
+	}{
+		{"unconditional", "", "", nil, http.StatusCreated, 4},
+		{"if-match", `"4"`, "", nil, http.StatusCreated, 4},
+		{"if-match with hash", etag(4, "{}"), "", nil, http.StatusCreated, 4},
+		{"any version", "*", "", nil, http.StatusCreated, 4},
+		{"body field", "", `,"expected_version":4`, nil, http.StatusCreated, 4},
+		{"header wins", `"3"`, `,"expected_version":4`, nil, http.StatusCreated, 3},
+		{"stale", `"3"`, "", ErrVersionConflict, http.StatusPreconditionFailed, 3},
+		{"weak tag", `W/"4"`, "", nil, http.StatusPreconditionFailed, 0},
+		{"invalid field", "", `,"expected_version":0`, nil, http.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		svc := &mockConfigService{
+			lastCfg:   &models.LastConfigurations{Name: "feature_flag", Version: 4, Schema: `{"type":"object"}`},
+			createErr: tc.createErr,
+		}
+		h := NewConfigHandler(svc)
+		r := setupGin()
+		r.PUT("/configs/:name", setIdentity("admin", "tester"), h.UpdateConfig)
+
+		req := httptest.NewRequest(http.MethodPut, "/configs/feature_flag", bytes.NewBufferString(fmt.Sprintf(body, tc.field)))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.wantCode || svc.expected != tc.wantExp {
+			t.Errorf("%s: expected %d with expected version %d, got %d with %d", tc.desc, tc.wantCode, tc.wantExp, w.Code, svc.expected)
+		}
+	}
+}
+
+func TestConfigHandler_UpdateConfig_LostRace(t *testing.T) {
+	cases := []struct {
+		desc, ifMatch        string
+		conflicts            int
+		wantCode, wantWrites int
+	}{
+		{"retried", "", 2, http.StatusCreated, 3},
+		{"kept losing", "", maxCheckedWriteAttempts, http.StatusConflict, maxCheckedWriteAttempts},
+		{"expected version", `"4"`, 1, http.StatusPreconditionFailed, 1},
+	}
+	for _, tc := range cases {
+		svc := &mockConfigService{
+			lastCfg:   &models.LastConfigurations{Name: "feature_flag", Version: 4, Schema: `{"type":"object"}`},
+			conflicts: tc.conflicts,
+		}
+		h := NewConfigHandler(svc)
+		r := setupGin()
+		r.PUT("/configs/:name", setIdentity("admin", "tester"), h.UpdateConfig)
+		r.POST("/configs/:name/rollback/:version", setIdentity("admin", "tester"), h.RollbackConfig)
+
+		req := httptest.NewRequest(http.MethodPut, "/configs/feature_flag", strings.NewReader(`{"schema":{"type":"object"},"input":{}}`))
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.wantCode || svc.writes != tc.wantWrites {
+			t.Errorf("update %s: expected %d after %d writes, got %d after %d", tc.desc, tc.wantCode, tc.wantWrites, w.Code, svc.writes)
+		}
+
+		svc.conflicts, svc.writes = tc.conflicts, 0
+		svc.byVerCfg = &models.Configurations{Name: "feature_flag", Version: 1, Schema: `{"type":"object"}`}
+		req = httptest.NewRequest(http.MethodPost, "/configs/feature_flag/rollback/1", nil)
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.wantCode || svc.writes != tc.wantWrites {
+			t.Errorf("rollback %s: expected %d after %d writes, got %d after %d", tc.desc, tc.wantCode, tc.wantWrites, w.Code, svc.writes)
+		}
+	}
+}
+
+func TestConfigHandler_PatchConfig_Precondition(t *testing.T) {
+	svc := &mockConfigService{}
+	h := NewConfigHandler(svc)
+	r := setupGin()
+	r.PATCH("/configs/:name", setIdentity("user", "tester"), h.PatchConfig)
+
+	req := httptest.NewRequest(http.MethodPatch, "/configs/feature_flag?expected_version=7", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", MergePatchType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || svc.expected != 7 {
+		t.Fatalf("expected 201 with expected version 7, got %d with %d", w.Code, svc.expected)
+	}
+
+	svc.lifecycleErr = ErrVersionConflict
+	req = httptest.NewRequest(http.MethodPatch, "/configs/feature_flag", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", MergePatchType)
+	req.Header.Set("If-Match", `"6"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d", w.Code)
+	}
+}
+
+func TestConfigHandler_WatchConfig(t *testing.T) {
+	cases := []struct {
+		desc, query string
+		err         error
+		want        int
+	}{
+		{"newer version", "after_version=3", nil, http.StatusOK},
+		{"timeout", "after_version=4&timeout=10ms", nil, http.StatusNotModified},
+		{"invalid after_version", "after_version=x", nil, http.StatusBadRequest},
+		{"invalid timeout", "timeout=10", nil, http.StatusBadRequest},
+		{"timeout too long", "timeout=1h", nil, http.StatusBadRequest},
+		{"too many watchers", "", notify.ErrTooManyWaiters, http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		svc := &mockConfigService{lastCfg: &models.LastConfigurations{Name: "feature_flag", Version: 4}, lifecycleErr: tc.err}
+		h := NewConfigHandler(svc)
+		r := setupGin()
+		r.GET("/configs/:name/watch", h.WatchConfig)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs/feature_flag/watch?"+tc.query, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.desc, tc.want, w.Code)
+		}
+	}
+}
+
+func streamEvents(t *testing.T, svc *mockConfigService, patterns []string, lastEventID string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := NewConfigHandler(svc)
+	r := setupGin()
+	r.GET("/events", func(c *gin.Context) {
+		c.Set("client_id", testClientID)
+		if patterns != nil {
+			c.Set("name_patterns", patterns)
+		}
+	}, h.StreamEvents)
+
+	// The stream only ends when the client goes away
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/events?prefix=payments.", nil).WithContext(ctx)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestConfigHandler_StreamEvents(t *testing.T) {
+	svc := &mockConfigService{events: []models.ConfigEvent{
+		{ID: 7, Name: "payments.gateway", Type: models.ConfigCreated, Version: 1, Actor: "alice"},
+		{ID: 9, Name: "payments.gateway", Type: models.ConfigUpdated, Version: 2, Actor: "bob"},
+	}}
+
+	w := streamEvents(t, svc, []string{"payments.*"}, "7")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "id:7\n") || !strings.Contains(body, "id:9\nevent:updated\ndata:{") || !strings.Contains(body, `"Actor":"bob"`) {
+		t.Fatalf("expected only the event after 7, got %q", body)
+	}
+	if svc.eventQuery.Prefix != "payments." || svc.eventQuery.Patterns[0] != "payments.*" || svc.eventQuery.ClientID != testClientID {
+		t.Fatalf("expected the query to be scoped, got %+v", svc.eventQuery)
+	}
+
+	// New clients only get events from now on
+	w = streamEvents(t, svc, []string{"*"}, "")
+	if strings.Contains(w.Body.String(), "id:") {
+		t.Fatalf("expected no past events, got %q", w.Body.String())
+	}
+}
+
+func TestConfigHandler_StreamEvents_BadRequest(t *testing.T) {
+	if w := streamEvents(t, &mockConfigService{}, []string{"*"}, "x"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid Last-Event-ID, got %d", w.Code)
+	}
+	if w := streamEvents(t, &mockConfigService{}, nil, ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 without name patterns, got %d", w.Code)
+	}
+}
+
+func TestConfigHandler_CreateConfig_ValidationErrors(t *testing.T) {
+	h := NewConfigHandler(&mockConfigService{})
+	r := setupGin()
+	r.POST("/configs", setIdentity("admin", "tester"), h.CreateConfig)
+
+	tests := []struct {
+		body       string
+		error      string
+		violations int
+	}{
+		{`{"name":"flags","schema":{"type":"object","properties":{"limit":{"type":"integer"}},"required":["enabled"]},"input":{"limit":"x"}}`, "input does not match the schema", 2},
+		{`{"name":"flags","schema":{"type":"objekt"},"input":{}}`, "invalid schema", 1},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/configs", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d: %s", tt.body, w.Code, w.Body.String())
+			continue
+		}
+		var resp struct {
+			Error      string      `json:"error"`
+			Violations []Violation `json:"violations"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Error != tt.error || len(resp.Violations) < tt.violations || resp.Violations[0].Message == "" {
+			t.Errorf("%s: unexpected response %s", tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestConfigHandler_ValidateConfig(t *testing.T) {
+	const schema = `{"type":"object","properties":{"limit":{"type":"integer"}}}`
+	latest := &models.LastConfigurations{Name: "limits", Schema: schema, Input: `{"limit":1}`, Version: 4, IsActive: 1}
+	diff := &ConfigDiff{Name: "limits", From: 4, To: 5}
+
+	tests := []struct {
+		name       string
+		path, body string
+		svc        *mockConfigService
+		status     int
+		errors     []string
+	}{
+		{"valid update", "/configs/limits/validate", `{"schema":` + schema + `,"input":{"limit":2}}`,
+			&mockConfigService{preview: &WritePreview{Latest: latest, Version: 5, Diff: diff}}, http.StatusOK, nil},
+		{"every failure", "/configs/limits/validate", `{"schema":{"type":"object","properties":{"limit":{"type":"string"}}},"input":{"limit":2},"expected_version":3}`,
+			&mockConfigService{preview: &WritePreview{Latest: latest, Version: 5, Diff: diff}, previewErr: ErrVersionConflict},
+			http.StatusUnprocessableEntity, []string{"input does not match the schema", "config version has changed", "schema change is not backward compatible"}},
+		{"forced schema change", "/configs/limits/validate", `{"schema":{"type":"object","properties":{"limit":{"type":"string"}}},"input":{"limit":"2"},"force":true}`,
+			&mockConfigService{preview: &WritePreview{Latest: latest, Version: 5, Diff: diff}}, http.StatusOK, nil},
+		{"missing config", "/configs/limits/validate", `{"schema":{},"input":{}}`,
+			&mockConfigService{preview: &WritePreview{Version: 1}}, http.StatusNotFound, nil},
+		{"new config", "/configs/validate", `{"name":"limits","schema":{},"input":{}}`,
+			&mockConfigService{preview: &WritePreview{Version: 1, Diff: diff}}, http.StatusOK, nil},
+		{"existing config", "/configs/validate", `{"name":"limits","schema":{},"input":{}}`,
+			&mockConfigService{preview: &WritePreview{Latest: latest, Version: 5, Diff: diff}}, http.StatusUnprocessableEntity, []string{"config already exists"}},
+		{"nameless", "/configs/validate", `{"schema":{},"input":{}}`, &mockConfigService{}, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		h := NewConfigHandler(tt.svc)
+		r := setupGin()
+		r.POST("/configs/validate", setIdentity("admin", "tester"), h.ValidateNewConfig)
+		r.POST("/configs/:name/validate", setIdentity("admin", "tester"), h.ValidateConfig)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+			continue
+		}
+		if w.Code != http.StatusOK && w.Code != http.StatusUnprocessableEntity {
+			continue
+		}
+
+		var result ValidationResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		var got []string
+		for _, e := range result.Errors {
+			got = append(got, e.Error)
+		}
+		if result.Valid != (len(tt.errors) == 0) || strings.Join(got, ", ") != strings.Join(tt.errors, ", ") {
+			t.Errorf("%s: expected errors %v, got %+v", tt.name, tt.errors, result)
+		}
+		if result.Diff == nil || result.Version != tt.svc.preview.Version || result.CurrentVersion != tt.svc.preview.Version-1 {
+			t.Errorf("%s: expected the preview in the result, got %+v", tt.name, result)
+		}
+	}
+}
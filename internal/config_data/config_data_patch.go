@@ -0,0 +1,454 @@
+package configdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types accepted by PATCH /configs/:name
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch    = errors.New("invalid patch")
+	ErrPatchTestFailed = errors.New("patch test operation failed")
+)
+
+// PatchOp is a single RFC 6902 JSON Patch operation
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Change describes one path-level difference between two documents
+type Change struct {
+	Path string          `json:"path"`
+	Type string          `json:"type"` // added, removed or changed
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// decodeJSON keeps numbers as written so large integers survive a round trip
+func decodeJSON(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func rawJSON(v interface{}) json.RawMessage {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+// escapePointerToken escapes a key for use in a JSON Pointer (RFC 6901)
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// diffJSON returns the patch turning from into to, and the matching summary
+func diffJSON(from, to interface{}) ([]PatchOp, []Change) {
+	d := &differ{patch: []PatchOp{}, changes: []Change{}}
+	d.diff("", from, to)
+	return d.patch, d.changes
+}
+
+type differ struct {
+	patch   []PatchOp
+	changes []Change
+}
+
+func (d *differ) diff(path string, from, to interface{}) {
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+
+	switch f := from.(type) {
+	case map[string]interface{}:
+		if t, ok := to.(map[string]interface{}); ok {
+			d.diffObjects(path, f, t)
+			return
+		}
+	case []interface{}:
+		if t, ok := to.([]interface{}); ok {
+			d.diffArrays(path, f, t)
+			return
+		}
+	}
+	d.replace(path, from, to)
+}
+
+func (d *differ) diffObjects(path string, from, to map[string]interface{}) {
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapePointerToken(k)
+		fv, inFrom := from[k]
+		tv, inTo := to[k]
+		switch {
+		case !inTo:
+			d.remove(p, fv)
+		case !inFrom:
+			d.add(p, tv)
+		default:
+			d.diff(p, fv, tv)
+		}
+	}
+}
+
+// diffArrays compares elements by index. Trailing removals are emitted from
+// the end so every operation stays valid when the patch is applied in order.
+func (d *differ) diffArrays(path string, from, to []interface{}) {
+	common := len(from)
+	if len(to) < common {
+		common = len(to)
+	}
+	for i := 0; i < common; i++ {
+		d.diff(path+"/"+strconv.Itoa(i), from[i], to[i])
+	}
+	for i := len(from) - 1; i >= common; i-- {
+		d.remove(path+"/"+strconv.Itoa(i), from[i])
+	}
+	for i := common; i < len(to); i++ {
+		d.add(path+"/"+strconv.Itoa(i), to[i])
+	}
+}
+
+func (d *differ) add(path string, value interface{}) {
+	d.patch = append(d.patch, PatchOp{Op: "add", Path: path, Value: rawJSON(value)})
+	d.changes = append(d.changes, Change{Path: path, Type: "added", New: rawJSON(value)})
+}
+
+func (d *differ) remove(path string, old interface{}) {
+	d.patch = append(d.patch, PatchOp{Op: "remove", Path: path})
+	d.changes = append(d.changes, Change{Path: path, Type: "removed", Old: rawJSON(old)})
+}
+
+func (d *differ) replace(path string, old, value interface{}) {
+	d.patch = append(d.patch, PatchOp{Op: "replace", Path: path, Value: rawJSON(value)})
+	d.changes = append(d.changes, Change{Path: path, Type: "changed", Old: rawJSON(old), New: rawJSON(value)})
+}
+
+// applyMergePatch applies an RFC 7396 JSON Merge Patch to doc
+func applyMergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	target := map[string]interface{}{}
+	if t, ok := doc.(map[string]interface{}); ok {
+		for k, v := range t {
+			target[k] = v
+		}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+		target[k] = applyMergePatch(target[k], v)
+	}
+	return target
+}
+
+// applyJSONPatch applies RFC 6902 operations to doc in order. doc is
+// modified in place, callers pass a freshly decoded document.
+func applyJSONPatch(doc interface{}, ops []PatchOp) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = applyOp(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOp(doc interface{}, op PatchOp) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%w: missing value", ErrInvalidPatch)
+		}
+		if value, err = decodeJSON(string(op.Value)); err != nil {
+			return nil, fmt.Errorf("%w: invalid value", ErrInvalidPatch)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return addAt(doc, path, value)
+	case "remove":
+		return removeAt(doc, path)
+	case "replace":
+		if _, err := getAt(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+			switch p := parent.(type) {
+			case map[string]interface{}:
+				p[key] = value
+				return p, nil
+			case []interface{}:
+				i, _ := arrayIndex(key, len(p)-1)
+				p[i] = value
+				return p, nil
+			}
+			return nil, errPathNotFound
+		})
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getAt(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return addAt(doc, path, deepCopy(v))
+		}
+		// A value cannot be moved into one of its own children
+		if len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidPatch)
+		}
+		if doc, err = removeAt(doc, from); err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, v)
+	case "test":
+		v, err := getAt(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(v, value) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+var errPathNotFound = fmt.Errorf("%w: path not found", ErrInvalidPatch)
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: pointer %q must start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token, max is the highest allowed index
+func arrayIndex(token string, max int) (int, error) {
+	// Leading zeros are not allowed by RFC 6901
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, errPathNotFound
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, errPathNotFound
+	}
+	return i, nil
+}
+
+func getAt(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[token]
+			if !ok {
+				return nil, errPathNotFound
+			}
+			node = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, errPathNotFound
+		}
+	}
+	return node, nil
+}
+
+// update walks to the parent of the last token of path and replaces it with
+// the result of fn, so slices can grow or shrink on the way back up.
+func update(node interface{}, path []string, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, errPathNotFound
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	}
+	return nil, errPathNotFound
+}
+
+func addAt(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			return p, nil
+		case []interface{}:
+			if key == "-" {
+				return append(p, value), nil
+			}
+			i, err := arrayIndex(key, len(p))
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		}
+		return nil, errPathNotFound
+	})
+}
+
+func removeAt(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+	return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, errPathNotFound
+			}
+			delete(p, key)
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, errPathNotFound
+	})
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, e := range t {
+			s[i] = deepCopy(e)
+		}
+		return s
+	}
+	return v
+}
+
+// jsonEqual compares decoded documents, numbers by value so 1 equals 1.0
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		rx, okx := new(big.Rat).SetString(x.String())
+		ry, oky := new(big.Rat).SetString(y.String())
+		return okx && oky && rx.Cmp(ry) == 0
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
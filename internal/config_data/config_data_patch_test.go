@@ -0,0 +1,200 @@
+package configdata
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func diffStrings(t *testing.T, from, to string) (string, string) {
+	f, err := decodeJSON(from)
+	if err != nil {
+		t.Fatalf("invalid from document: %v", err)
+	}
+	o, err := decodeJSON(to)
+	if err != nil {
+		t.Fatalf("invalid to document: %v", err)
+	}
+	patch, summary := diffJSON(f, o)
+	p, _ := json.Marshal(patch)
+	s, _ := json.Marshal(summary)
+	return string(p), string(s)
+}
+
+func TestDiffJSON_Objects(t *testing.T) {
+	patch, summary := diffStrings(t,
+		`{"host":"db1","port":5432,"tls":{"enabled":false},"old":1}`,
+		`{"host":"db2","port":5432,"tls":{"enabled":true},"pool":{"max":10}}`)
+
+	wantPatch := `[{"op":"replace","path":"/host","value":"db2"},` +
+		`{"op":"remove","path":"/old"},` +
+		`{"op":"add","path":"/pool","value":{"max":10}},` +
+		`{"op":"replace","path":"/tls/enabled","value":true}]`
+	if patch != wantPatch {
+		t.Errorf("unexpected patch\n got: %s\nwant: %s", patch, wantPatch)
+	}
+	wantSummary := `[{"path":"/host","type":"changed","old":"db1","new":"db2"},` +
+		`{"path":"/old","type":"removed","old":1},` +
+		`{"path":"/pool","type":"added","new":{"max":10}},` +
+		`{"path":"/tls/enabled","type":"changed","old":false,"new":true}]`
+	if summary != wantSummary {
+		t.Errorf("unexpected summary\n got: %s\nwant: %s", summary, wantSummary)
+	}
+}
+
+func TestDiffJSON_Arrays(t *testing.T) {
+	patch, _ := diffStrings(t, `{"hosts":["a","b","c","d"]}`, `{"hosts":["a","x"]}`)
+
+	// Removals run from the end so indexes stay valid
+	want := `[{"op":"replace","path":"/hosts/1","value":"x"},` +
+		`{"op":"remove","path":"/hosts/3"},` +
+		`{"op":"remove","path":"/hosts/2"}]`
+	if patch != want {
+		t.Errorf("unexpected patch\n got: %s\nwant: %s", patch, want)
+	}
+
+	patch, _ = diffStrings(t, `[1]`, `[1,2,3]`)
+	if want := `[{"op":"add","path":"/1","value":2},{"op":"add","path":"/2","value":3}]`; patch != want {
+		t.Errorf("unexpected patch\n got: %s\nwant: %s", patch, want)
+	}
+}
+
+func TestDiffJSON_EscapesAndNulls(t *testing.T) {
+	patch, _ := diffStrings(t, `{"a/b":1,"c~d":null}`, `{"a/b":null,"c~d":null}`)
+
+	if want := `[{"op":"replace","path":"/a~1b","value":null}]`; patch != want {
+		t.Errorf("unexpected patch\n got: %s\nwant: %s", patch, want)
+	}
+}
+
+func TestDiffJSON_Equal(t *testing.T) {
+	patch, summary := diffStrings(t, `{"a":[1,{"b":2}]}`, `{ "a": [1, {"b": 2}] }`)
+
+	if patch != "[]" || summary != "[]" {
+		t.Errorf("expected no differences, got %s %s", patch, summary)
+	}
+}
+
+func TestDiffJSON_RootTypeChange(t *testing.T) {
+	patch, _ := diffStrings(t, `{"a":1}`, `[1]`)
+
+	if want := `[{"op":"replace","path":"","value":[1]}]`; patch != want {
+		t.Errorf("unexpected patch\n got: %s\nwant: %s", patch, want)
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	// Test cases from RFC 7396 Appendix A
+	cases := []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		target, _ := decodeJSON(tc.target)
+		patch, _ := decodeJSON(tc.patch)
+		got, _ := json.Marshal(applyMergePatch(target, patch))
+		if string(got) != tc.want {
+			t.Errorf("merge %s into %s: expected %s, got %s", tc.patch, tc.target, tc.want, got)
+		}
+	}
+}
+
+func applyPatchString(t *testing.T, doc, patch string) (string, error) {
+	d, err := decodeJSON(doc)
+	if err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	var ops []PatchOp
+	if err := json.Unmarshal([]byte(patch), &ops); err != nil {
+		t.Fatalf("invalid patch: %v", err)
+	}
+	out, err := applyJSONPatch(d, ops)
+	if err != nil {
+		return "", err
+	}
+	got, _ := json.Marshal(out)
+	return string(got), nil
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	// Mostly the examples of RFC 6902 Appendix A
+	cases := []struct{ doc, patch, want string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"/":1,"~":2}`, `[{"op":"copy","from":"/~1","path":"/~0"}]`, `{"/":1,"~":1}`},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		{`{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`, `{"a":{"b":[1]},"c":{"b":[1,2]}}`},
+	}
+	for _, tc := range cases {
+		got, err := applyPatchString(t, tc.doc, tc.patch)
+		if err != nil {
+			t.Errorf("patch %s: unexpected error: %v", tc.patch, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("patch %s: expected %s, got %s", tc.patch, tc.want, got)
+		}
+	}
+}
+
+func TestApplyJSONPatch_Errors(t *testing.T) {
+	cases := []struct {
+		doc, patch string
+		want       error
+	}{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrPatchTestFailed},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrInvalidPatch},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, ErrInvalidPatch},
+		{`{"foo":["bar"]}`, `[{"op":"replace","path":"/foo/01","value":1}]`, ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/foo"}]`, ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"jump","path":"/foo"}]`, ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"foo","value":1}]`, ErrInvalidPatch},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ErrInvalidPatch},
+	}
+	for _, tc := range cases {
+		if _, err := applyPatchString(t, tc.doc, tc.patch); !errors.Is(err, tc.want) {
+			t.Errorf("patch %s: expected %v, got %v", tc.patch, tc.want, err)
+		}
+	}
+}
+
+func TestApplyJSONPatch_RoundTripsDiff(t *testing.T) {
+	from := `{"hosts":["a","b","c"],"tls":{"on":true},"x~y":1}`
+	to := `{"hosts":["a","z"],"tls":{"on":false,"ca":"pem"},"pool":5}`
+
+	f, _ := decodeJSON(from)
+	o, _ := decodeJSON(to)
+	patch, _ := diffJSON(f, o)
+	raw, _ := json.Marshal(patch)
+
+	got, err := applyPatchString(t, from, string(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _ := json.Marshal(o)
+	if got != string(want) {
+		t.Fatalf("expected diff to round trip to %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,149 @@
+package configdata
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"sass.com/configsvc/internal/models"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Columns the config listing can be sorted by
+var sortColumns = map[string]string{
+	"name":       "name",
+	"version":    "version",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// ListQuery selects a page of latest configs of a tenant
+type ListQuery struct {
+	ClientID     string
+	Patterns     []string // grant patterns the caller may read, "*" for everything
+	Search       string   // substring of the name
+	Prefix       string
+	Type         models.Type
+	CreatedBy    string
+	IsActive     *int
+	UpdatedSince *time.Time
+	Sort         string // field name, "-" prefix sorts descending
+	Limit        int
+	Cursor       string
+}
+
+// EventQuery selects the events of a tenant following AfterID
+type EventQuery struct {
+	ClientID string
+	Patterns []string // grant patterns the caller may read, "*" for everything
+	Prefix   string
+	AfterID  uint
+	Limit    int
+}
+
+// ConfigList is a page of latest configs
+type ConfigList struct {
+	Items      []models.LastConfigurations `json:"items"`
+	NextCursor string                      `json:"next_cursor,omitempty"`
+}
+
+// VersionList is a page of a config's history, oldest first
+type VersionList struct {
+	Items      []models.Configurations `json:"items"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// listCursor points at the last row of a page. Only the value of the
+// sorted column is set, ID breaks ties between equal values.
+type listCursor struct {
+	Sort    string     `json:"s"`
+	Name    string     `json:"n,omitempty"`
+	Version int        `json:"v,omitempty"`
+	Time    *time.Time `json:"t,omitempty"`
+	ID      string     `json:"id"`
+}
+
+// parseSort splits "-updated_at" into its column and direction
+func parseSort(sort string) (column string, desc bool, err error) {
+	if sort == "" {
+		sort = "name"
+	}
+	field, desc := strings.CutPrefix(sort, "-")
+	column, ok := sortColumns[field]
+	if !ok {
+		return "", false, ErrInvalidSort
+	}
+	return column, desc, nil
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
+
+func newListCursor(sort string, cfg models.LastConfigurations) listCursor {
+	cur := listCursor{Sort: sort, ID: cfg.ID.String()}
+	switch strings.TrimPrefix(sort, "-") {
+	case "version":
+		cur.Version = cfg.Version
+	case "created_at":
+		cur.Time = &cfg.CreatedAt
+	case "updated_at":
+		cur.Time = &cfg.UpdatedAt
+	default:
+		cur.Name = cfg.Name
+	}
+	return cur
+}
+
+// value returns the cursor's value for the sorted column
+func (cur listCursor) value(column string) interface{} {
+	switch column {
+	case "version":
+		return cur.Version
+	case "created_at", "updated_at":
+		if cur.Time == nil {
+			return nil
+		}
+		return *cur.Time
+	default:
+		return cur.Name
+	}
+}
+
+func encodeCursor(v interface{}) string {
+	raw, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// versionCursor points after the last version of a history page
+type versionCursor struct {
+	After int `json:"after"`
+}
@@ -1,17 +1,28 @@
 package configdata
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"sass.com/configsvc/internal/models"
 )
 
+// Every lookup is scoped by clientID (the tenant), a tenant never sees another tenant's configs
 type ConfigRepo interface {
-	Create(cfg *models.Configurations, lastCfg *models.LastConfigurations) error
+	Create(cfg *models.Configurations, lastCfg *models.LastConfigurations, expectedVersion int, event *models.ConfigEvent) error
 	Update(cfg *models.Configurations) error
-	GetLastConfig(name string) (*models.LastConfigurations, error)
-	GetByNameByVersion(name string, version int) (*models.Configurations, error)
-	GetConfigVersions(name string) ([]models.Configurations, error)
+	GetLastConfig(clientID, name string) (*models.LastConfigurations, error)
+	GetByNameByVersion(clientID, name string, version int) (*models.Configurations, error)
+	GetConfigVersions(clientID, name string, afterVersion, limit int) ([]models.Configurations, error)
+	ListConfigs(q ListQuery, after *listCursor) ([]models.LastConfigurations, error)
+	SetActive(clientID, name string, active bool, event *models.ConfigEvent) (bool, error)
+	Purge(clientID, name string, event *models.ConfigEvent) error
+	GetConfigEvents(clientID, name string) ([]models.ConfigEvent, error)
+	ListEvents(q EventQuery) ([]models.ConfigEvent, error)
+	LastEventID() (uint, error)
 }
 
 func NewConfigRepo(db *gorm.DB) ConfigRepo {
@@ -22,19 +33,62 @@ type ConfigRepoImpl struct {
 	db *gorm.DB
 }
 
-func (r *ConfigRepoImpl) Create(cfg *models.Configurations, last *models.LastConfigurations) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+// Create stores cfg as the next version of its config. The version number is
+// allocated inside the transaction, so concurrent writers never pick the same
+// one. A non-zero expectedVersion must still be the latest version. The
+// schema version goes up whenever the schema differs from the latest one. The
+// event, if any, is recorded with the allocated version.
+func (r *ConfigRepoImpl) Create(cfg *models.Configurations, last *models.LastConfigurations, expectedVersion int, event *models.ConfigEvent) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current []models.LastConfigurations
+		if err := tx.Where("client_id = ? AND name = ?", cfg.ClientID, cfg.Name).
+			Limit(1).
+			Find(&current).Error; err != nil {
+			return err
+		}
+
+		latest, schemaVersion := 0, 1
+		if len(current) == 1 {
+			latest = current[0].Version
+			if current[0].SchemaVersion > 0 {
+				schemaVersion = current[0].SchemaVersion
+			}
+			if !equalSchemas(current[0].Schema, cfg.Schema) {
+				schemaVersion++
+			}
+		}
+		if expectedVersion != 0 && latest != expectedVersion {
+			return ErrVersionConflict
+		}
+		cfg.Version = latest + 1
+		last.Version = cfg.Version
+		cfg.SchemaVersion = schemaVersion
+		last.SchemaVersion = schemaVersion
+
 		if err := tx.Create(cfg).Error; err != nil {
 			return err
 		}
 		if err := tx.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "name"}},
-			DoUpdates: clause.AssignmentColumns([]string{"client_id", "type", "schema", "input", "version", "updated_at", "is_active"}),
+			Columns:   []clause.Column{{Name: "client_id"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"type", "schema", "input", "version", "schema_version", "updated_at", "is_active"}),
 		}).Create(last).Error; err != nil {
 			return err
 		}
+		if event != nil {
+			event.Version = cfg.Version
+			return recordEvent(tx, event)
+		}
 		return nil
 	})
+	// Another writer took the version between our read and insert
+	if err != nil && isUniqueViolation(err) {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
 
 func (r *ConfigRepoImpl) Update(cfg *models.Configurations) error {
@@ -42,30 +96,198 @@ func (r *ConfigRepoImpl) Update(cfg *models.Configurations) error {
 }
 
 // Get latest version of a config by name
-func (r *ConfigRepoImpl) GetLastConfig(name string) (*models.LastConfigurations, error) {
+func (r *ConfigRepoImpl) GetLastConfig(clientID, name string) (*models.LastConfigurations, error) {
 	var lastCfg models.LastConfigurations
-	if err := r.db.Where("name = ?", name).
+	if err := r.db.Where("client_id = ? AND name = ?", clientID, name).
 		First(&lastCfg).Error; err != nil {
 		return nil, err
 	}
 	return &lastCfg, nil
 }
 
-func (r *ConfigRepoImpl) GetByNameByVersion(name string, version int) (*models.Configurations, error) {
+func (r *ConfigRepoImpl) GetByNameByVersion(clientID, name string, version int) (*models.Configurations, error) {
 	var cfg models.Configurations
-	if err := r.db.Where("name = ? AND version = ?", name, version).
+	if err := r.db.Where("client_id = ? AND name = ? AND version = ?", clientID, name, version).
 		First(&cfg).Error; err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
 
-func (r *ConfigRepoImpl) GetConfigVersions(name string) ([]models.Configurations, error) {
+// GetConfigVersions returns up to limit versions newer than afterVersion, oldest first
+func (r *ConfigRepoImpl) GetConfigVersions(clientID, name string, afterVersion, limit int) ([]models.Configurations, error) {
 	var configs []models.Configurations
-	if err := r.db.Where("name = ?", name).
-		Order("version ASC"). // change to DESC if you prefer latest first
+	if err := r.db.Where("client_id = ? AND name = ? AND version > ?", clientID, name, afterVersion).
+		Order("version ASC").
+		Limit(limit).
+		Find(&configs).Error; err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// ListConfigs returns up to q.Limit latest configs matching q, continuing after the given row
+func (r *ConfigRepoImpl) ListConfigs(q ListQuery, after *listCursor) ([]models.LastConfigurations, error) {
+	column, desc, err := parseSort(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := r.db.Model(&models.LastConfigurations{}).Where("client_id = ?", q.ClientID)
+	if clause, args := namePatternClause(q.Patterns); clause != "" {
+		tx = tx.Where(clause, args...)
+	}
+	if q.Search != "" {
+		tx = tx.Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(q.Search)+"%")
+	}
+	if q.Prefix != "" {
+		tx = tx.Where(`name LIKE ? ESCAPE '\'`, escapeLike(q.Prefix)+"%")
+	}
+	if q.Type != "" {
+		tx = tx.Where("type = ?", q.Type)
+	}
+	if q.CreatedBy != "" {
+		tx = tx.Where("created_by = ?", q.CreatedBy)
+	}
+	if q.IsActive != nil {
+		tx = tx.Where("is_active = ?", *q.IsActive)
+	}
+	if q.UpdatedSince != nil {
+		tx = tx.Where("updated_at >= ?", *q.UpdatedSince)
+	}
+
+	dir, op := "ASC", ">"
+	if desc {
+		dir, op = "DESC", "<"
+	}
+	if after != nil {
+		v := after.value(column)
+		tx = tx.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, op, column, op), v, v, after.ID)
+	}
+
+	var configs []models.LastConfigurations
+	if err := tx.Order(column + " " + dir).
+		Order("id " + dir).
+		Limit(q.Limit).
 		Find(&configs).Error; err != nil {
 		return nil, err
 	}
 	return configs, nil
 }
+
+// namePatternClause turns grant patterns into a SQL condition on name with
+// the same semantics as policy.MatchPattern. "*" needs no condition.
+func namePatternClause(patterns []string) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, p := range patterns {
+		if p == "*" {
+			return "", nil
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			conds = append(conds, `name LIKE ? ESCAPE '\'`)
+			args = append(args, escapeLike(prefix)+"%")
+			continue
+		}
+		conds = append(conds, "name = ?")
+		args = append(args, p)
+	}
+	if len(conds) == 0 {
+		// No pattern at all matches nothing
+		return "1 = 0", nil
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// SetActive flips is_active of the latest config and records event, it reports
+// false when the config is missing or already in the requested state.
+func (r *ConfigRepoImpl) SetActive(clientID, name string, active bool, event *models.ConfigEvent) (bool, error) {
+	from, to := 1, 0
+	if active {
+		from, to = 0, 1
+	}
+
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.LastConfigurations{}).
+			Where("client_id = ? AND name = ? AND is_active = ?", clientID, name, from).
+			Update("is_active", to)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		updated = true
+		return recordEvent(tx, event)
+	})
+	return updated, err
+}
+
+// Purge removes the config and its whole version history, only the event is kept
+func (r *ConfigRepoImpl) Purge(clientID, name string, event *models.ConfigEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ? AND name = ?", clientID, name).
+			Delete(&models.Configurations{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ? AND name = ?", clientID, name).
+			Delete(&models.LastConfigurations{}).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, event)
+	})
+}
+
+// recordEvent stores the event together with its outbox entry, from which
+// webhook deliveries are made, so no committed change goes unannounced
+func recordEvent(tx *gorm.DB, event *models.ConfigEvent) error {
+	if err := tx.Create(event).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{EventID: event.ID}).Error
+}
+
+func (r *ConfigRepoImpl) GetConfigEvents(clientID, name string) ([]models.ConfigEvent, error) {
+	var events []models.ConfigEvent
+	if err := r.db.Where("client_id = ? AND name = ?", clientID, name).
+		Order("id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListEvents returns up to q.Limit events after q.AfterID matching q, oldest first
+func (r *ConfigRepoImpl) ListEvents(q EventQuery) ([]models.ConfigEvent, error) {
+	tx := r.db.Where("client_id = ? AND id > ?", q.ClientID, q.AfterID)
+	if clause, args := namePatternClause(q.Patterns); clause != "" {
+		tx = tx.Where(clause, args...)
+	}
+	if q.Prefix != "" {
+		tx = tx.Where(`name LIKE ? ESCAPE '\'`, escapeLike(q.Prefix)+"%")
+	}
+
+	var events []models.ConfigEvent
+	if err := tx.Order("id ASC").
+		Limit(q.Limit).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// LastEventID returns the ID of the newest event of any tenant, 0 without events
+func (r *ConfigRepoImpl) LastEventID() (uint, error) {
+	var id uint
+	if err := r.db.Model(&models.ConfigEvent{}).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&id).Error; err != nil {
+		return 0, err
+	}
+	return id, nil
+}
@@ -1,6 +1,10 @@
 package configdata
 
 import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,13 +14,15 @@ import (
 	"sass.com/configsvc/internal/models"
 )
 
+const testClientID = "bca-pusat"
+
 func setupConfigTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
 		t.Fatalf("failed to open sqlite in-memory: %v", err)
 	}
 	// migrate both history and last snapshot tables so repo.Create(..., last) works
-	if err := db.AutoMigrate(&models.Configurations{}, &models.LastConfigurations{}); err != nil {
+	if err := db.AutoMigrate(&models.Configurations{}, &models.LastConfigurations{}, &models.ConfigEvent{}, &models.OutboxEvent{}); err != nil {
 		t.Fatalf("failed to migrate schema: %v", err)
 	}
 	return db
@@ -75,7 +81,7 @@ func TestConfigDataRepo_CreateMultipleProperties_Success(t *testing.T) {
 	}
 
 	// create last snapshot from cfg and pass to Create
-	if err := repo.Create(cfg, makeLastFromCfg(cfg)); err != nil {
+	if err := repo.Create(cfg, makeLastFromCfg(cfg), 0, nil); err != nil {
 		t.Fatalf("failed to create config: %v", err)
 	}
 }
@@ -114,7 +120,7 @@ func TestConfigDataRepo_CreateSingleProperty_Success(t *testing.T) {
 		IsActive:  1,
 	}
 
-	if err := repo.Create(cfg, makeLastFromCfg(cfg)); err != nil {
+	if err := repo.Create(cfg, makeLastFromCfg(cfg), 0, nil); err != nil {
 		t.Fatalf("failed to create config: %v", err)
 	}
 }
@@ -189,7 +195,7 @@ func TestConfigDataRepo_Update_Success(t *testing.T) {
 		UpdatedAt: time.Now(),
 		IsActive:  1,
 	}
-	if err := repo.Create(cfg, makeLastFromCfg(cfg)); err != nil {
+	if err := repo.Create(cfg, makeLastFromCfg(cfg), 0, nil); err != nil {
 		t.Fatalf("failed to create config: %v", err)
 	}
 
@@ -239,12 +245,12 @@ func TestConfigDataRepo_GetByName(t *testing.T) {
 		t.Fatal("validation failed: schema and input are conflicting")
 	}
 
-	cfg1 := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 1, Schema: schemaJSON, Input: inputV1}
-	cfg2 := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 2, Schema: schemaJSON, Input: inputV2}
-	_ = repo.Create(cfg1, makeLastFromCfg(cfg1))
-	_ = repo.Create(cfg2, makeLastFromCfg(cfg2))
+	cfg1 := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "feature_flag", Version: 1, Schema: schemaJSON, Input: inputV1}
+	cfg2 := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "feature_flag", Version: 2, Schema: schemaJSON, Input: inputV2}
+	_ = repo.Create(cfg1, makeLastFromCfg(cfg1), 0, nil)
+	_ = repo.Create(cfg2, makeLastFromCfg(cfg2), 0, nil)
 
-	latest, err := repo.GetLastConfig("feature_flag")
+	latest, err := repo.GetLastConfig(testClientID, "feature_flag")
 	if err != nil {
 		t.Fatalf("failed to get config by name: %v", err)
 	}
@@ -257,7 +263,7 @@ func TestConfigDataRepo_GetByName_NotFound(t *testing.T) {
 	db := setupConfigTestDB(t)
 	repo := NewConfigRepo(db)
 
-	_, err := repo.GetLastConfig("does_not_exist")
+	_, err := repo.GetLastConfig(testClientID, "does_not_exist")
 	if err == nil {
 		t.Fatal("expected error for missing config, got nil")
 	}
@@ -287,12 +293,12 @@ func TestConfigDataRepo_GetByNameByVersion(t *testing.T) {
 		t.Fatal("validation failed: schema and input are conflicting")
 	}
 
-	cfg1 := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 1, Schema: schemaJSON, Input: inputV1}
-	cfg2 := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 2, Schema: schemaJSON, Input: inputV2}
-	_ = repo.Create(cfg1, makeLastFromCfg(cfg1))
-	_ = repo.Create(cfg2, makeLastFromCfg(cfg2))
+	cfg1 := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "feature_flag", Version: 1, Schema: schemaJSON, Input: inputV1}
+	cfg2 := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "feature_flag", Version: 2, Schema: schemaJSON, Input: inputV2}
+	_ = repo.Create(cfg1, makeLastFromCfg(cfg1), 0, nil)
+	_ = repo.Create(cfg2, makeLastFromCfg(cfg2), 0, nil)
 
-	v1, err := repo.GetByNameByVersion("feature_flag", 1)
+	v1, err := repo.GetByNameByVersion(testClientID, "feature_flag", 1)
 	if err != nil {
 		t.Fatalf("failed to get config by name and version: %v", err)
 	}
@@ -305,7 +311,7 @@ func TestConfigDataRepo_GetByNameByVersion_NotFound(t *testing.T) {
 	db := setupConfigTestDB(t)
 	repo := NewConfigRepo(db)
 
-	_, err := repo.GetByNameByVersion("missing", 99)
+	_, err := repo.GetByNameByVersion(testClientID, "missing", 99)
 	if err == nil {
 		t.Fatal("expected error for missing config version, got nil")
 	}
@@ -339,14 +345,14 @@ func TestConfigDataRepo_GetConfigVersions(t *testing.T) {
 		t.Fatal("validation failed: schema and input are conflicting")
 	}
 
-	cfg1 := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 1, Schema: schemaJSON, Input: inputV1}
-	cfg2 := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 2, Schema: schemaJSON, Input: inputV2}
-	cfg3 := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 3, Schema: schemaJSON, Input: inputV3}
-	_ = repo.Create(cfg1, makeLastFromCfg(cfg1))
-	_ = repo.Create(cfg2, makeLastFromCfg(cfg2))
-	_ = repo.Create(cfg3, makeLastFromCfg(cfg3))
+	cfg1 := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "feature_flag", Version: 1, Schema: schemaJSON, Input: inputV1}
+	cfg2 := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "feature_flag", Version: 2, Schema: schemaJSON, Input: inputV2}
+	cfg3 := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "feature_flag", Version: 3, Schema: schemaJSON, Input: inputV3}
+	_ = repo.Create(cfg1, makeLastFromCfg(cfg1), 0, nil)
+	_ = repo.Create(cfg2, makeLastFromCfg(cfg2), 0, nil)
+	_ = repo.Create(cfg3, makeLastFromCfg(cfg3), 0, nil)
 
-	list, err := repo.GetConfigVersions("feature_flag")
+	list, err := repo.GetConfigVersions(testClientID, "feature_flag", 0, 10)
 	if err != nil {
 		t.Fatalf("failed to get config versions: %v", err)
 	}
@@ -362,7 +368,7 @@ func TestConfigDataRepo_GetConfigVersions_NotFound(t *testing.T) {
 	db := setupConfigTestDB(t)
 	repo := NewConfigRepo(db)
 
-	list, err := repo.GetConfigVersions("does_not_exist")
+	list, err := repo.GetConfigVersions(testClientID, "does_not_exist", 0, 10)
 	if err != nil {
 		t.Fatalf("expected empty list, got error: %v", err)
 	}
@@ -370,3 +376,297 @@ func TestConfigDataRepo_GetConfigVersions_NotFound(t *testing.T) {
 		t.Errorf("expected 0 results, got %d", len(list))
 	}
 }
+
+func TestConfigDataRepo_SameNameAcrossTenants(t *testing.T) {
+	db := setupConfigTestDB(t)
+	repo := NewConfigRepo(db)
+
+	schemaJSON := `{"type":"object","properties":{"host":{"type":"string"}},"required":["host"]}`
+	ours := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "database", Version: 1, Schema: schemaJSON, Input: `{"host":"ours"}`}
+	theirs := &models.Configurations{ID: uuid.New(), ClientID: "bca-cabang", Name: "database", Version: 1, Schema: schemaJSON, Input: `{"host":"theirs"}`}
+	if err := repo.Create(ours, makeLastFromCfg(ours), 0, nil); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	if err := repo.Create(theirs, makeLastFromCfg(theirs), 0, nil); err != nil {
+		t.Fatalf("expected same name in another tenant to be allowed, got %v", err)
+	}
+
+	latest, err := repo.GetLastConfig(testClientID, "database")
+	if err != nil || latest.Input != `{"host":"ours"}` {
+		t.Fatalf("expected own config, got %+v, %v", latest, err)
+	}
+	list, err := repo.GetConfigVersions(testClientID, "database", 0, 10)
+	if err != nil || len(list) != 1 || list[0].ID != ours.ID {
+		t.Fatalf("expected only own versions, got %+v, %v", list, err)
+	}
+	if _, err := repo.GetByNameByVersion("bca-syariah", "database", 1); err == nil {
+		t.Fatal("expected a tenant without configs to find nothing")
+	}
+}
+
+func seedListConfigs(t *testing.T, repo ConfigRepo) {
+	schemaJSON := `{"type":"object"}`
+	seeds := []struct {
+		clientID, name, createdBy string
+		typ                       models.Type
+		isActive                  int
+	}{
+		{testClientID, "payments.gateway", "alice", models.TypeObject, 1},
+		{testClientID, "payments.limits", "bob", models.TypeStandard, 1},
+		{testClientID, "payments_legacy", "alice", models.TypeObject, 0},
+		{testClientID, "database", "bob", models.TypeObject, 1},
+		{"bca-cabang", "payments.gateway", "carol", models.TypeObject, 1},
+	}
+	for _, s := range seeds {
+		cfg := &models.Configurations{ID: uuid.New(), ClientID: s.clientID, Name: s.name, Type: s.typ, Version: 1,
+			Schema: schemaJSON, Input: `{}`, CreatedBy: s.createdBy, IsActive: s.isActive}
+		if err := repo.Create(cfg, makeLastFromCfg(cfg), 0, nil); err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+	}
+}
+
+func TestConfigDataRepo_ListConfigs_Filters(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	seedListConfigs(t, repo)
+
+	inactive := 0
+	cases := []struct {
+		desc string
+		q    ListQuery
+		want []string
+	}{
+		{"all", ListQuery{}, []string{"database", "payments.gateway", "payments.limits", "payments_legacy"}},
+		{"search", ListQuery{Search: "limit"}, []string{"payments.limits"}},
+		{"prefix escapes wildcards", ListQuery{Prefix: "payments_"}, []string{"payments_legacy"}},
+		{"type", ListQuery{Type: models.TypeStandard}, []string{"payments.limits"}},
+		{"created by", ListQuery{CreatedBy: "bob"}, []string{"database", "payments.limits"}},
+		{"is active", ListQuery{IsActive: &inactive}, []string{"payments_legacy"}},
+		{"patterns", ListQuery{Patterns: []string{"payments.*", "database"}}, []string{"database", "payments.gateway", "payments.limits"}},
+		{"no patterns", ListQuery{Patterns: []string{}}, nil},
+		{"sort desc", ListQuery{Sort: "-name", Search: "payments."}, []string{"payments.limits", "payments.gateway"}},
+	}
+	for _, tc := range cases {
+		tc.q.ClientID = testClientID
+		tc.q.Limit = 10
+		if tc.q.Patterns == nil {
+			tc.q.Patterns = []string{"*"}
+		}
+
+		got, err := repo.ListConfigs(tc.q, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.desc, err)
+		}
+		var names []string
+		for _, cfg := range got {
+			names = append(names, cfg.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.want, names)
+		}
+	}
+}
+
+func TestConfigDataRepo_SetActive(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	seedListConfigs(t, repo)
+
+	event := &models.ConfigEvent{ClientID: testClientID, Name: "database", Type: models.ConfigDeleted, Actor: "bob"}
+	updated, err := repo.SetActive(testClientID, "database", false, event)
+	if err != nil || !updated {
+		t.Fatalf("expected config to be deactivated, got %v, %v", updated, err)
+	}
+
+	// Already inactive: nothing changes and no event is recorded
+	updated, err = repo.SetActive(testClientID, "database", false, &models.ConfigEvent{ClientID: testClientID, Name: "database"})
+	if err != nil || updated {
+		t.Fatalf("expected no update, got %v, %v", updated, err)
+	}
+	events, _ := repo.GetConfigEvents(testClientID, "database")
+	if len(events) != 1 || events[0].Actor != "bob" {
+		t.Fatalf("expected a single event by bob, got %+v", events)
+	}
+
+	last, _ := repo.GetLastConfig(testClientID, "database")
+	if last.IsActive != 0 {
+		t.Fatalf("expected config to be inactive, got %+v", last)
+	}
+}
+
+func TestConfigDataRepo_Create_AllocatesVersion(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	newCfg := func() *models.Configurations {
+		return &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "database", Schema: `{}`, Input: `{}`, IsActive: 1}
+	}
+
+	first := newCfg()
+	if err := repo.Create(first, makeLastFromCfg(first), 0, nil); err != nil || first.Version != 1 {
+		t.Fatalf("expected version 1, got %d, %v", first.Version, err)
+	}
+	// The version passed in is ignored, the repo picks the next one
+	second := newCfg()
+	second.Version = 1
+	if err := repo.Create(second, makeLastFromCfg(second), 1, nil); err != nil || second.Version != 2 {
+		t.Fatalf("expected version 2, got %d, %v", second.Version, err)
+	}
+
+	stale := newCfg()
+	if err := repo.Create(stale, makeLastFromCfg(stale), 1, nil); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	last, _ := repo.GetLastConfig(testClientID, "database")
+	if last.Version != 2 {
+		t.Fatalf("expected latest version to stay 2, got %d", last.Version)
+	}
+}
+
+func TestConfigDataRepo_Create_SchemaVersion(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	write := func(schema, input string) *models.Configurations {
+		cfg := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "database", Schema: schema, Input: input, IsActive: 1}
+		if err := repo.Create(cfg, makeLastFromCfg(cfg), 0, nil); err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+		return cfg
+	}
+
+	steps := []struct {
+		schema, input string
+		want          int
+	}{
+		{`{"type":"object"}`, `{}`, 1},
+		{`{"type":"object"}`, `{"a":1}`, 1},
+		// Key order does not make a different schema
+		{`{"properties":{"a":{}},"type":"object"}`, `{"a":1}`, 2},
+		{`{"type":"object","properties":{"a":{}}}`, `{"a":2}`, 2},
+		{`{"type":"object"}`, `{"a":2}`, 3},
+	}
+	for i, step := range steps {
+		cfg := write(step.schema, step.input)
+		last, _ := repo.GetLastConfig(testClientID, "database")
+		if cfg.Version != i+1 || cfg.SchemaVersion != step.want || last.SchemaVersion != step.want {
+			t.Fatalf("version %d: expected schema version %d, got %d and %d", i+1, step.want, cfg.SchemaVersion, last.SchemaVersion)
+		}
+	}
+}
+
+func TestConfigDataRepo_Create_Concurrent(t *testing.T) {
+	// A file database, every connection of an in-memory one sees its own database
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "config.db")+"?_txlock=immediate"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Configurations{}, &models.LastConfigurations{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	repo := NewConfigRepo(db)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "database", Schema: `{}`, Input: `{}`, IsActive: 1}
+			errs <- repo.Create(cfg, makeLastFromCfg(cfg), 0, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	last, _ := repo.GetLastConfig(testClientID, "database")
+	if last.Version != writers {
+		t.Fatalf("expected %d versions, got latest version %d", writers, last.Version)
+	}
+}
+
+func TestConfigDataRepo_ListEvents(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	if id, err := repo.LastEventID(); err != nil || id != 0 {
+		t.Fatalf("expected 0 without events, got %d, %v", id, err)
+	}
+
+	for _, e := range []struct{ clientID, name string }{
+		{testClientID, "payments.gateway"},
+		{testClientID, "database"},
+		{"bca-cabang", "payments.gateway"},
+		{testClientID, "payments.limits"},
+	} {
+		cfg := &models.Configurations{ID: uuid.New(), ClientID: e.clientID, Name: e.name, Schema: `{}`, Input: `{}`, IsActive: 1}
+		event := &models.ConfigEvent{ClientID: e.clientID, Name: e.name, Type: models.ConfigCreated, Actor: "bob"}
+		if err := repo.Create(cfg, makeLastFromCfg(cfg), 0, event); err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+		if event.ID == 0 || event.Version != 1 {
+			t.Fatalf("expected event to be stored with the version, got %+v", event)
+		}
+	}
+
+	cases := []struct {
+		desc string
+		q    EventQuery
+		want []string
+	}{
+		{"all", EventQuery{}, []string{"payments.gateway", "database", "payments.limits"}},
+		{"after id", EventQuery{AfterID: 2}, []string{"payments.limits"}},
+		{"prefix", EventQuery{Prefix: "payments."}, []string{"payments.gateway", "payments.limits"}},
+		{"patterns", EventQuery{Patterns: []string{"database"}}, []string{"database"}},
+		{"limit", EventQuery{Limit: 1}, []string{"payments.gateway"}},
+	}
+	for _, tc := range cases {
+		tc.q.ClientID = testClientID
+		if tc.q.Patterns == nil {
+			tc.q.Patterns = []string{"*"}
+		}
+		if tc.q.Limit == 0 {
+			tc.q.Limit = 10
+		}
+
+		events, err := repo.ListEvents(tc.q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.desc, err)
+		}
+		var names []string
+		for _, e := range events {
+			names = append(names, e.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.want, names)
+		}
+	}
+
+	if id, err := repo.LastEventID(); err != nil || id != 4 {
+		t.Fatalf("expected last event id 4, got %d, %v", id, err)
+	}
+}
+
+func TestConfigDataRepo_RecordsOutboxEvent(t *testing.T) {
+	db := setupConfigTestDB(t)
+	repo := NewConfigRepo(db)
+
+	cfg := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "database", Schema: `{}`, Input: `{}`, IsActive: 1}
+	event := &models.ConfigEvent{ClientID: testClientID, Name: "database", Type: models.ConfigCreated, Actor: "bob"}
+	if err := repo.Create(cfg, makeLastFromCfg(cfg), 0, event); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	// A conflicting write is rolled back together with its outbox entry
+	stale := &models.Configurations{ID: uuid.New(), ClientID: testClientID, Name: "database", Schema: `{}`, Input: `{}`, IsActive: 1}
+	staleEvent := &models.ConfigEvent{ClientID: testClientID, Name: "database", Type: models.ConfigUpdated, Actor: "bob"}
+	if err := repo.Create(stale, makeLastFromCfg(stale), 5, staleEvent); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+
+	var outbox []models.OutboxEvent
+	if err := db.Find(&outbox).Error; err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	}
+	if len(outbox) != 1 || outbox[0].EventID != event.ID {
+		t.Fatalf("expected one outbox entry for event %d, got %+v", event.ID, outbox)
+	}
+}
@@ -0,0 +1,108 @@
+package configdata
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultSchemaCacheSize is how many compiled schemas are kept unless
+// SetSchemaCacheSize says otherwise
+const DefaultSchemaCacheSize = 1024
+
+// Compiled schemas shared by every handler, so a schema is only compiled
+// the first time it is seen. Registered schema versions never change, so
+// neither does what a schema with $refs compiles to.
+var compiledSchemas = newSchemaCache(DefaultSchemaCacheSize)
+
+// SetSchemaCacheSize bounds the compiled schema cache, n <= 0 restores the
+// default. It drops what was cached.
+func SetSchemaCacheSize(n int) {
+	if n <= 0 {
+		n = DefaultSchemaCacheSize
+	}
+	compiledSchemas.reset(n)
+}
+
+type schemaKey [sha256.Size]byte
+
+// schemaCacheKey hashes a schema with its tenant, whose registered schemas
+// its $refs resolve to
+func schemaCacheKey(clientID, schemaJSONString string) schemaKey {
+	h := sha256.New()
+	h.Write([]byte(clientID))
+	h.Write([]byte{0})
+	h.Write([]byte(schemaJSONString))
+	var key schemaKey
+	h.Sum(key[:0])
+	return key
+}
+
+type schemaCacheEntry struct {
+	key    schemaKey
+	schema *jsonschema.Schema
+}
+
+// schemaCache is an LRU of compiled schemas. A compiled schema is safe to
+// validate with from any number of goroutines.
+type schemaCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // most recently used first
+	entries map[schemaKey]*list.Element
+}
+
+func newSchemaCache(size int) *schemaCache {
+	return &schemaCache{size: size, order: list.New(), entries: make(map[schemaKey]*list.Element)}
+}
+
+func (c *schemaCache) get(key schemaKey) (*jsonschema.Schema, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*schemaCacheEntry).schema, true
+}
+
+// add caches schema, evicting the least recently used one when full
+func (c *schemaCache) add(key schemaKey, schema *jsonschema.Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&schemaCacheEntry{key: key, schema: schema})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*schemaCacheEntry).key)
+	}
+}
+
+func (c *schemaCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// reset empties the cache and bounds it to size
+func (c *schemaCache) reset(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.order.Init()
+	c.entries = make(map[schemaKey]*list.Element)
+}
+
+func (c *schemaCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[schemaKey]*list.Element)
+}
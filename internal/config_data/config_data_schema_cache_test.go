@@ -0,0 +1,179 @@
+package configdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func TestSchemaCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newSchemaCache(2)
+	a, b, d := schemaCacheKey("t", "a"), schemaCacheKey("t", "b"), schemaCacheKey("t", "d")
+	c.add(a, &jsonschema.Schema{})
+	c.add(b, &jsonschema.Schema{})
+	c.get(a)
+	c.add(d, &jsonschema.Schema{})
+
+	if _, ok := c.get(b); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if _, ok := c.get(a); !ok {
+		t.Fatal("expected a to be kept")
+	}
+	if _, ok := c.get(d); !ok {
+		t.Fatal("expected d to be kept")
+	}
+	if c.len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.len())
+	}
+}
+
+func TestSchemaCacheKey_ScopedByTenant(t *testing.T) {
+	if schemaCacheKey("a", "{}") == schemaCacheKey("b", "{}") {
+		t.Fatal("expected tenants to get different keys")
+	}
+	if schemaCacheKey("ab", "c") == schemaCacheKey("a", "bc") {
+		t.Fatal("expected the tenant and the schema to be delimited")
+	}
+}
+
+func TestCompileSchema_Cached(t *testing.T) {
+	SetSchemaCacheSize(2)
+	t.Cleanup(func() { SetSchemaCacheSize(0) })
+
+	schema := `{"type":"object"}`
+	first, err := compileSchema(testClientID, schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again, _ := compileSchema(testClientID, schema); again != first {
+		t.Fatal("expected the compiled schema to be reused")
+	}
+	if other, _ := compileSchema("other", schema); other == first {
+		t.Fatal("expected another tenant to compile its own")
+	}
+
+	// Invalid schemas are not cached
+	compileSchema(testClientID, `{"type":"nope"}`)
+	if compiledSchemas.len() != 2 {
+		t.Fatalf("expected 2 cached schemas, got %d", compiledSchemas.len())
+	}
+}
+
+func TestValidateInput_Concurrent(t *testing.T) {
+	schema := largeSchema(50)
+	valid, invalid := largeInput(50, 1), largeInput(50, 0)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := validateInput(testClientID, schema, valid); err != nil {
+				errs <- err
+			}
+			if err := validateInput(testClientID, schema, invalid); err == nil {
+				errs <- fmt.Errorf("expected an invalid input to fail")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// largeSchema has n object properties of a few constrained fields each
+func largeSchema(n int) string {
+	props := map[string]interface{}{}
+	var required []string
+	for i := 0; i < n; i++ {
+		name := "service_" + strconv.Itoa(i)
+		props[name] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"host":    map[string]interface{}{"type": "string", "pattern": "^[a-z0-9.-]+$", "maxLength": 253},
+				"port":    map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 65535},
+				"mode":    map[string]interface{}{"enum": []string{"active", "standby", "off"}},
+				"tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "uniqueItems": true},
+				"timeout": map[string]interface{}{"type": "number", "exclusiveMinimum": 0},
+			},
+			"required":             []string{"host", "port"},
+			"additionalProperties": false,
+		}
+		required = append(required, name)
+	}
+	b, _ := json.Marshal(map[string]interface{}{"type": "object", "properties": props, "required": required})
+	return string(b)
+}
+
+// largeInput matches largeSchema(n) unless port is out of range
+func largeInput(n, port int) string {
+	doc := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		doc["service_"+strconv.Itoa(i)] = map[string]interface{}{
+			"host": "db-" + strconv.Itoa(i) + ".internal", "port": port, "mode": "active",
+			"tags": []string{"a", "b"}, "timeout": 1.5,
+		}
+	}
+	b, _ := json.Marshal(doc)
+	return string(b)
+}
+
+func BenchmarkValidateInput_LargeSchema(b *testing.B) {
+	schema, input := largeSchema(200), largeInput(200, 5432)
+
+	b.Run("cached", func(b *testing.B) {
+		validateInput(testClientID, schema, input)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := validateInput(testClientID, schema, input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// What every write paid before compiled schemas were cached
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			compiledSchemas.clear()
+			if err := validateInput(testClientID, schema, input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkValidateInput_Parallel(b *testing.B) {
+	// A bulk load: many writes over a handful of schemas
+	schemas, inputs := make([]string, 8), make([]string, 8)
+	for i := range schemas {
+		schemas[i], inputs[i] = largeSchema(50+i), largeInput(50+i, 5432)
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if err := validateInput(testClientID, schemas[i%len(schemas)], inputs[i%len(inputs)]); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSchemaCacheKey(b *testing.B) {
+	schema := largeSchema(200)
+	b.SetBytes(int64(len(schema)))
+	for i := 0; i < b.N; i++ {
+		schemaCacheKey(testClientID, schema)
+	}
+}
@@ -0,0 +1,170 @@
+package configdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// FormatFunc reports whether v is of a format. Formats only apply to
+// strings, so it returns true for any other JSON value.
+type FormatFunc func(v interface{}) bool
+
+// Keyword is an x- keyword of config schemas, like {"x-min-duration": "1s"}.
+// Compile is given the keyword's value once, when a schema using it is
+// compiled, and fails when that value is invalid. Both the value and what
+// KeywordFunc checks are decoded as by json.Unmarshal, numbers are float64.
+type Keyword interface {
+	Compile(arg interface{}) (KeywordFunc, error)
+}
+
+// KeywordCompiler is a func used as a Keyword
+type KeywordCompiler func(arg interface{}) (KeywordFunc, error)
+
+func (f KeywordCompiler) Compile(arg interface{}) (KeywordFunc, error) {
+	return f(arg)
+}
+
+// KeywordFunc checks a value the keyword applies to. The error, if any,
+// is the message of the violation.
+type KeywordFunc func(v interface{}) error
+
+var keywordNamePattern = regexp.MustCompile(`^x-[A-Za-z0-9_-]+$`)
+
+var (
+	extMu    sync.RWMutex
+	formats  = map[string]FormatFunc{}
+	keywords = map[string]Keyword{}
+)
+
+// RegisterFormat makes the "format" keyword assert name with f, replacing
+// any format of that name, built-in ones included
+func RegisterFormat(name string, f FormatFunc) {
+	if name == "" || f == nil {
+		panic("configdata: RegisterFormat needs a name and a FormatFunc")
+	}
+	extMu.Lock()
+	formats[name] = f
+	extMu.Unlock()
+	compiledSchemas.clear()
+}
+
+// RegisterKeyword adds the keyword name to config schemas, replacing any
+// keyword of that name. Names must start with "x-".
+func RegisterKeyword(name string, k Keyword) {
+	if !keywordNamePattern.MatchString(name) || k == nil {
+		panic(fmt.Sprintf("configdata: RegisterKeyword(%q) needs an x- name and a Keyword", name))
+	}
+	extMu.Lock()
+	keywords[name] = k
+	extMu.Unlock()
+	compiledSchemas.clear()
+}
+
+// isRegisteredKeyword tells the compatibility check which keywords only
+// ever constrain inputs
+func isRegisteredKeyword(name string) bool {
+	extMu.RLock()
+	defer extMu.RUnlock()
+	_, ok := keywords[name]
+	return ok
+}
+
+// useExtensions adds the registered formats and keywords to c. Registered
+// formats are always asserted, standard ones only where the schema's draft
+// asserts them, as before.
+func useExtensions(c *jsonschema.Compiler) {
+	extMu.RLock()
+	defer extMu.RUnlock()
+	registered := make(map[string]FormatFunc, len(formats))
+	for name, f := range formats {
+		registered[name] = f
+		// Where the draft asserts formats, leave the registered ones to
+		// formatCompiler so they are checked once
+		c.Formats[name] = func(interface{}) bool { return true }
+	}
+	c.RegisterExtension("format", nil, formatCompiler(registered))
+	for name, k := range keywords {
+		c.RegisterExtension(name, nil, keywordCompiler{name: name, keyword: k})
+	}
+}
+
+// formatCompiler asserts the "format" keyword when it names a registered format
+type formatCompiler map[string]FormatFunc
+
+func (fc formatCompiler) Compile(_ jsonschema.CompilerContext, m map[string]interface{}) (jsonschema.ExtSchema, error) {
+	name, _ := m["format"].(string)
+	f, ok := fc[name]
+	if !ok {
+		return nil, nil
+	}
+	return formatSchema{name: name, check: f}, nil
+}
+
+type formatSchema struct {
+	name  string
+	check FormatFunc
+}
+
+func (fs formatSchema) Validate(ctx jsonschema.ValidationContext, v interface{}) error {
+	if !fs.check(v) {
+		return ctx.Error("format", "'%v' is not valid '%s'", v, fs.name)
+	}
+	return nil
+}
+
+type keywordCompiler struct {
+	name    string
+	keyword Keyword
+}
+
+func (kc keywordCompiler) Compile(_ jsonschema.CompilerContext, m map[string]interface{}) (jsonschema.ExtSchema, error) {
+	arg, ok := m[kc.name]
+	if !ok {
+		return nil, nil
+	}
+	check, err := kc.keyword.Compile(fromJSONNumbers(arg))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", kc.name, err)
+	}
+	return keywordSchema{name: kc.name, check: check}, nil
+}
+
+type keywordSchema struct {
+	name  string
+	check KeywordFunc
+}
+
+func (ks keywordSchema) Validate(ctx jsonschema.ValidationContext, v interface{}) error {
+	if err := ks.check(v); err != nil {
+		return ctx.Error(ks.name, "%s", err.Error())
+	}
+	return nil
+}
+
+// fromJSONNumbers turns the json.Numbers the compiler decodes schemas with
+// into float64s
+func fromJSONNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = fromJSONNumbers(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = fromJSONNumbers(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
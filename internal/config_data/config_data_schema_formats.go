@@ -0,0 +1,334 @@
+package configdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template/parse"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Formats and keywords for what configs usually hold, on top of the
+// standard formats of JSON Schema
+func init() {
+	RegisterFormat("duration", isDuration)
+	RegisterFormat("byte-size", isByteSize)
+	RegisterFormat("cron", isCron)
+	RegisterFormat("cidr", isCIDR)
+	RegisterFormat("host-port", isHostPort)
+	RegisterFormat("go-template", isGoTemplate)
+
+	RegisterKeyword("x-min-duration", durationBound(false))
+	RegisterKeyword("x-max-duration", durationBound(true))
+	RegisterKeyword("x-min-bytes", byteSizeBound(false))
+	RegisterKeyword("x-max-bytes", byteSizeBound(true))
+	RegisterKeyword("x-unique-by", KeywordCompiler(compileUniqueBy))
+}
+
+// isDuration accepts Go durations like 1h30m as well as the ISO 8601
+// durations of the standard format
+func isDuration(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return true
+	}
+	if _, err := time.ParseDuration(s); err == nil {
+		return true
+	}
+	return jsonschema.Formats["duration"](s)
+}
+
+var byteSizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?) ?([A-Za-z]*)$`)
+
+var byteUnits = map[string]float64{
+	"": 1, "B": 1,
+	"K": 1e3, "KB": 1e3, "M": 1e6, "MB": 1e6, "G": 1e9, "GB": 1e9, "T": 1e12, "TB": 1e12, "P": 1e15, "PB": 1e15,
+	"KI": 1 << 10, "KIB": 1 << 10, "MI": 1 << 20, "MIB": 1 << 20, "GI": 1 << 30, "GIB": 1 << 30,
+	"TI": 1 << 40, "TIB": 1 << 40, "PI": 1 << 50, "PIB": 1 << 50,
+}
+
+// parseByteSize reads sizes like 512MiB, 1.5GB or 64k, in bytes
+func parseByteSize(s string) (float64, bool) {
+	m := byteSizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	unit, ok := byteUnits[strings.ToUpper(m[2])]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return n * unit, true
+}
+
+func isByteSize(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return true
+	}
+	_, ok = parseByteSize(s)
+	return ok
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+	any      bool // "?" is allowed
+}
+
+var (
+	cronSeconds = cronField{min: 0, max: 59}
+	cronFields  = []cronField{
+		{min: 0, max: 59},
+		{min: 0, max: 23},
+		{min: 1, max: 31, any: true},
+		{min: 1, max: 12, names: map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+			"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}},
+		{min: 0, max: 7, any: true, names: map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}},
+	}
+	cronDescriptors = map[string]bool{"@yearly": true, "@annually": true, "@monthly": true, "@weekly": true,
+		"@daily": true, "@midnight": true, "@hourly": true}
+)
+
+// isCron accepts five field cron expressions, six with seconds first, the
+// @daily kind of descriptors and @every <Go duration>
+func isCron(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return true
+	}
+	if every, ok := strings.CutPrefix(s, "@every "); ok {
+		d, err := time.ParseDuration(every)
+		return err == nil && d > 0
+	}
+	if cronDescriptors[s] {
+		return true
+	}
+
+	fields := strings.Fields(s)
+	specs := cronFields
+	if len(fields) == 6 {
+		specs = append([]cronField{cronSeconds}, cronFields...)
+	}
+	if len(fields) != len(specs) {
+		return false
+	}
+	for i, f := range fields {
+		if !specs[i].valid(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cf cronField) valid(field string) bool {
+	for _, part := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			if n, err := strconv.Atoi(step); err != nil || n < 1 {
+				return false
+			}
+		}
+		if rng == "*" || rng == "?" && cf.any && !hasStep {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(rng, "-")
+		from, ok := cf.value(lo)
+		if !ok {
+			return false
+		}
+		if isRange {
+			to, ok := cf.value(hi)
+			if !ok || to < from {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (cf cronField) value(s string) (int, bool) {
+	if n, ok := cf.names[strings.ToUpper(s)]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || s[0] == '+' || s[0] == '-' || n < cf.min || n > cf.max {
+		return 0, false
+	}
+	return n, true
+}
+
+func isCIDR(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// isHostPort accepts a hostname or an IP address with a port, IPv6
+// addresses in brackets: db.internal:5432, [::1]:8080
+func isHostPort(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return true
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || host == "" {
+		return false
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return false
+	}
+	return net.ParseIP(host) != nil || jsonschema.Formats["hostname"](host)
+}
+
+// isGoTemplate checks the syntax of a text/template. Functions are not
+// checked, templates may be executed with any.
+func isGoTemplate(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return true
+	}
+	t := parse.New("config")
+	t.Mode = parse.SkipFuncCheck
+	_, err := t.Parse(s, "", "", map[string]*parse.Tree{})
+	return err == nil
+}
+
+// durationBound compares Go durations with the x-min-duration or
+// x-max-duration of a schema
+func durationBound(max bool) Keyword {
+	return KeywordCompiler(func(arg interface{}) (KeywordFunc, error) {
+		s, _ := arg.(string)
+		bound, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, errors.New("expected a duration like 30s")
+		}
+		return func(v interface{}) error {
+			s, ok := v.(string)
+			if !ok {
+				return nil
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("%q is not a duration", s)
+			}
+			if max && d > bound {
+				return fmt.Errorf("must be <= %s but found %s", bound, s)
+			}
+			if !max && d < bound {
+				return fmt.Errorf("must be >= %s but found %s", bound, s)
+			}
+			return nil
+		}, nil
+	})
+}
+
+// byteSizeBound compares sizes, numbers of bytes or byte-size strings, with
+// the x-min-bytes or x-max-bytes of a schema
+func byteSizeBound(max bool) Keyword {
+	return KeywordCompiler(func(arg interface{}) (KeywordFunc, error) {
+		bound, ok := byteSize(arg)
+		if !ok {
+			return nil, errors.New("expected a number of bytes or a size like 512MiB")
+		}
+		return func(v interface{}) error {
+			n, ok := byteSize(v)
+			if !ok {
+				if s, isString := v.(string); isString {
+					return fmt.Errorf("%q is not a byte size", s)
+				}
+				return nil
+			}
+			if max && n > bound {
+				return fmt.Errorf("must be <= %s but found %s", formatSize(arg), formatSize(v))
+			}
+			if !max && n < bound {
+				return fmt.Errorf("must be >= %s but found %s", formatSize(arg), formatSize(v))
+			}
+			return nil
+		}, nil
+	})
+}
+
+func byteSize(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, v >= 0
+	case string:
+		return parseByteSize(v)
+	}
+	return 0, false
+}
+
+// compileUniqueBy reads x-unique-by, a property or a list of properties
+// that no two objects of an array may share the values of
+func compileUniqueBy(arg interface{}) (KeywordFunc, error) {
+	var props []string
+	switch arg := arg.(type) {
+	case string:
+		props = []string{arg}
+	case []interface{}:
+		for _, p := range arg {
+			s, ok := p.(string)
+			if !ok {
+				props = nil
+				break
+			}
+			props = append(props, s)
+		}
+	}
+	if len(props) == 0 {
+		return nil, errors.New("expected a property name or a list of them")
+	}
+
+	return func(v interface{}) error {
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		seen := make(map[string]int, len(items))
+		for i, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			values := make([]interface{}, len(props))
+			complete := true
+			for j, p := range props {
+				values[j], complete = obj[p]
+				if !complete {
+					break
+				}
+			}
+			if !complete {
+				continue
+			}
+			key, _ := json.Marshal(values)
+			if first, dup := seen[string(key)]; dup {
+				return fmt.Errorf("items at %d and %d have the same %s", first, i, strings.Join(props, ", "))
+			}
+			seen[string(key)] = i
+		}
+		return nil
+	}, nil
+}
+
+// formatSize prints a size as it was given
+func formatSize(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
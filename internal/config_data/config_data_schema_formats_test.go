@@ -0,0 +1,195 @@
+package configdata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		valid  []string
+		bad    []string
+	}{
+		{"duration", []string{"30s", "1h30m", "250ms", "P1DT2H"}, []string{"30", "1 hour", "P"}},
+		{"byte-size", []string{"512MiB", "1.5GB", "64k", "100", "2 GiB"}, []string{"MiB", "12XB", "-1KB", "1,5GB"}},
+		{"cron", []string{"*/5 * * * *", "0 9-17 * * MON-FRI", "0 0 1,15 * ?", "30 0 0 * * *", "@daily", "@every 90s"},
+			[]string{"* * * *", "60 * * * *", "0 0 0 * *", "0 9-7 * * *", "*/0 * * * *", "@every 0s", "@often"}},
+		{"cidr", []string{"10.0.0.0/8", "2001:db8::/32"}, []string{"10.0.0.0", "10.0.0.0/33"}},
+		{"host-port", []string{"db.internal:5432", "127.0.0.1:80", "[::1]:8080"}, []string{"db.internal", ":8080", "db:0", "db:65536", "db:+80", "bad_host:80"}},
+		{"go-template", []string{"Hello {{ .Name }}", `{{ range .Items }}{{ upper . }}{{ end }}`, "plain"}, []string{"{{ .Name ", "{{ end }}"}},
+	}
+	for _, tt := range tests {
+		schema := fmt.Sprintf(`{"type":"string","format":%q}`, tt.format)
+		for _, v := range tt.valid {
+			if err := validateInput(testClientID, schema, jsonString(v)); err != nil {
+				t.Errorf("%s: expected %q to be valid, got %v", tt.format, v, err)
+			}
+		}
+		for _, v := range tt.bad {
+			if err := validateInput(testClientID, schema, jsonString(v)); !errors.Is(err, ErrInputMismatch) {
+				t.Errorf("%s: expected %q to be rejected, got %v", tt.format, v, err)
+			}
+		}
+	}
+}
+
+func TestFormats_ExistingSchemas(t *testing.T) {
+	// 2019-09 and later schemas only annotate standard formats, they keep
+	// validating as before formats were registered
+	const annotated = `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object",
+		"properties":{"contact":{"type":"string","format":"email"},"site":{"format":"uri"}}}`
+	if err := validateInput(testClientID, annotated, `{"contact":"ops team","site":"see wiki"}`); err != nil {
+		t.Fatalf("expected standard formats not to be asserted, got %v", err)
+	}
+	// Schemas without $schema always had them asserted
+	if err := validateInput(testClientID, `{"format":"email"}`, `"not an email"`); !errors.Is(err, ErrInputMismatch) {
+		t.Fatalf("expected ErrInputMismatch, got %v", err)
+	}
+	// Formats only apply to strings
+	if err := validateInput(testClientID, `{"format":"cidr"}`, `42`); err != nil {
+		t.Fatalf("expected a number to pass, got %v", err)
+	}
+}
+
+func TestFormats_RegisteredAsserted(t *testing.T) {
+	for _, draft := range []string{"https://json-schema.org/draft/2020-12/schema", "http://json-schema.org/draft-07/schema#"} {
+		schema := fmt.Sprintf(`{"$schema":%q,"format":"duration"}`, draft)
+		if err := validateInput(testClientID, schema, `"1h30m"`); err != nil {
+			t.Fatalf("%s: expected a Go duration to be valid, got %v", draft, err)
+		}
+		var ve *ValidationError
+		if err := validateInput(testClientID, schema, `"soon"`); !errors.As(err, &ve) || len(ve.Violations) != 1 {
+			t.Fatalf("%s: expected one violation, got %v", draft, err)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	const schema = `{"type":"object","properties":{
+		"timeout":{"type":"string","x-min-duration":"1s","x-max-duration":"1m"},
+		"cache":{"x-min-bytes":"1MiB","x-max-bytes":1073741824},
+		"upstreams":{"type":"array","x-unique-by":["host","port"]}}}`
+
+	tests := []struct {
+		input      string
+		violations []Violation
+	}{
+		{`{"timeout":"30s","cache":"512MiB","upstreams":[{"host":"a","port":1},{"host":"a","port":2},{"host":"b"},{"host":"b"}]}`, nil},
+		{`{"cache":1048576}`, nil},
+		{`{"timeout":"500ms","cache":"2GiB"}`, []Violation{
+			{"/cache", "/properties/cache/x-max-bytes", "must be <= 1073741824 but found 2GiB"},
+			{"/timeout", "/properties/timeout/x-min-duration", "must be >= 1s but found 500ms"},
+		}},
+		{`{"timeout":"2m","cache":1024}`, []Violation{
+			{"/cache", "/properties/cache/x-min-bytes", "must be >= 1MiB but found 1024"},
+			{"/timeout", "/properties/timeout/x-max-duration", "must be <= 1m0s but found 2m"},
+		}},
+		{`{"timeout":"soon"}`, []Violation{
+			{"/timeout", "/properties/timeout/x-max-duration", `"soon" is not a duration`},
+			{"/timeout", "/properties/timeout/x-min-duration", `"soon" is not a duration`},
+		}},
+		{`{"upstreams":[{"host":"a","port":1},{"host":"b","port":1},{"host":"a","port":1}]}`, []Violation{
+			{"/upstreams", "/properties/upstreams/x-unique-by", "items at 0 and 2 have the same host, port"},
+		}},
+	}
+	for _, tt := range tests {
+		err := validateInput(testClientID, schema, tt.input)
+		if tt.violations == nil {
+			if err != nil {
+				t.Errorf("%s: expected a match, got %v", tt.input, err)
+			}
+			continue
+		}
+		var ve *ValidationError
+		if !errors.As(err, &ve) || !errors.Is(err, ErrInputMismatch) {
+			t.Errorf("%s: expected ErrInputMismatch, got %v", tt.input, err)
+			continue
+		}
+		if len(ve.Violations) != len(tt.violations) {
+			t.Errorf("%s: expected %d violations, got %+v", tt.input, len(tt.violations), ve.Violations)
+			continue
+		}
+		for i, v := range ve.Violations {
+			if v != tt.violations[i] {
+				t.Errorf("%s: expected %+v, got %+v", tt.input, tt.violations[i], v)
+			}
+		}
+	}
+}
+
+func TestKeywords_InvalidArgument(t *testing.T) {
+	for _, schema := range []string{
+		`{"x-min-duration":"soon"}`,
+		`{"x-max-bytes":"lots"}`,
+		`{"x-unique-by":[]}`,
+		`{"items":{"x-unique-by":[1]}}`,
+	} {
+		err := validateInput(testClientID, schema, `1`)
+		if !errors.Is(err, ErrInvalidSchema) || !strings.Contains(err.Error(), "invalid x-") {
+			t.Errorf("%s: expected ErrInvalidSchema, got %v", schema, err)
+		}
+	}
+}
+
+type evenKeyword struct{}
+
+func (evenKeyword) Compile(arg interface{}) (KeywordFunc, error) {
+	if arg != true {
+		return nil, errors.New("expected true")
+	}
+	return func(v interface{}) error {
+		if n, ok := v.(float64); ok && int(n)%2 != 0 {
+			return fmt.Errorf("%v is odd", n)
+		}
+		return nil
+	}, nil
+}
+
+func TestRegisterFormatAndKeyword(t *testing.T) {
+	t.Cleanup(func() {
+		extMu.Lock()
+		delete(formats, "test-upper")
+		delete(keywords, "x-test-even")
+		extMu.Unlock()
+		compiledSchemas.clear()
+	})
+	const schema = `{"properties":{"name":{"format":"test-upper"},"n":{"x-test-even":true}}}`
+
+	// Unknown formats and x- keywords are ignored until registered
+	if err := validateInput(testClientID, schema, `{"name":"abc","n":3}`); err != nil {
+		t.Fatalf("expected unregistered extensions to be ignored, got %v", err)
+	}
+
+	RegisterFormat("test-upper", func(v interface{}) bool {
+		s, ok := v.(string)
+		return !ok || s == strings.ToUpper(s)
+	})
+	RegisterKeyword("x-test-even", evenKeyword{})
+
+	err := validateInput(testClientID, schema, `{"name":"abc","n":3}`)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", err)
+	}
+	if ve.Violations[0].KeywordPath != "/properties/n/x-test-even" || ve.Violations[0].Message != "3 is odd" {
+		t.Fatalf("unexpected violation %+v", ve.Violations[0])
+	}
+	if ve.Violations[1].KeywordPath != "/properties/name/format" {
+		t.Fatalf("unexpected violation %+v", ve.Violations[1])
+	}
+	if err := validateInput(testClientID, schema, `{"name":"ABC","n":4}`); err != nil {
+		t.Fatalf("expected a match, got %v", err)
+	}
+}
+
+func TestRegisterKeyword_InvalidName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a name without x-")
+		}
+	}()
+	RegisterKeyword("even", evenKeyword{})
+}
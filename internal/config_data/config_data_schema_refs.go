@@ -0,0 +1,101 @@
+package configdata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Schemas are compiled as resources of this base URL, so a schema refers to
+// a registered one by the path of its API resource:
+//
+//	{"$ref": "/schemas/database/versions/2"}
+//
+// Nothing else can be referenced, no file or network is ever read.
+const (
+	schemaBaseURL   = "configsvc:///"
+	configSchemaURL = schemaBaseURL + "configs/schema.json"
+)
+
+var ErrSchemaNotRegistered = errors.New("schema is not registered")
+
+// SchemaRegistry returns the registered schemas that $refs point to.
+// LookupSchema fails with ErrSchemaNotRegistered for unknown versions.
+type SchemaRegistry interface {
+	LookupSchema(clientID, id string, version int) (string, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   SchemaRegistry
+)
+
+// UseSchemaRegistry makes $refs to registered schemas resolve against r.
+// Without one they fail like refs to unknown versions.
+func UseSchemaRegistry(r SchemaRegistry) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = r
+	// What schemas with $refs compiled to came from the previous registry
+	compiledSchemas.clear()
+}
+
+func currentRegistry() SchemaRegistry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry
+}
+
+// RegisteredSchemaURL is the absolute URL a registered schema version is
+// compiled as
+func RegisteredSchemaURL(id string, version int) string {
+	return schemaBaseURL + "schemas/" + url.PathEscape(id) + "/versions/" + strconv.Itoa(version)
+}
+
+// parseRegisteredSchemaURL is the reverse of RegisteredSchemaURL
+func parseRegisteredSchemaURL(s string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(s, schemaBaseURL+"schemas/")
+	if !ok {
+		return "", 0, false
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || parts[1] != "versions" {
+		return "", 0, false
+	}
+	id, err := url.PathUnescape(parts[0])
+	if err != nil || id == "" {
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(parts[2])
+	if err != nil || version < 1 {
+		return "", 0, false
+	}
+	return id, version, true
+}
+
+// schemaLoader loads the registered schemas of a tenant, the only documents
+// a schema may refer to
+func schemaLoader(clientID string) func(string) (io.ReadCloser, error) {
+	return func(s string) (io.ReadCloser, error) {
+		id, version, ok := parseRegisteredSchemaURL(s)
+		if !ok {
+			return nil, fmt.Errorf("%s cannot be referenced, only registered schemas like /schemas/{id}/versions/{version}", s)
+		}
+		reg := currentRegistry()
+		if reg == nil {
+			return nil, fmt.Errorf("%w: %s version %d", ErrSchemaNotRegistered, id, version)
+		}
+		schema, err := reg.LookupSchema(clientID, id, version)
+		if err != nil {
+			if errors.Is(err, ErrSchemaNotRegistered) {
+				return nil, fmt.Errorf("%w: %s version %d", ErrSchemaNotRegistered, id, version)
+			}
+			return nil, err
+		}
+		return io.NopCloser(strings.NewReader(schema)), nil
+	}
+}
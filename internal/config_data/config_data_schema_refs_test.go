@@ -0,0 +1,106 @@
+package configdata
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sass.com/configsvc/internal/models"
+)
+
+// mapRegistry holds schemas by tenant, id and version
+type mapRegistry map[string]string
+
+func (m mapRegistry) LookupSchema(clientID, id string, version int) (string, error) {
+	schema, ok := m[clientID+"/"+id+"/"+strconv.Itoa(version)]
+	if !ok {
+		return "", ErrSchemaNotRegistered
+	}
+	return schema, nil
+}
+
+func useTestRegistry(t *testing.T, r SchemaRegistry) {
+	UseSchemaRegistry(r)
+	t.Cleanup(func() { UseSchemaRegistry(nil) })
+}
+
+func TestValidateInput_RegisteredSchemaRef(t *testing.T) {
+	useTestRegistry(t, mapRegistry{
+		testClientID + "/port/1":     `{"type":"integer","minimum":1,"maximum":65535}`,
+		testClientID + "/database/1": `{"type":"object","properties":{"host":{"type":"string"},"port":{"$ref":"../../port/versions/1"}},"required":["host"]}`,
+	})
+	schema := `{"type":"object","properties":{"db":{"$ref":"/schemas/database/versions/1"}}}`
+
+	if err := validateInput(testClientID, schema, `{"db":{"host":"x","port":5432}}`); err != nil {
+		t.Fatalf("expected input to match, got %v", err)
+	}
+
+	err := validateInput(testClientID, schema, `{"db":{"port":0}}`)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || !errors.Is(err, ErrInputMismatch) {
+		t.Fatalf("expected ErrInputMismatch, got %v", err)
+	}
+	if len(ve.Violations) != 2 || ve.Violations[0].InstancePath != "/db" || ve.Violations[1].InstancePath != "/db/port" {
+		t.Fatalf("expected violations of /db and /db/port, got %+v", ve.Violations)
+	}
+}
+
+func TestIsValidInput_DefaultTenantRefs(t *testing.T) {
+	useTestRegistry(t, mapRegistry{
+		models.DefaultClientID + "/port/1": `{"type":"integer","minimum":1}`,
+		testClientID + "/port/2":           `{"type":"integer"}`,
+	})
+
+	if !isValidInput(`{"$ref":"/schemas/port/versions/1"}`, `8080`) {
+		t.Fatal("expected a schema of the default tenant to resolve")
+	}
+	if isValidInput(`{"$ref":"/schemas/port/versions/1"}`, `0`) {
+		t.Fatal("expected the input to be checked against the default tenant's schema")
+	}
+	if isValidInput(`{"$ref":"/schemas/port/versions/2"}`, `8080`) {
+		t.Fatal("expected schemas of other tenants not to resolve")
+	}
+}
+
+func TestValidateInput_UnresolvableRefs(t *testing.T) {
+	useTestRegistry(t, mapRegistry{"other-tenant/port/1": `{"type":"integer"}`})
+
+	tests := []struct {
+		name, schema, msg string
+	}{
+		{"other tenant", `{"$ref":"/schemas/port/versions/1"}`, "not registered"},
+		{"unknown version", `{"$ref":"/schemas/port/versions/2"}`, "not registered"},
+		{"file", `{"$ref":"file:///etc/passwd"}`, "cannot be referenced"},
+		{"network", `{"$ref":"https://example.com/schema.json"}`, "cannot be referenced"},
+		{"other path", `{"$ref":"/configs/secrets"}`, "cannot be referenced"},
+	}
+	for _, tt := range tests {
+		err := validateInput(testClientID, tt.schema, `1`)
+		var ve *ValidationError
+		if !errors.As(err, &ve) || !errors.Is(err, ErrInvalidSchema) {
+			t.Errorf("%s: expected ErrInvalidSchema, got %v", tt.name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: expected %q in %v", tt.name, tt.msg, err)
+		}
+	}
+}
+
+func TestParseRegisteredSchemaURL(t *testing.T) {
+	id, version, ok := parseRegisteredSchemaURL(RegisteredSchemaURL("db.main", 3))
+	if !ok || id != "db.main" || version != 3 {
+		t.Fatalf("expected db.main version 3, got %q %d %v", id, version, ok)
+	}
+	for _, s := range []string{
+		"configsvc:///schemas/db/versions/0",
+		"configsvc:///schemas/db/versions/x",
+		"configsvc:///schemas/db",
+		"configsvc:///configs/schema.json",
+	} {
+		if _, _, ok := parseRegisteredSchemaURL(s); ok {
+			t.Errorf("expected %s not to parse", s)
+		}
+	}
+}
@@ -1,44 +1,105 @@
 package configdata
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"sass.com/configsvc/internal/cache"
 	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
 )
 
+var (
+	ErrConfigNotFound   = errors.New("config not found")
+	ErrConfigDeleted    = errors.New("config is deleted")
+	ErrConfigNotDeleted = errors.New("config is not deleted")
+	ErrVersionNotFound  = errors.New("config version not found")
+	ErrInputMismatch    = errors.New("input does not match the schema")
+	ErrVersionConflict  = errors.New("config version has changed")
+)
+
+// ConfigDiff compares the Input of two versions of a config
+type ConfigDiff struct {
+	Name    string    `json:"name"`
+	From    int       `json:"from"`
+	To      int       `json:"to"`
+	Patch   []PatchOp `json:"patch"`
+	Summary []Change  `json:"summary"`
+}
+
+// WritePreview is what storing a config would do, see PreviewWrite
+type WritePreview struct {
+	// Latest is the current latest version, deleted or not, nil for new configs
+	Latest *models.LastConfigurations
+	// Version is the version the write would store
+	Version int
+	Diff    *ConfigDiff
+}
+
 type ConfigService interface {
 	Create(cfg *models.Configurations) error
+	CreateVersion(cfg *models.Configurations, expectedVersion int) error
+	PreviewWrite(cfg *models.Configurations, expectedVersion int) (*WritePreview, error)
 	Update(cfg *models.Configurations) error
-	RollbackConfig(cfg *models.Configurations) error
-	GetLastVersionByName(name string) (*models.LastConfigurations, error)
-	GetByNameByVersion(name string, version int) (*models.Configurations, error)
-	GetConfigVersions(name string) ([]models.Configurations, error)
+	RollbackConfig(cfg *models.Configurations, expectedVersion int) error
+	GetLastVersionByName(clientID, name string) (*models.LastConfigurations, error)
+	GetByNameByVersion(clientID, name string, version int) (*models.Configurations, error)
+	GetConfigVersions(clientID, name string, limit int, cursor string) (*VersionList, error)
+	ListConfigs(q ListQuery) (*ConfigList, error)
+	DeleteConfig(clientID, name, actor string) error
+	RestoreConfig(clientID, name, actor string) (*models.LastConfigurations, error)
+	PurgeConfig(clientID, name, actor string) error
+	GetConfigEvents(clientID, name string) ([]models.ConfigEvent, error)
+	DiffVersions(clientID, name string, from, to int) (*ConfigDiff, error)
+	PatchConfig(clientID, name, patchType string, patch []byte, expectedVersion int, actor string) (*models.Configurations, error)
+	WatchConfig(ctx context.Context, clientID, name string, afterVersion int) (*models.LastConfigurations, error)
+	WaitForEvents(ctx context.Context, q EventQuery) ([]models.ConfigEvent, error)
+	LastEventID() (uint, error)
 }
 
-func NewConfigService(repo ConfigRepo) ConfigService {
-	return &ConfigServiceImpl{repo: repo}
+func NewConfigService(repo ConfigRepo, notifier *notify.Notifier) ConfigService {
+	return &ConfigServiceImpl{repo: repo, notifier: notifier}
 }
 
 type ConfigServiceImpl struct {
-	repo ConfigRepo
+	repo     ConfigRepo
+	notifier *notify.Notifier
 }
 
+// Create stores cfg as the next version of its config
 func (s *ConfigServiceImpl) Create(cfg *models.Configurations) error {
-	lastCfg, err := s.GetLastVersionByName(cfg.Name)
+	return s.CreateVersion(cfg, 0)
+}
+
+// CreateVersion only stores cfg while expectedVersion is the latest version,
+// otherwise it returns ErrVersionConflict. 0 accepts any version.
+func (s *ConfigServiceImpl) CreateVersion(cfg *models.Configurations, expectedVersion int) error {
+	return s.createVersion(cfg, expectedVersion, models.ConfigUpdated)
+}
+
+// RollbackConfig stores cfg, a copy of an older version, as the next version
+func (s *ConfigServiceImpl) RollbackConfig(cfg *models.Configurations, expectedVersion int) error {
+	return s.createVersion(cfg, expectedVersion, models.ConfigRolledBack)
+}
+
+func (s *ConfigServiceImpl) createVersion(cfg *models.Configurations, expectedVersion int, eventType models.ConfigEventType) error {
+	// Deleted configs still own their version numbers
+	lastCfg, err := s.lastConfig(cfg.ClientID, cfg.Name)
 	if err != nil {
 		return err
 	}
-
-	nextVersion := 1
-	if lastCfg != nil {
-		nextVersion = lastCfg.Version + 1
+	if lastCfg != nil && lastCfg.IsActive == 0 {
+		return ErrConfigDeleted
+	}
+	if lastCfg == nil {
+		eventType = models.ConfigCreated
 	}
 
 	cfg.ID = uuid.New()
-	cfg.Version = nextVersion
 
 	newLastCfg := &models.LastConfigurations{
 		ID:        uuid.New(),
@@ -47,37 +108,114 @@ func (s *ConfigServiceImpl) Create(cfg *models.Configurations) error {
 		Type:      cfg.Type,
 		Schema:    cfg.Schema,
 		Input:     cfg.Input,
-		Version:   cfg.Version,
 		CreatedBy: cfg.CreatedBy,
 		IsActive:  1,
 	}
 
-	if err := s.repo.Create(cfg, newLastCfg); err != nil {
+	event := &models.ConfigEvent{ClientID: cfg.ClientID, Name: cfg.Name, Type: eventType, Actor: cfg.CreatedBy}
+
+	// The repo allocates the version, the cache may be stale
+	if err := s.repo.Create(cfg, newLastCfg, expectedVersion, event); err != nil {
 		return err
 	}
 
-	// Push new data to cache
-	cache.Put(cfg.Name, newLastCfg)
+	// Invalidate rather than put, a concurrent write may have committed a
+	// newer version already; watchers read it through once woken up
+	cache.Remove(cfg.ClientID, cfg.Name)
+	s.notifier.Notify(cfg.ClientID, cfg.Name)
 
 	return nil
 }
 
-func (s *ConfigServiceImpl) Update(cfg *models.Configurations) error {
+// PreviewWrite reports what CreateVersion would do with cfg without storing
+// anything. The preview is returned along with ErrConfigDeleted or
+// ErrVersionConflict when CreateVersion would fail with them. It reads the
+// database only, so a stale cache entry cannot hide a newer version.
+func (s *ConfigServiceImpl) PreviewWrite(cfg *models.Configurations, expectedVersion int) (*WritePreview, error) {
+	lastCfg, err := s.lastConfig(cfg.ClientID, cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	preview := &WritePreview{Latest: lastCfg, Version: 1}
 
-	return s.repo.Update(cfg)
+	var from interface{}
+	if lastCfg != nil {
+		preview.Version = lastCfg.Version + 1
+		if from, err = decodeJSON(lastCfg.Input); err != nil {
+			return nil, err
+		}
+	}
+	to, err := decodeJSON(cfg.Input)
+	if err != nil {
+		return nil, err
+	}
+	patch, summary := diffJSON(from, to)
+	preview.Diff = &ConfigDiff{Name: cfg.Name, From: preview.Version - 1, To: preview.Version, Patch: patch, Summary: summary}
+
+	switch {
+	case lastCfg != nil && lastCfg.IsActive == 0:
+		return preview, ErrConfigDeleted
+	case expectedVersion != 0 && (lastCfg == nil || lastCfg.Version != expectedVersion):
+		return preview, ErrVersionConflict
+	}
+	return preview, nil
 }
 
-func (s *ConfigServiceImpl) RollbackConfig(cfg *models.Configurations) error {
-	return s.repo.Create(cfg, nil)
+func (s *ConfigServiceImpl) Update(cfg *models.Configurations) error {
+
+	return s.repo.Update(cfg)
 }
 
-func (s *ConfigServiceImpl) GetLastVersionByName(name string) (*models.LastConfigurations, error) {
+// GetLastVersionByName returns nil, nil for missing and deleted configs
+func (s *ConfigServiceImpl) GetLastVersionByName(clientID, name string) (*models.LastConfigurations, error) {
 	// Get from cache first
-	if cacheData, ok := cache.Get(name); ok && cacheData != nil {
+	if cacheData, ok := cache.Get(clientID, name); ok && cacheData != nil {
 		return cacheData, nil
 	}
 
-	dbData, err := s.repo.GetLastConfig(name)
+	gen := cache.Generation(clientID, name)
+	dbData, err := s.lastConfig(clientID, name)
+	if err != nil || dbData == nil || dbData.IsActive == 0 {
+		return nil, err
+	}
+
+	// Push db data to cache unless a write removed the entry meanwhile, only
+	// active configs are cached
+	cache.Fill(clientID, name, dbData, gen)
+
+	return dbData, nil
+}
+
+// WatchConfig blocks until the config has a version newer than afterVersion
+// and returns it. Missing and deleted configs are waited for as well. It
+// returns nil, nil once ctx is done without a newer version.
+func (s *ConfigServiceImpl) WatchConfig(ctx context.Context, clientID, name string, afterVersion int) (*models.LastConfigurations, error) {
+	for {
+		// Subscribe before reading, a write in between must still wake us up
+		changed, release, err := s.notifier.Subscribe(clientID, name)
+		if err != nil {
+			return nil, err
+		}
+
+		latest, err := s.GetLastVersionByName(clientID, name)
+		if err != nil || (latest != nil && latest.Version > afterVersion) {
+			release()
+			return latest, err
+		}
+
+		select {
+		case <-changed:
+			release()
+		case <-ctx.Done():
+			release()
+			return nil, nil
+		}
+	}
+}
+
+// lastConfig reads the latest config from the DB, including deleted ones
+func (s *ConfigServiceImpl) lastConfig(clientID, name string) (*models.LastConfigurations, error) {
+	dbData, err := s.repo.GetLastConfig(clientID, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// First creation just return nil, nil
@@ -85,17 +223,11 @@ func (s *ConfigServiceImpl) GetLastVersionByName(name string) (*models.LastConfi
 		}
 		return nil, err
 	}
-
-	if dbData != nil {
-		// Push db data to cache
-		cache.Put(name, dbData)
-	}
-
 	return dbData, nil
 }
 
-func (s *ConfigServiceImpl) GetByNameByVersion(name string, version int) (*models.Configurations, error) {
-	cfg, err := s.repo.GetByNameByVersion(name, version)
+func (s *ConfigServiceImpl) GetByNameByVersion(clientID, name string, version int) (*models.Configurations, error) {
+	cfg, err := s.repo.GetByNameByVersion(clientID, name, version)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil // no such version: return nil, nil
@@ -105,6 +237,300 @@ func (s *ConfigServiceImpl) GetByNameByVersion(name string, version int) (*model
 	return cfg, nil
 }
 
-func (s *ConfigServiceImpl) GetConfigVersions(name string) ([]models.Configurations, error) {
-	return s.repo.GetConfigVersions(name)
+func (s *ConfigServiceImpl) GetConfigVersions(clientID, name string, limit int, cursor string) (*VersionList, error) {
+	var after versionCursor
+	if cursor != "" {
+		if err := decodeCursor(cursor, &after); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fetch one extra row to know whether another page follows
+	limit = pageSize(limit)
+	cfgs, err := s.repo.GetConfigVersions(clientID, name, after.After, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &VersionList{Items: cfgs}
+	if len(cfgs) > limit {
+		list.Items = cfgs[:limit]
+		list.NextCursor = encodeCursor(versionCursor{After: cfgs[limit-1].Version})
+	}
+	return list, nil
+}
+
+// ListConfigs returns a page of the tenant's latest configs restricted to q.Patterns.
+// Deleted configs are only listed when asked for with IsActive.
+func (s *ConfigServiceImpl) ListConfigs(q ListQuery) (*ConfigList, error) {
+	if q.IsActive == nil {
+		active := 1
+		q.IsActive = &active
+	}
+	if _, _, err := parseSort(q.Sort); err != nil {
+		return nil, err
+	}
+
+	var after *listCursor
+	if q.Cursor != "" {
+		after = &listCursor{}
+		if err := decodeCursor(q.Cursor, after); err != nil {
+			return nil, err
+		}
+		// A cursor is only meaningful for the sort order it was issued for
+		if after.Sort != q.Sort {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	limit := pageSize(q.Limit)
+	q.Limit = limit + 1
+	cfgs, err := s.repo.ListConfigs(q, after)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ConfigList{Items: cfgs}
+	if len(cfgs) > limit {
+		list.Items = cfgs[:limit]
+		list.NextCursor = encodeCursor(newListCursor(q.Sort, cfgs[limit-1]))
+	}
+	return list, nil
+}
+
+// DeleteConfig hides the config from reads, its history stays until purged
+func (s *ConfigServiceImpl) DeleteConfig(clientID, name, actor string) error {
+	lastCfg, err := s.lastConfig(clientID, name)
+	if err != nil {
+		return err
+	}
+	if lastCfg == nil {
+		return ErrConfigNotFound
+	}
+	if lastCfg.IsActive == 0 {
+		return ErrConfigDeleted
+	}
+
+	event := newConfigEvent(lastCfg, models.ConfigDeleted, actor)
+	updated, err := s.repo.SetActive(clientID, name, false, event)
+	if err != nil {
+		return err
+	}
+	cache.Remove(clientID, name)
+	if !updated {
+		// Deleted concurrently
+		return ErrConfigDeleted
+	}
+	s.notifier.Notify(clientID, name)
+	return nil
+}
+
+func (s *ConfigServiceImpl) RestoreConfig(clientID, name, actor string) (*models.LastConfigurations, error) {
+	lastCfg, err := s.lastConfig(clientID, name)
+	if err != nil {
+		return nil, err
+	}
+	if lastCfg == nil {
+		return nil, ErrConfigNotFound
+	}
+	if lastCfg.IsActive == 1 {
+		return nil, ErrConfigNotDeleted
+	}
+
+	event := newConfigEvent(lastCfg, models.ConfigRestored, actor)
+	updated, err := s.repo.SetActive(clientID, name, true, event)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrConfigNotDeleted
+	}
+
+	lastCfg.IsActive = 1
+	cache.Remove(clientID, name)
+	s.notifier.Notify(clientID, name)
+	return lastCfg, nil
+}
+
+// PurgeConfig permanently removes a deleted config with all of its versions
+func (s *ConfigServiceImpl) PurgeConfig(clientID, name, actor string) error {
+	lastCfg, err := s.lastConfig(clientID, name)
+	if err != nil {
+		return err
+	}
+	if lastCfg == nil {
+		return ErrConfigNotFound
+	}
+	// Purging is irreversible, so it needs a prior soft delete
+	if lastCfg.IsActive == 1 {
+		return ErrConfigNotDeleted
+	}
+
+	if err := s.repo.Purge(clientID, name, newConfigEvent(lastCfg, models.ConfigPurged, actor)); err != nil {
+		return err
+	}
+	cache.Remove(clientID, name)
+	s.notifier.Notify(clientID, name)
+	return nil
+}
+
+func (s *ConfigServiceImpl) GetConfigEvents(clientID, name string) ([]models.ConfigEvent, error) {
+	return s.repo.GetConfigEvents(clientID, name)
+}
+
+// WaitForEvents blocks until there are events matching q and returns up to
+// q.Limit of them. It returns nil, nil once ctx is done without new events.
+func (s *ConfigServiceImpl) WaitForEvents(ctx context.Context, q EventQuery) ([]models.ConfigEvent, error) {
+	q.Limit = pageSize(q.Limit)
+	for {
+		// Subscribe before reading, an event in between must still wake us up
+		changed, release, err := s.notifier.SubscribeTenant(q.ClientID)
+		if err != nil {
+			return nil, err
+		}
+
+		events, err := s.repo.ListEvents(q)
+		if err != nil || len(events) > 0 {
+			release()
+			return events, err
+		}
+
+		select {
+		case <-changed:
+			release()
+		case <-ctx.Done():
+			release()
+			return nil, nil
+		}
+	}
+}
+
+// LastEventID is where a stream starts that does not resume an earlier one
+func (s *ConfigServiceImpl) LastEventID() (uint, error) {
+	return s.repo.LastEventID()
+}
+
+func newConfigEvent(cfg *models.LastConfigurations, typ models.ConfigEventType, actor string) *models.ConfigEvent {
+	return &models.ConfigEvent{
+		ClientID: cfg.ClientID,
+		Name:     cfg.Name,
+		Type:     typ,
+		Version:  cfg.Version,
+		Actor:    actor,
+	}
+}
+
+// DiffVersions diffs version from against version to, or against the latest version when to is 0
+func (s *ConfigServiceImpl) DiffVersions(clientID, name string, from, to int) (*ConfigDiff, error) {
+	if to == 0 {
+		latest, err := s.GetLastVersionByName(clientID, name)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			return nil, ErrConfigNotFound
+		}
+		to = latest.Version
+	}
+
+	fromCfg, err := s.GetByNameByVersion(clientID, name, from)
+	if err != nil {
+		return nil, err
+	}
+	toCfg, err := s.GetByNameByVersion(clientID, name, to)
+	if err != nil {
+		return nil, err
+	}
+	if fromCfg == nil || toCfg == nil {
+		return nil, ErrVersionNotFound
+	}
+
+	fromDoc, err := decodeJSON(fromCfg.Input)
+	if err != nil {
+		return nil, err
+	}
+	toDoc, err := decodeJSON(toCfg.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, summary := diffJSON(fromDoc, toDoc)
+	return &ConfigDiff{Name: name, From: from, To: to, Patch: patch, Summary: summary}, nil
+}
+
+// How often PatchConfig reapplies a patch that lost a race against another write
+const maxPatchAttempts = 3
+
+// PatchConfig applies a merge patch or JSON patch to the latest Input and
+// stores the result as a new version. The schema never changes. Without an
+// expectedVersion the patch is reapplied when another write got in first.
+func (s *ConfigServiceImpl) PatchConfig(clientID, name, patchType string, patch []byte, expectedVersion int, actor string) (*models.Configurations, error) {
+	for attempt := 0; ; attempt++ {
+		cfg, err := s.patchLatest(clientID, name, patchType, patch, expectedVersion, actor)
+		if errors.Is(err, ErrVersionConflict) && expectedVersion == 0 && attempt < maxPatchAttempts {
+			continue
+		}
+		return cfg, err
+	}
+}
+
+func (s *ConfigServiceImpl) patchLatest(clientID, name, patchType string, patch []byte, expectedVersion int, actor string) (*models.Configurations, error) {
+	// Read from the DB, patching a stale cached Input would always conflict
+	lastCfg, err := s.lastConfig(clientID, name)
+	if err != nil {
+		return nil, err
+	}
+	if lastCfg == nil || lastCfg.IsActive == 0 {
+		return nil, ErrConfigNotFound
+	}
+	if expectedVersion != 0 && lastCfg.Version != expectedVersion {
+		return nil, ErrVersionConflict
+	}
+
+	doc, err := decodeJSON(lastCfg.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	switch patchType {
+	case MergePatchType:
+		mergePatch, err := decodeJSON(string(patch))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		doc = applyMergePatch(doc, mergePatch)
+	case JSONPatchType:
+		var ops []PatchOp
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		if doc, err = applyJSONPatch(doc, ops); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported patch type %q", ErrInvalidPatch, patchType)
+	}
+
+	input, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInput(clientID, lastCfg.Schema, string(input)); err != nil {
+		return nil, err
+	}
+
+	cfg := &models.Configurations{
+		ClientID:  clientID,
+		Name:      name,
+		Type:      lastCfg.Type,
+		Schema:    lastCfg.Schema,
+		Input:     string(input),
+		CreatedBy: actor,
+		IsActive:  1,
+	}
+	// The patch was computed from lastCfg, so only store it on top of that version
+	if err := s.CreateVersion(cfg, lastCfg.Version); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
@@ -1,6 +1,7 @@
 package configdata
 
 import (
+	"context"
 	"errors"
 	"os"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	"github.com/google/uuid"
 	"sass.com/configsvc/internal/cache"
 	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
 )
 
 // Ensure global cache is initialized before run test.
@@ -28,25 +30,43 @@ type mockConfigRepo struct {
 	versionsErr error
 }
 
-func (m *mockConfigRepo) Create(cfg *models.Configurations, last *models.LastConfigurations) error {
+func (m *mockConfigRepo) Create(cfg *models.Configurations, last *models.LastConfigurations, expectedVersion int, event *models.ConfigEvent) error {
 	return m.createErr
 }
 func (m *mockConfigRepo) Update(cfg *models.Configurations) error {
 	return m.updateErr
 }
-func (m *mockConfigRepo) GetLastConfig(name string) (*models.LastConfigurations, error) {
+func (m *mockConfigRepo) GetLastConfig(clientID, name string) (*models.LastConfigurations, error) {
 	return m.lastCfg, m.lastErr
 }
-func (m *mockConfigRepo) GetByNameByVersion(name string, version int) (*models.Configurations, error) {
+func (m *mockConfigRepo) GetByNameByVersion(clientID, name string, version int) (*models.Configurations, error) {
 	return m.byVerCfg, m.byVerErr
 }
-func (m *mockConfigRepo) GetConfigVersions(name string) ([]models.Configurations, error) {
+func (m *mockConfigRepo) GetConfigVersions(clientID, name string, afterVersion, limit int) ([]models.Configurations, error) {
 	return m.versions, m.versionsErr
 }
+func (m *mockConfigRepo) ListConfigs(q ListQuery, after *listCursor) ([]models.LastConfigurations, error) {
+	return nil, nil
+}
+func (m *mockConfigRepo) SetActive(clientID, name string, active bool, event *models.ConfigEvent) (bool, error) {
+	return true, nil
+}
+func (m *mockConfigRepo) Purge(clientID, name string, event *models.ConfigEvent) error {
+	return nil
+}
+func (m *mockConfigRepo) GetConfigEvents(clientID, name string) ([]models.ConfigEvent, error) {
+	return nil, nil
+}
+func (m *mockConfigRepo) ListEvents(q EventQuery) ([]models.ConfigEvent, error) {
+	return nil, nil
+}
+func (m *mockConfigRepo) LastEventID() (uint, error) {
+	return 0, nil
+}
 
 func TestConfigService_Create_Success(t *testing.T) {
 	mockRepo := &mockConfigRepo{}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
 	cfg := &models.Configurations{ID: uuid.New(), Name: "feature_flag", Version: 1}
 	if err := svc.Create(cfg); err != nil {
@@ -56,7 +76,7 @@ func TestConfigService_Create_Success(t *testing.T) {
 
 func TestConfigService_Create_Error(t *testing.T) {
 	mockRepo := &mockConfigRepo{createErr: errors.New("create failed")}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
 	err := svc.Create(&models.Configurations{})
 	if err == nil || err.Error() != "create failed" {
@@ -66,7 +86,7 @@ func TestConfigService_Create_Error(t *testing.T) {
 
 func TestConfigService_Update_Error(t *testing.T) {
 	mockRepo := &mockConfigRepo{updateErr: errors.New("update failed")}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
 	err := svc.Update(&models.Configurations{})
 	if err == nil || err.Error() != "update failed" {
@@ -76,7 +96,7 @@ func TestConfigService_Update_Error(t *testing.T) {
 
 func TestConfigService_RollbackConfig(t *testing.T) {
 	mockRepo := &mockConfigRepo{}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
 	cfg := &models.Configurations{
 		ID:        uuid.New(),
@@ -85,17 +105,17 @@ func TestConfigService_RollbackConfig(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	if err := svc.RollbackConfig(cfg); err != nil {
+	if err := svc.RollbackConfig(cfg, 0); err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 }
 
 func TestConfigService_GetByName_Success(t *testing.T) {
-	expected := &models.LastConfigurations{Name: "feature_flag", Version: 1}
+	expected := &models.LastConfigurations{Name: "feature_flag", Version: 1, IsActive: 1}
 	mockRepo := &mockConfigRepo{lastCfg: expected}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
-	cfg, err := svc.GetLastVersionByName("feature_flag")
+	cfg, err := svc.GetLastVersionByName(testClientID, "feature_flag")
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
@@ -106,9 +126,9 @@ func TestConfigService_GetByName_Success(t *testing.T) {
 
 func TestConfigService_GetByName_Error(t *testing.T) {
 	mockRepo := &mockConfigRepo{lastErr: errors.New("not found")}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
-	_, err := svc.GetLastVersionByName("missing")
+	_, err := svc.GetLastVersionByName(testClientID, "missing")
 	if err == nil || err.Error() != "not found" {
 		t.Fatalf("expected 'not found', got %v", err)
 	}
@@ -117,9 +137,9 @@ func TestConfigService_GetByName_Error(t *testing.T) {
 func TestConfigService_GetByNameByVersion_Success(t *testing.T) {
 	expected := &models.Configurations{Name: "feature_flag", Version: 1}
 	mockRepo := &mockConfigRepo{byVerCfg: expected}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
-	cfg, err := svc.GetByNameByVersion("feature_flag", 1)
+	cfg, err := svc.GetByNameByVersion(testClientID, "feature_flag", 1)
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
@@ -130,9 +150,9 @@ func TestConfigService_GetByNameByVersion_Success(t *testing.T) {
 
 func TestConfigService_GetByNameByVersion_Error(t *testing.T) {
 	mockRepo := &mockConfigRepo{byVerErr: errors.New("not found")}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
-	_, err := svc.GetByNameByVersion("feature_flag", 99)
+	_, err := svc.GetByNameByVersion(testClientID, "feature_flag", 99)
 	if err == nil || err.Error() != "not found" {
 		t.Fatalf("expected 'not found', got %v", err)
 	}
@@ -144,23 +164,468 @@ func TestConfigService_GetConfigVersions_Success(t *testing.T) {
 		{Name: "feature_flag", Version: 2},
 	}
 	mockRepo := &mockConfigRepo{versions: expected}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
-	cfgs, err := svc.GetConfigVersions("feature_flag")
+	cfgs, err := svc.GetConfigVersions(testClientID, "feature_flag", 0, "")
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
-	if len(cfgs) != 2 {
-		t.Errorf("expected 2 configs, got %d", len(cfgs))
+	if len(cfgs.Items) != 2 || cfgs.NextCursor != "" {
+		t.Errorf("expected a single page of 2 configs, got %+v", cfgs)
 	}
 }
 
 func TestConfigService_GetConfigVersions_Error(t *testing.T) {
 	mockRepo := &mockConfigRepo{versionsErr: errors.New("db error")}
-	svc := &ConfigServiceImpl{repo: mockRepo}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
 
-	_, err := svc.GetConfigVersions("feature_flag")
+	_, err := svc.GetConfigVersions(testClientID, "feature_flag", 0, "")
 	if err == nil || err.Error() != "db error" {
 		t.Fatalf("expected 'db error', got %v", err)
 	}
 }
+
+func TestConfigService_CacheInvalidatedOnWrite(t *testing.T) {
+	mockRepo := &mockConfigRepo{lastCfg: &models.LastConfigurations{ClientID: testClientID, Name: "cache_order", Version: 1, Input: `{"v":1}`, IsActive: 1}}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
+
+	// A read of version 1 that finishes after version 2 was written must
+	// not leave version 1 in the cache
+	gen := cache.Generation(testClientID, "cache_order")
+	if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "cache_order", Input: `{"v":2}`}); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if cache.Fill(testClientID, "cache_order", mockRepo.lastCfg, gen) {
+		t.Fatal("expected a read started before the write not to be cached")
+	}
+
+	mockRepo.lastCfg = &models.LastConfigurations{ClientID: testClientID, Name: "cache_order", Version: 2, Input: `{"v":2}`, IsActive: 1}
+	if latest, err := svc.GetLastVersionByName(testClientID, "cache_order"); err != nil || latest.Version != 2 {
+		t.Fatalf("expected version 2, got %+v, %v", latest, err)
+	}
+	if cached, ok := cache.Get(testClientID, "cache_order"); !ok || cached.Version != 2 {
+		t.Fatalf("expected version 2 to be cached, got %+v", cached)
+	}
+}
+
+func TestConfigService_CacheScopedByTenant(t *testing.T) {
+	mockRepo := &mockConfigRepo{lastCfg: &models.LastConfigurations{ClientID: testClientID, Name: "tenant_cache", Input: `{"v":1}`, IsActive: 1}}
+	svc := &ConfigServiceImpl{repo: mockRepo, notifier: notify.New(notify.DefaultMaxWaiters)}
+
+	// Read through once, then only the cache has it
+	if _, err := svc.GetLastVersionByName(testClientID, "tenant_cache"); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	mockRepo.lastCfg = nil
+
+	own, err := svc.GetLastVersionByName(testClientID, "tenant_cache")
+	if err != nil || own == nil || own.Input != `{"v":1}` {
+		t.Fatalf("expected own cached config, got %+v, %v", own, err)
+	}
+	other, err := svc.GetLastVersionByName("bca-cabang", "tenant_cache")
+	if err != nil || other != nil {
+		t.Fatalf("expected another tenant to miss the cache, got %+v, %v", other, err)
+	}
+}
+
+func TestConfigService_ListConfigs_Paginates(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	seedListConfigs(t, repo)
+	svc := NewConfigService(repo, notify.New(notify.DefaultMaxWaiters))
+
+	for _, sort := range []string{"name", "-name", "-updated_at", "version"} {
+		q := ListQuery{ClientID: testClientID, Patterns: []string{"*"}, Sort: sort, Limit: 2}
+		seen := map[string]bool{}
+		pages := 0
+		for {
+			page, err := svc.ListConfigs(q)
+			if err != nil {
+				t.Fatalf("sort %s: unexpected error: %v", sort, err)
+			}
+			pages++
+			for _, cfg := range page.Items {
+				if seen[cfg.Name] {
+					t.Fatalf("sort %s: %s returned twice", sort, cfg.Name)
+				}
+				seen[cfg.Name] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		// payments_legacy is deleted and not listed by default
+		if len(seen) != 3 || pages != 2 {
+			t.Errorf("sort %s: expected 3 configs over 2 pages, got %d over %d", sort, len(seen), pages)
+		}
+	}
+}
+
+func TestConfigService_ListConfigs_Invalid(t *testing.T) {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+
+	if _, err := svc.ListConfigs(ListQuery{Sort: "schema"}); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("expected ErrInvalidSort, got %v", err)
+	}
+	if _, err := svc.ListConfigs(ListQuery{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+
+	// Cursors cannot be replayed with another sort order
+	cursor := encodeCursor(listCursor{Sort: "name", Name: "a", ID: uuid.NewString()})
+	if _, err := svc.ListConfigs(ListQuery{Sort: "-name", Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestConfigService_GetConfigVersions_Paginates(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	svc := NewConfigService(repo, notify.New(notify.DefaultMaxWaiters))
+	for i := 0; i < 5; i++ {
+		if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "paged", Schema: `{}`, Input: `{}`}); err != nil {
+			t.Fatalf("failed to create version: %v", err)
+		}
+	}
+
+	first, err := svc.GetConfigVersions(testClientID, "paged", 2, "")
+	if err != nil || len(first.Items) != 2 || first.NextCursor == "" {
+		t.Fatalf("expected first page of 2 with a cursor, got %+v, %v", first, err)
+	}
+	second, _ := svc.GetConfigVersions(testClientID, "paged", 2, first.NextCursor)
+	last, _ := svc.GetConfigVersions(testClientID, "paged", 2, second.NextCursor)
+	if second.Items[0].Version != 3 || len(last.Items) != 1 || last.NextCursor != "" {
+		t.Fatalf("expected versions 3-4 then 5, got %+v and %+v", second.Items, last)
+	}
+}
+
+func TestConfigService_DeleteRestorePurge(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	svc := NewConfigService(repo, notify.New(notify.DefaultMaxWaiters))
+
+	cfg := &models.Configurations{ClientID: testClientID, Name: "lifecycle", Schema: `{}`, Input: `{}`, CreatedBy: "alice"}
+	if err := svc.Create(cfg); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	if err := svc.DeleteConfig(testClientID, "lifecycle", "bob"); err != nil {
+		t.Fatalf("expected delete to succeed, got %v", err)
+	}
+
+	// Hidden from reads and evicted from the cache, history is kept
+	if latest, _ := svc.GetLastVersionByName(testClientID, "lifecycle"); latest != nil {
+		t.Fatalf("expected deleted config to be hidden, got %+v", latest)
+	}
+	if _, ok := cache.Get(testClientID, "lifecycle"); ok {
+		t.Fatal("expected deleted config to be evicted from cache")
+	}
+	if versions, _ := svc.GetConfigVersions(testClientID, "lifecycle", 0, ""); len(versions.Items) != 1 {
+		t.Fatalf("expected history to be preserved, got %+v", versions)
+	}
+	if err := svc.DeleteConfig(testClientID, "lifecycle", "bob"); !errors.Is(err, ErrConfigDeleted) {
+		t.Fatalf("expected ErrConfigDeleted, got %v", err)
+	}
+	if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "lifecycle", Schema: `{}`, Input: `{}`}); !errors.Is(err, ErrConfigDeleted) {
+		t.Fatalf("expected writes to a deleted config to fail, got %v", err)
+	}
+
+	restored, err := svc.RestoreConfig(testClientID, "lifecycle", "carol")
+	if err != nil || restored.Version != 1 || restored.IsActive != 1 {
+		t.Fatalf("expected version 1 to be restored, got %+v, %v", restored, err)
+	}
+	if latest, _ := svc.GetLastVersionByName(testClientID, "lifecycle"); latest == nil {
+		t.Fatal("expected restored config to be readable")
+	}
+	if err := svc.PurgeConfig(testClientID, "lifecycle", "admin"); !errors.Is(err, ErrConfigNotDeleted) {
+		t.Fatalf("expected purge of an active config to fail, got %v", err)
+	}
+
+	_ = svc.DeleteConfig(testClientID, "lifecycle", "bob")
+	if err := svc.PurgeConfig(testClientID, "lifecycle", "admin"); err != nil {
+		t.Fatalf("expected purge to succeed, got %v", err)
+	}
+	if versions, _ := svc.GetConfigVersions(testClientID, "lifecycle", 0, ""); len(versions.Items) != 0 {
+		t.Fatalf("expected history to be purged, got %+v", versions)
+	}
+	if _, err := svc.RestoreConfig(testClientID, "lifecycle", "carol"); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected ErrConfigNotFound after purge, got %v", err)
+	}
+
+	events, _ := svc.GetConfigEvents(testClientID, "lifecycle")
+	want := []struct {
+		typ   models.ConfigEventType
+		actor string
+	}{
+		{models.ConfigCreated, "alice"},
+		{models.ConfigDeleted, "bob"},
+		{models.ConfigRestored, "carol"},
+		{models.ConfigDeleted, "bob"},
+		{models.ConfigPurged, "admin"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].Actor != w.actor || events[i].CreatedAt.IsZero() {
+			t.Errorf("event %d: expected %s by %s, got %+v", i, w.typ, w.actor, events[i])
+		}
+	}
+}
+
+func TestConfigService_DeleteConfig_NotFound(t *testing.T) {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+
+	if err := svc.DeleteConfig(testClientID, "missing", "bob"); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected ErrConfigNotFound, got %v", err)
+	}
+	if _, err := svc.RestoreConfig(testClientID, "missing", "bob"); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected ErrConfigNotFound, got %v", err)
+	}
+}
+
+func TestConfigService_DiffVersions(t *testing.T) {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+	for _, input := range []string{`{"v":1,"name":"a"}`, `{"v":2,"name":"a"}`, `{"v":3}`} {
+		if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "diffed", Schema: `{}`, Input: input}); err != nil {
+			t.Fatalf("failed to create version: %v", err)
+		}
+	}
+
+	diff, err := svc.DiffVersions(testClientID, "diffed", 1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diff.Patch) != 1 || diff.Summary[0].Path != "/v" || string(diff.Summary[0].Old) != "1" {
+		t.Fatalf("expected /v to change from 1, got %+v", diff)
+	}
+
+	latest, err := svc.DiffVersions(testClientID, "diffed", 1, 0)
+	if err != nil || latest.To != 3 || len(latest.Patch) != 2 {
+		t.Fatalf("expected diff against latest version 3, got %+v, %v", latest, err)
+	}
+
+	if _, err := svc.DiffVersions(testClientID, "diffed", 1, 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("expected ErrVersionNotFound, got %v", err)
+	}
+	if _, err := svc.DiffVersions("bca-cabang", "diffed", 1, 0); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected another tenant not to find the config, got %v", err)
+	}
+}
+
+func TestConfigService_PatchConfig(t *testing.T) {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+	schema := `{"type":"object","properties":{"limit":{"type":"integer"},"tags":{"type":"array"}},"required":["limit"]}`
+	if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "patched", Type: models.TypeObject, Schema: schema, Input: `{"limit":1,"tags":["a"]}`}); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	cfg, err := svc.PatchConfig(testClientID, "patched", MergePatchType, []byte(`{"limit":5}`), 0, "bob")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Version != 2 || cfg.Input != `{"limit":5,"tags":["a"]}` || cfg.CreatedBy != "bob" || cfg.Schema != schema {
+		t.Fatalf("expected merge patch to create version 2, got %+v", cfg)
+	}
+
+	cfg, err = svc.PatchConfig(testClientID, "patched", JSONPatchType, []byte(`[{"op":"test","path":"/limit","value":5},{"op":"add","path":"/tags/-","value":"b"}]`), 0, "bob")
+	if err != nil || cfg.Version != 3 || cfg.Input != `{"limit":5,"tags":["a","b"]}` {
+		t.Fatalf("expected json patch to create version 3, got %+v, %v", cfg, err)
+	}
+
+	if _, err := svc.PatchConfig(testClientID, "patched", MergePatchType, []byte(`{"limit":null}`), 0, "bob"); !errors.Is(err, ErrInputMismatch) {
+		t.Fatalf("expected ErrInputMismatch, got %v", err)
+	}
+	if _, err := svc.PatchConfig(testClientID, "patched", JSONPatchType, []byte(`[{"op":"test","path":"/limit","value":1}]`), 0, "bob"); !errors.Is(err, ErrPatchTestFailed) {
+		t.Fatalf("expected ErrPatchTestFailed, got %v", err)
+	}
+	if _, err := svc.PatchConfig(testClientID, "patched", JSONPatchType, []byte(`{"op":"add"}`), 0, "bob"); !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("expected ErrInvalidPatch, got %v", err)
+	}
+	if _, err := svc.PatchConfig("bca-cabang", "patched", MergePatchType, []byte(`{}`), 0, "bob"); !errors.Is(err, ErrConfigNotFound) {
+		t.Fatalf("expected another tenant not to find the config, got %v", err)
+	}
+
+	latest, _ := svc.GetLastVersionByName(testClientID, "patched")
+	if latest.Version != 3 {
+		t.Fatalf("expected failed patches not to create versions, got version %d", latest.Version)
+	}
+}
+
+func TestConfigService_CreateVersion_Conflict(t *testing.T) {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+	for i := 0; i < 2; i++ {
+		if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "guarded", Schema: `{}`, Input: `{}`}); err != nil {
+			t.Fatalf("failed to create version: %v", err)
+		}
+	}
+
+	cfg := &models.Configurations{ClientID: testClientID, Name: "guarded", Schema: `{}`, Input: `{"a":1}`}
+	if err := svc.CreateVersion(cfg, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if err := svc.CreateVersion(cfg, 2); err != nil || cfg.Version != 3 {
+		t.Fatalf("expected version 3, got %d, %v", cfg.Version, err)
+	}
+
+	latest, _ := svc.GetLastVersionByName(testClientID, "guarded")
+	if latest.Version != 3 || latest.Input != `{"a":1}` {
+		t.Fatalf("expected cache to hold version 3, got %+v", latest)
+	}
+
+	if _, err := svc.PatchConfig(testClientID, "guarded", MergePatchType, []byte(`{"b":2}`), 2, "bob"); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected stale patch to conflict, got %v", err)
+	}
+	patched, err := svc.PatchConfig(testClientID, "guarded", MergePatchType, []byte(`{"b":2}`), 3, "bob")
+	if err != nil || patched.Version != 4 {
+		t.Fatalf("expected version 4, got %+v, %v", patched, err)
+	}
+}
+
+func TestConfigService_WatchConfig(t *testing.T) {
+	notifier := notify.New(notify.DefaultMaxWaiters)
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notifier)
+	create := func(input string) {
+		if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "watched", Schema: `{}`, Input: input}); err != nil {
+			t.Fatalf("failed to create version: %v", err)
+		}
+	}
+	create(`{"v":1}`)
+
+	// A newer version already exists
+	cfg, err := svc.WatchConfig(context.Background(), testClientID, "watched", 0)
+	if err != nil || cfg == nil || cfg.Version != 1 {
+		t.Fatalf("expected version 1 right away, got %+v, %v", cfg, err)
+	}
+
+	done := make(chan *models.LastConfigurations)
+	go func() {
+		cfg, _ := svc.WatchConfig(context.Background(), testClientID, "watched", 1)
+		done <- cfg
+	}()
+	// Wait until the watcher is subscribed, then write
+	for notifier.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	create(`{"v":2}`)
+
+	select {
+	case cfg := <-done:
+		if cfg == nil || cfg.Version != 2 {
+			t.Fatalf("expected version 2, got %+v", cfg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected watcher to wake up on a new version")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cfg, err = svc.WatchConfig(ctx, testClientID, "watched", 2)
+	if err != nil || cfg != nil {
+		t.Fatalf("expected nil on timeout, got %+v, %v", cfg, err)
+	}
+	if got := notifier.Waiters(); got != 0 {
+		t.Fatalf("expected timed out watchers to unsubscribe, got %d", got)
+	}
+}
+
+func TestConfigService_RecordsWriteEvents(t *testing.T) {
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notify.New(notify.DefaultMaxWaiters))
+	for _, input := range []string{`{"v":1}`, `{"v":2}`} {
+		if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "recorded", Schema: `{}`, Input: input, CreatedBy: "alice"}); err != nil {
+			t.Fatalf("failed to create version: %v", err)
+		}
+	}
+	v1, _ := svc.GetByNameByVersion(testClientID, "recorded", 1)
+	v1.CreatedBy = "bob"
+	if err := svc.RollbackConfig(v1, 2); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+
+	events, _ := svc.GetConfigEvents(testClientID, "recorded")
+	want := []models.ConfigEventType{models.ConfigCreated, models.ConfigUpdated, models.ConfigRolledBack}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, typ := range want {
+		if events[i].Type != typ || events[i].Version != i+1 {
+			t.Errorf("event %d: expected %s of version %d, got %+v", i, typ, i+1, events[i])
+		}
+	}
+	if events[2].Actor != "bob" {
+		t.Fatalf("expected rollback by bob, got %s", events[2].Actor)
+	}
+}
+
+func TestConfigService_WaitForEvents(t *testing.T) {
+	notifier := notify.New(notify.DefaultMaxWaiters)
+	svc := NewConfigService(NewConfigRepo(setupConfigTestDB(t)), notifier)
+	after, _ := svc.LastEventID()
+
+	done := make(chan []models.ConfigEvent)
+	go func() {
+		events, _ := svc.WaitForEvents(context.Background(), EventQuery{ClientID: testClientID, Patterns: []string{"*"}, AfterID: after})
+		done <- events
+	}()
+	for notifier.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Other tenants do not wake the stream up with anything to send
+	if err := svc.Create(&models.Configurations{ClientID: "bca-cabang", Name: "streamed", Schema: `{}`, Input: `{}`}); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "streamed", Schema: `{}`, Input: `{}`}); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	select {
+	case events := <-done:
+		if len(events) != 1 || events[0].ClientID != testClientID || events[0].Type != models.ConfigCreated {
+			t.Fatalf("expected the created event of our tenant, got %+v", events)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected stream to wake up on a new event")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	last, _ := svc.LastEventID()
+	events, err := svc.WaitForEvents(ctx, EventQuery{ClientID: testClientID, Patterns: []string{"*"}, AfterID: last})
+	if err != nil || events != nil {
+		t.Fatalf("expected nil on timeout, got %+v, %v", events, err)
+	}
+}
+
+func TestConfigService_PreviewWrite(t *testing.T) {
+	repo := NewConfigRepo(setupConfigTestDB(t))
+	svc := NewConfigService(repo, notify.New(notify.DefaultMaxWaiters))
+
+	preview, err := svc.PreviewWrite(&models.Configurations{ClientID: testClientID, Name: "previewed", Input: `{"v":1}`}, 0)
+	if err != nil || preview.Latest != nil || preview.Version != 1 || len(preview.Diff.Patch) != 1 {
+		t.Fatalf("expected version 1 of a new config, got %+v, %v", preview, err)
+	}
+
+	for _, input := range []string{`{"v":1}`, `{"v":2}`} {
+		if err := svc.Create(&models.Configurations{ClientID: testClientID, Name: "previewed", Schema: `{}`, Input: input}); err != nil {
+			t.Fatalf("failed to create version: %v", err)
+		}
+	}
+	next := &models.Configurations{ClientID: testClientID, Name: "previewed", Input: `{"v":3}`}
+	preview, err = svc.PreviewWrite(next, 2)
+	if err != nil || preview.Version != 3 || preview.Diff.From != 2 || preview.Diff.Summary[0].Path != "/v" {
+		t.Fatalf("expected version 3 changing /v, got %+v, %v", preview, err)
+	}
+	if _, err := svc.PreviewWrite(next, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+
+	// Nothing was stored
+	versions, err := repo.GetConfigVersions(testClientID, "previewed", 0, 10)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("expected 2 stored versions, got %d, %v", len(versions), err)
+	}
+
+	if err := svc.DeleteConfig(testClientID, "previewed", "bob"); err != nil {
+		t.Fatalf("failed to delete config: %v", err)
+	}
+	if preview, err := svc.PreviewWrite(next, 0); !errors.Is(err, ErrConfigDeleted) || preview == nil {
+		t.Fatalf("expected ErrConfigDeleted with a preview, got %+v, %v", preview, err)
+	}
+}
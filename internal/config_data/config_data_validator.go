@@ -2,35 +2,158 @@ package configdata
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/santhosh-tekuri/jsonschema/v5"
+	"sass.com/configsvc/internal/models"
 )
 
-// Validates the config against a schema (specific to config type)
-func isValidInput(schemaJSONString, inputJSONString string) bool {
-	compiler := jsonschema.NewCompiler()
-	if err := compiler.AddResource("schema.json", strings.NewReader(schemaJSONString)); err != nil {
-		panic(err)
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Violation is one reason a schema or an input was rejected. InstancePath
+// points into the input, or into the schema for invalid schemas; KeywordPath
+// is the failing keyword of the schema, or of the JSON Schema meta-schema.
+// Both are JSON Pointers, "" is the root.
+type Violation struct {
+	InstancePath string `json:"instance_path"`
+	KeywordPath  string `json:"keyword_path"`
+	Message      string `json:"message"`
+}
+
+// ValidationError lists every violation of a rejected write. Err is
+// ErrInvalidSchema, ErrInputMismatch or ErrIncompatibleSchema, so errors.Is
+// tells them apart.
+type ValidationError struct {
+	Err        error
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 0 {
+		return e.Err.Error()
+	}
+	v := e.Violations[0]
+	msg := fmt.Sprintf("%s: %s", e.Err, v.Message)
+	if v.InstancePath != "" {
+		msg = fmt.Sprintf("%s: %s: %s", e.Err, v.InstancePath, v.Message)
 	}
-	schema, err := compiler.Compile("schema.json")
+	if len(e.Violations) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Violations)-1)
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validates the config against a schema (specific to config type), $refs
+// resolving to the registered schemas of the default tenant
+func isValidInput(schemaJSONString, inputJSONString string) bool {
+	return validateInput(models.DefaultClientID, schemaJSONString, inputJSONString) == nil
+}
+
+// validateInput checks a schema and an input against it, $refs resolving to
+// the registered schemas of the tenant. It returns a *ValidationError for
+// anything wrong with either, and never panics on whatever schema it is given.
+func validateInput(clientID, schemaJSONString, inputJSONString string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &ValidationError{Err: ErrInvalidSchema, Violations: []Violation{{Message: fmt.Sprint(r)}}}
+		}
+	}()
+
+	schema, err := compileSchema(clientID, schemaJSONString)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	var doc interface{}
 	if err := json.Unmarshal([]byte(inputJSONString), &doc); err != nil {
-		return false
+		return &ValidationError{Err: ErrInputMismatch, Violations: []Violation{{Message: "input is not valid JSON: " + err.Error()}}}
 	}
-
 	if err := schema.Validate(doc); err != nil {
-		fmt.Println("Input not matches with the Schema:", err)
-		return false
+		return &ValidationError{Err: ErrInputMismatch, Violations: violations(err)}
+	}
+	return nil
+}
+
+// ValidateRegisteredSchema checks a schema about to be stored as version of
+// the registered schema id. Its $refs resolve relative to its own URL.
+func ValidateRegisteredSchema(clientID, id string, version int, schemaJSONString string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &ValidationError{Err: ErrInvalidSchema, Violations: []Violation{{Message: fmt.Sprint(r)}}}
+		}
+	}()
+	_, err = compileSchemaAt(clientID, RegisteredSchemaURL(id, version), schemaJSONString)
+	return err
+}
+
+// compileSchema compiles a config schema, or returns it from compiledSchemas
+func compileSchema(clientID, schemaJSONString string) (*jsonschema.Schema, error) {
+	key := schemaCacheKey(clientID, schemaJSONString)
+	if schema, ok := compiledSchemas.get(key); ok {
+		return schema, nil
+	}
+	schema, err := compileSchemaAt(clientID, configSchemaURL, schemaJSONString)
+	if err != nil {
+		return nil, err
+	}
+	compiledSchemas.add(key, schema)
+	return schema, nil
+}
+
+func compileSchemaAt(clientID, url, schemaJSONString string) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = schemaLoader(clientID)
+	useExtensions(compiler)
+	if err := compiler.AddResource(url, strings.NewReader(schemaJSONString)); err != nil {
+		return nil, &ValidationError{Err: ErrInvalidSchema, Violations: []Violation{{Message: "schema is not valid JSON: " + err.Error()}}}
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		// The schema is checked against the meta-schema first
+		var schemaErr *jsonschema.SchemaError
+		if errors.As(err, &schemaErr) {
+			err = schemaErr.Err
+		}
+		return nil, &ValidationError{Err: ErrInvalidSchema, Violations: violations(err)}
+	}
+	return schema, nil
+}
+
+// violations flattens a jsonschema error into its leaves, sorted by path
+func violations(err error) []Violation {
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []Violation{{Message: err.Error()}}
+	}
+
+	var out []Violation
+	var walk func(ve *jsonschema.ValidationError)
+	walk = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			out = append(out, Violation{InstancePath: ve.InstanceLocation, KeywordPath: ve.KeywordLocation, Message: ve.Message})
+			return
+		}
+		for _, cause := range ve.Causes {
+			walk(cause)
+		}
 	}
+	walk(ve)
 
-	return true
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].InstancePath != out[j].InstancePath {
+			return out[i].InstancePath < out[j].InstancePath
+		}
+		return out[i].KeywordPath < out[j].KeywordPath
+	})
+	return out
 }
 
 // Validates two schemas properties is the same and ignore the order
@@ -1,6 +1,10 @@
 package configdata
 
-import "testing"
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
 
 func TestIsValidInput_Success(t *testing.T) {
 	schemaJSON := `{
@@ -119,3 +123,55 @@ func TestEqualSchemas_InvalidJSON(t *testing.T) {
 		t.Fatal("expected invalid JSON not to be equal")
 	}
 }
+
+func TestValidateInput_ListsEveryViolation(t *testing.T) {
+	schemaJSON := `{
+		"type": "object",
+		"properties": {
+			"enabled": { "type": "boolean" },
+			"max_limit": { "type": "integer", "minimum": 1 },
+			"tags": { "type": "array", "items": { "type": "string" } }
+		},
+		"required": ["enabled", "max_limit"]
+	}`
+
+	err := validateInput(testClientID, schemaJSON, `{"max_limit": 0, "tags": ["a", 2]}`)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || !errors.Is(err, ErrInputMismatch) {
+		t.Fatalf("expected a ValidationError for the input, got %v", err)
+	}
+
+	want := []Violation{
+		{InstancePath: "", KeywordPath: "/required", Message: "missing properties: 'enabled'"},
+		{InstancePath: "/max_limit", KeywordPath: "/properties/max_limit/minimum", Message: "must be >= 1 but found 0"},
+		{InstancePath: "/tags/1", KeywordPath: "/properties/tags/items/type", Message: "expected string, but got number"},
+	}
+	if !reflect.DeepEqual(ve.Violations, want) {
+		t.Errorf("expected %+v, got %+v", want, ve.Violations)
+	}
+}
+
+func TestValidateInput_InvalidSchema(t *testing.T) {
+	schemas := []string{
+		`{"type": "object",`,
+		`{"type": 5}`,
+		`{"pattern": "("}`,
+		`{"$ref": "#/definitions/missing"}`,
+		`{"$schema": "https://example.com/unknown-draft"}`,
+		`[]`,
+		``,
+	}
+	for _, schema := range schemas {
+		err := validateInput(testClientID, schema, `{}`)
+		var ve *ValidationError
+		if !errors.As(err, &ve) || !errors.Is(err, ErrInvalidSchema) || len(ve.Violations) == 0 {
+			t.Errorf("%s: expected a ValidationError for the schema, got %v", schema, err)
+		}
+	}
+
+	err := validateInput(testClientID, `{"properties": {"a": {"type": "strin"}}}`, `{}`)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Violations) == 0 || ve.Violations[0].InstancePath != "/properties/a/type" {
+		t.Errorf("expected the path of the broken keyword in the schema, got %v", err)
+	}
+}
@@ -27,18 +27,45 @@ func RunMigrations(dbPath string, withSeed bool) error {
 		return fmt.Errorf("failed to connect DB: %w", err)
 	}
 
+	// Names used to be unique across all tenants
+	if err := dropLegacyIndexes(db); err != nil {
+		return err
+	}
+
 	// AutoMigrate creates/updates schema
 	if err := db.AutoMigrate(&models.User{}); err != nil {
 		return fmt.Errorf("failed to migrate User schema: %w", err)
 	}
+	if err := db.AutoMigrate(&models.RefreshToken{}); err != nil {
+		return fmt.Errorf("failed to migrate RefreshToken schema: %w", err)
+	}
+	if err := db.AutoMigrate(&models.RevokedToken{}, &models.UserRevocation{}); err != nil {
+		return fmt.Errorf("failed to migrate token revocation schema: %w", err)
+	}
 	if err := db.AutoMigrate(&models.Configurations{}); err != nil {
 		return fmt.Errorf("failed to migrate Configurations schema: %w", err)
 	}
 	if err := db.AutoMigrate(&models.LastConfigurations{}); err != nil {
 		return fmt.Errorf("failed to migrate LastConfigurations schema: %w", err)
 	}
+	if err := db.AutoMigrate(&models.ConfigEvent{}); err != nil {
+		return fmt.Errorf("failed to migrate ConfigEvent schema: %w", err)
+	}
+	if err := db.AutoMigrate(&models.ConfigGrant{}); err != nil {
+		return fmt.Errorf("failed to migrate ConfigGrant schema: %w", err)
+	}
+	if err := db.AutoMigrate(&models.OutboxEvent{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.WebhookAttempt{}); err != nil {
+		return fmt.Errorf("failed to migrate webhook schema: %w", err)
+	}
+	if err := db.AutoMigrate(&models.RegisteredSchema{}); err != nil {
+		return fmt.Errorf("failed to migrate RegisteredSchema schema: %w", err)
+	}
 	fmt.Println("all schemas migrated")
 
+	if err := backfillClientID(db); err != nil {
+		return err
+	}
+
 	if withSeed {
 		seed(db)
 	}
@@ -46,18 +73,59 @@ func RunMigrations(dbPath string, withSeed bool) error {
 	return nil
 }
 
+func dropLegacyIndexes(db *gorm.DB) error {
+	legacy := []struct {
+		model interface{}
+		index string
+	}{
+		{&models.Configurations{}, "idx_name_version"},
+		{&models.LastConfigurations{}, "idx_name"},
+	}
+	for _, l := range legacy {
+		if !db.Migrator().HasIndex(l.model, l.index) {
+			continue
+		}
+		if err := db.Migrator().DropIndex(l.model, l.index); err != nil {
+			return fmt.Errorf("failed to drop index %s: %w", l.index, err)
+		}
+	}
+	return nil
+}
+
+// backfillClientID moves rows created before tenancy into the default tenant
+func backfillClientID(db *gorm.DB) error {
+	for _, model := range []interface{}{
+		&models.User{},
+		&models.Configurations{},
+		&models.LastConfigurations{},
+		&models.ConfigGrant{},
+	} {
+		err := db.Model(model).
+			Where("client_id IS NULL OR client_id = ''").
+			Update("client_id", models.DefaultClientID).Error
+		if err != nil {
+			return fmt.Errorf("failed to backfill client_id: %w", err)
+		}
+	}
+	return nil
+}
+
 func seed(db *gorm.DB) {
 	admin := models.User{
 		ID:           uuid.New(),
+		ClientID:     models.DefaultClientID,
 		Username:     "admin",
 		PasswordHash: hashPassword("admin123"),
 		Role:         models.RoleAdmin,
+		IsActive:     1,
 	}
 	user := models.User{
 		ID:           uuid.New(),
+		ClientID:     models.DefaultClientID,
 		Username:     "user1",
 		PasswordHash: hashPassword("user123"),
 		Role:         models.RoleUser,
+		IsActive:     1,
 	}
 
 	db.FirstOrCreate(&admin, models.User{Username: admin.Username})
@@ -14,29 +14,31 @@ const (
 )
 
 type Configurations struct {
-	ID        uuid.UUID `gorm:"primarykey"`
-	ClientID  string
-	Name      string `gorm:"size:100;uniqueIndex:idx_name_version"`
-	Type      Type
-	Schema    string    `gorm:"type:TEXT;check:json_valid(schema)"`
-	Input     string    `gorm:"type:TEXT;check:json_valid(input)"`
-	Version   int       `gorm:"uniqueIndex:idx_name_version"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	CreatedBy string
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
-	IsActive  int
+	ID            uuid.UUID `gorm:"primarykey"`
+	ClientID      string    `gorm:"size:100;uniqueIndex:idx_client_name_version"`
+	Name          string    `gorm:"size:100;uniqueIndex:idx_client_name_version"`
+	Type          Type
+	Schema        string    `gorm:"type:TEXT;check:json_valid(schema)"`
+	Input         string    `gorm:"type:TEXT;check:json_valid(input)"`
+	Version       int       `gorm:"uniqueIndex:idx_client_name_version"`
+	SchemaVersion int       `gorm:"default:1"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	CreatedBy     string
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+	IsActive      int
 }
 
 type LastConfigurations struct {
-	ID        uuid.UUID `gorm:"primarykey"`
-	ClientID  string
-	Name      string `gorm:"size:100;uniqueIndex:idx_name"`
-	Type      Type
-	Schema    string `gorm:"type:TEXT;check:json_valid(schema)"`
-	Input     string `gorm:"type:TEXT;check:json_valid(input)"`
-	Version   int
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	CreatedBy string
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
-	IsActive  int
+	ID            uuid.UUID `gorm:"primarykey"`
+	ClientID      string    `gorm:"size:100;uniqueIndex:idx_client_name"`
+	Name          string    `gorm:"size:100;uniqueIndex:idx_client_name"`
+	Type          Type
+	Schema        string `gorm:"type:TEXT;check:json_valid(schema)"`
+	Input         string `gorm:"type:TEXT;check:json_valid(input)"`
+	Version       int
+	SchemaVersion int       `gorm:"default:1"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	CreatedBy     string
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+	IsActive      int
 }
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type ConfigEventType string
+
+const (
+	ConfigCreated    ConfigEventType = "created"
+	ConfigUpdated    ConfigEventType = "updated"
+	ConfigRolledBack ConfigEventType = "rolled_back"
+	ConfigDeleted    ConfigEventType = "deleted"
+	ConfigRestored   ConfigEventType = "restored"
+	ConfigPurged     ConfigEventType = "purged"
+)
+
+// ConfigEvent records every change of a config. IDs only ever grow, so
+// they double as the sequence event streams resume from.
+type ConfigEvent struct {
+	ID        uint            `gorm:"primarykey"`
+	ClientID  string          `gorm:"size:100;index:idx_event_client_name"`
+	Name      string          `gorm:"size:100;index:idx_event_client_name"`
+	Type      ConfigEventType `gorm:"size:20"`
+	Version   int             // latest version at the time of the event
+	Actor     string
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GrantRole string
+
+// Grant roles are ordered, each one includes the permissions of the previous
+const (
+	GrantRoleViewer    GrantRole = "viewer"
+	GrantRoleEditor    GrantRole = "editor"
+	GrantRolePublisher GrantRole = "publisher"
+	GrantRoleOwner     GrantRole = "owner"
+)
+
+// ConfigGrant gives a user a role on a config name, or on every name under a
+// prefix when Pattern ends with ".*" (e.g. "payments.*"). "*" matches all configs.
+type ConfigGrant struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	ClientID  string    `gorm:"size:100;index"`
+	UserID    uuid.UUID `gorm:"uniqueIndex:idx_grant_user_pattern"`
+	Pattern   string    `gorm:"size:100;uniqueIndex:idx_grant_user_pattern"`
+	Role      GrantRole `gorm:"size:20"`
+	CreatedBy string
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
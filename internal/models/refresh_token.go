@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is stored hashed; the raw token is only ever returned to the client.
+// Tokens issued from the same login share a FamilyID so a replayed token can
+// revoke the whole chain.
+type RefreshToken struct {
+	ID        uuid.UUID `gorm:"primarykey"`
+	UserID    uuid.UUID `gorm:"index"`
+	FamilyID  uuid.UUID `gorm:"index"`
+	TokenHash string    `gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RevokedToken is a deny-listed access token. It can be pruned once ExpiresAt
+// has passed because the token would be rejected as expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"primarykey;size:64"`
+	UserID    string    `gorm:"index;size:64"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// UserRevocation invalidates every access token of a user issued at or before
+// RevokedAt.
+type UserRevocation struct {
+	UserID    string `gorm:"primarykey;size:64"`
+	RevokedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RegisteredSchema is a version of a schema of the registry, shared by the
+// configs of a tenant. Versions never change once registered, configs refer
+// to them with {"$ref": "/schemas/<SchemaID>/versions/<Version>"}.
+type RegisteredSchema struct {
+	ID          uuid.UUID `gorm:"primarykey"`
+	ClientID    string    `gorm:"size:100;uniqueIndex:idx_client_schema_version"`
+	SchemaID    string    `gorm:"size:100;uniqueIndex:idx_client_schema_version"`
+	Version     int       `gorm:"uniqueIndex:idx_client_schema_version"`
+	Schema      string    `gorm:"type:TEXT;check:json_valid(schema)"`
+	Description string
+	CreatedBy   string
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
@@ -13,12 +13,16 @@ const (
 	RoleUser  Role = "user"
 )
 
+// DefaultClientID is the tenant of users created before tenancy existed
+const DefaultClientID = "default"
+
 type User struct {
 	ID           uuid.UUID `gorm:"primarykey"`
+	ClientID     string    `gorm:"size:100;index"`
 	Username     string    `gorm:"uniqueIndex;size:100"`
-	PasswordHash string
-	Role         Role `gorm:"size:20;default:'user'"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `gorm:"size:20;default:'user'"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
-	IsActive     int
+	IsActive     int `gorm:"default:1"`
 }
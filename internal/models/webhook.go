@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook posts the events of a tenant's configs matching Pattern to URL.
+// Pattern uses the grant syntax: a name, a "payments.*" prefix or "*".
+type Webhook struct {
+	ID         uuid.UUID `gorm:"primarykey"`
+	ClientID   string    `gorm:"size:100;index"`
+	URL        string
+	Pattern    string `gorm:"size:100"`
+	EventTypes string // comma separated ConfigEventTypes, empty for every type
+	Secret     string `json:"-"`
+	IsActive   int    `gorm:"default:1"`
+	CreatedBy  string
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+// OutboxEvent is written in the same transaction as its ConfigEvent and
+// removed once the event has been turned into webhook deliveries
+type OutboxEvent struct {
+	ID        uint `gorm:"primarykey"`
+	EventID   uint
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead" // gave up retrying
+)
+
+// WebhookDelivery is one event to be posted to one webhook
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primarykey"`
+	WebhookID      uuid.UUID `gorm:"index"`
+	ClientID       string    `gorm:"size:100"`
+	EventID        uint
+	EventType      ConfigEventType `gorm:"size:20"`
+	Payload        string          `gorm:"type:TEXT"`
+	Status         DeliveryStatus  `gorm:"size:20;index:idx_delivery_due"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index:idx_delivery_due"`
+	LastStatusCode int
+	LastError      string
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+// WebhookAttempt logs a single try of a delivery
+type WebhookAttempt struct {
+	ID         uint `gorm:"primarykey"`
+	DeliveryID uint `gorm:"index"`
+	StatusCode int  // 0 when no response was received
+	Error      string
+	DurationMs int64
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"errors"
+	"sync"
+)
+
+// DefaultMaxWaiters bounds how many requests may wait for changes at once
+const DefaultMaxWaiters = 10000
+
+var ErrTooManyWaiters = errors.New("too many waiters")
+
+// Notifier wakes up everybody waiting for a config, or any config of a
+// tenant, to change. All waiters of a config share one channel that is closed
+// on its next change, so a waiter costs no goroutine or timer of its own.
+type Notifier struct {
+	maxWaiters int
+
+	mu      sync.Mutex
+	waiters int
+	topics  map[string]*topic // "clientID/name" -> waiters of that config
+	tenants map[string]*topic // clientID -> waiters of any config of the tenant
+}
+
+type topic struct {
+	changed chan struct{}
+	waiters int
+}
+
+func New(maxWaiters int) *Notifier {
+	return &Notifier{maxWaiters: maxWaiters, topics: map[string]*topic{}, tenants: map[string]*topic{}}
+}
+
+// Configs are scoped per tenant like the cache
+func key(clientID, name string) string {
+	return clientID + "/" + name
+}
+
+// Subscribe returns a channel that is closed on the next Notify of the config.
+// release must be called once the caller stops waiting, it may be called twice.
+func (n *Notifier) Subscribe(clientID, name string) (<-chan struct{}, func(), error) {
+	return n.subscribe(n.topics, key(clientID, name))
+}
+
+// SubscribeTenant is Subscribe for a change of any config of the tenant
+func (n *Notifier) SubscribeTenant(clientID string) (<-chan struct{}, func(), error) {
+	return n.subscribe(n.tenants, clientID)
+}
+
+func (n *Notifier) subscribe(topics map[string]*topic, k string) (<-chan struct{}, func(), error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.waiters >= n.maxWaiters {
+		return nil, nil, ErrTooManyWaiters
+	}
+	t, ok := topics[k]
+	if !ok {
+		t = &topic{changed: make(chan struct{})}
+		topics[k] = t
+	}
+	t.waiters++
+	n.waiters++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() { n.release(topics, k, t) })
+	}
+	return t.changed, release, nil
+}
+
+func (n *Notifier) release(topics map[string]*topic, k string, t *topic) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	t.waiters--
+	n.waiters--
+	// Notify already dropped topics it has closed
+	if t.waiters == 0 && topics[k] == t {
+		delete(topics, k)
+	}
+}
+
+// Notify wakes up every waiter of the config and of its tenant
+func (n *Notifier) Notify(clientID, name string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wake(n.topics, key(clientID, name))
+	wake(n.tenants, clientID)
+}
+
+func wake(topics map[string]*topic, k string) {
+	if t, ok := topics[k]; ok {
+		close(t.changed)
+		delete(topics, k)
+	}
+}
+
+// Waiters returns how many subscriptions are currently held
+func (n *Notifier) Waiters() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.waiters
+}
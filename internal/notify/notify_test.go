@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifier_WakesWaiters(t *testing.T) {
+	n := New(DefaultMaxWaiters)
+
+	const waiters = 100
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		changed, release, err := n.Subscribe("acme", "database")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer release()
+			<-changed
+		}()
+	}
+	if got := n.Waiters(); got != waiters {
+		t.Fatalf("expected %d waiters, got %d", waiters, got)
+	}
+
+	n.Notify("acme", "database")
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected every waiter to wake up")
+	}
+	if got := n.Waiters(); got != 0 {
+		t.Fatalf("expected no waiters left, got %d", got)
+	}
+}
+
+func TestNotifier_ScopedByConfig(t *testing.T) {
+	n := New(DefaultMaxWaiters)
+	changed, release, _ := n.Subscribe("acme", "database")
+	defer release()
+
+	n.Notify("acme", "payments")
+	n.Notify("globex", "database")
+
+	select {
+	case <-changed:
+		t.Fatal("expected only changes of the same tenant and name to wake up the waiter")
+	default:
+	}
+
+	n.Notify("acme", "database")
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected waiter to be woken up")
+	}
+}
+
+func TestNotifier_Release(t *testing.T) {
+	n := New(2)
+	_, first, _ := n.Subscribe("acme", "database")
+	_, second, _ := n.Subscribe("acme", "database")
+
+	if _, _, err := n.Subscribe("acme", "payments"); !errors.Is(err, ErrTooManyWaiters) {
+		t.Fatalf("expected ErrTooManyWaiters, got %v", err)
+	}
+
+	// Releasing twice only counts once
+	first()
+	first()
+	if got := n.Waiters(); got != 1 {
+		t.Fatalf("expected 1 waiter, got %d", got)
+	}
+
+	second()
+	if len(n.topics) != 0 {
+		t.Fatalf("expected idle topics to be dropped, got %d", len(n.topics))
+	}
+
+	// A release after Notify must not touch the next subscription of the config
+	_, stale, _ := n.Subscribe("acme", "database")
+	n.Notify("acme", "database")
+	changed, fresh, _ := n.Subscribe("acme", "database")
+	stale()
+	if got := n.Waiters(); got != 1 || len(n.topics) != 1 {
+		t.Fatalf("expected the new subscription to survive, got %d waiters and %d topics", got, len(n.topics))
+	}
+	fresh()
+	select {
+	case <-changed:
+		t.Fatal("expected the new subscription not to be closed")
+	default:
+	}
+}
+
+func TestNotifier_SubscribeTenant(t *testing.T) {
+	n := New(DefaultMaxWaiters)
+	changed, release, _ := n.SubscribeTenant("acme")
+	defer release()
+
+	n.Notify("globex", "database")
+	select {
+	case <-changed:
+		t.Fatal("expected changes of another tenant not to wake up the waiter")
+	default:
+	}
+
+	n.Notify("acme", "payments")
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected any config of the tenant to wake up the waiter")
+	}
+}
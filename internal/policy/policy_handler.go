@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+type PolicyHandler struct {
+	service PolicyService
+}
+
+func NewPolicyHandler(service PolicyService) *PolicyHandler {
+	return &PolicyHandler{service: service}
+}
+
+func (h *PolicyHandler) CreateGrant(c *gin.Context) {
+	var req struct {
+		UserID  uuid.UUID        `json:"user_id" binding:"required"`
+		Pattern string           `json:"pattern" binding:"required"`
+		Role    models.GrantRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	g := &models.ConfigGrant{
+		ClientID:  c.GetString("client_id"),
+		UserID:    req.UserID,
+		Pattern:   req.Pattern,
+		Role:      req.Role,
+		CreatedBy: c.GetString("user_id"),
+	}
+	if err := h.service.Grant(g); err != nil {
+		writePolicyError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, g)
+}
+
+func (h *PolicyHandler) ListGrants(c *gin.Context) {
+	var userID *uuid.UUID
+	if s := c.Query("user_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		userID = &id
+	}
+
+	grants, err := h.service.ListGrants(c.GetString("client_id"), userID)
+	if err != nil {
+		writePolicyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+func (h *PolicyHandler) DeleteGrant(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grant id"})
+		return
+	}
+
+	if err := h.service.RevokeGrant(c.GetString("client_id"), id); err != nil {
+		writePolicyError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// WhoCanAccess answers "who can access config X"
+func (h *PolicyHandler) WhoCanAccess(c *gin.Context) {
+	access, err := h.service.WhoCanAccess(c.GetString("client_id"), c.Param("name"))
+	if err != nil {
+		writePolicyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, access)
+}
+
+func writePolicyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrGrantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidGrantRole), errors.Is(err, ErrInvalidPattern), errors.Is(err, ErrUnknownUser):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Println("policy service error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+func setupPolicyHandlerRouter(t *testing.T) (*gin.Engine, *PolicyHandler, models.User) {
+	db := setupPolicyTestDB(t)
+	user := createTestUser(t, db, "alice", models.RoleUser)
+	h := NewPolicyHandler(NewPolicyService(NewPolicyRepo(db)))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("client_id", testClientID)
+	})
+	r.POST("/grants", h.CreateGrant)
+	r.GET("/grants", h.ListGrants)
+	r.DELETE("/grants/:id", h.DeleteGrant)
+	r.GET("/configs/:name/access", h.WhoCanAccess)
+	return r, h, user
+}
+
+func TestPolicyHandler_CreateGrant(t *testing.T) {
+	r, _, user := setupPolicyHandlerRouter(t)
+
+	body := bytes.NewBufferString(`{"user_id":"` + user.ID.String() + `","pattern":"payments.*","role":"editor"}`)
+	req := httptest.NewRequest(http.MethodPost, "/grants", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/configs/payments.gateway/access", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"username":"alice"`)) {
+		t.Fatalf("expected alice to have access, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPolicyHandler_CreateGrant_InvalidRole(t *testing.T) {
+	r, _, user := setupPolicyHandlerRouter(t)
+
+	body := bytes.NewBufferString(`{"user_id":"` + user.ID.String() + `","pattern":"payments.*","role":"superuser"}`)
+	req := httptest.NewRequest(http.MethodPost, "/grants", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestPolicyHandler_ListGrants_InvalidUserID(t *testing.T) {
+	r, _, _ := setupPolicyHandlerRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/grants?user_id=nope", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestPolicyHandler_DeleteGrant_NotFound(t *testing.T) {
+	r, _, _ := setupPolicyHandlerRouter(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/grants/"+uuid.NewString(), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
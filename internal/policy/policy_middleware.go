@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NameFunc extracts the config name a request targets
+type NameFunc func(c *gin.Context) string
+
+// NameFromParam reads the config name from a route parameter
+func NameFromParam(param string) NameFunc {
+	return func(c *gin.Context) string {
+		return c.Param(param)
+	}
+}
+
+// NameFromBody reads the config name from a field of the JSON body and leaves
+// the body in place for the handler.
+func NameFromBody(field string) NameFunc {
+	return func(c *gin.Context) string {
+		if c.Request.Body == nil {
+			return ""
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return ""
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return ""
+		}
+		// Match the case-insensitive field lookup of ShouldBindJSON
+		for k, v := range doc {
+			if strings.EqualFold(k, field) {
+				name, _ := v.(string)
+				return name
+			}
+		}
+		return ""
+	}
+}
+
+// RequireConfigAccess only lets the request through when the user may perform
+// action on the targeted config. It must run after AuthMiddleware.
+func RequireConfigAccess(svc PolicyService, action Action, nameFn NameFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roleVal, exists := c.Get("role")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "role not found"})
+			return
+		}
+		role, ok := roleVal.(string)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "invalid role type"})
+			return
+		}
+		userID, ok := c.Get("user_id")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+		userIDStr, ok := userID.(string)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+
+		name := nameFn(c)
+		if name == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "config name is required"})
+			return
+		}
+
+		allowed, err := svc.Authorize(userIDStr, role, name, action)
+		if err != nil {
+			fmt.Println("failed to authorize request:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "you are not authorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ScopeConfigAccess stores the name patterns the user may perform action on as
+// "name_patterns" for handlers listing several configs. It must run after AuthMiddleware.
+func ScopeConfigAccess(svc PolicyService, action Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roleVal, exists := c.Get("role")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "role not found"})
+			return
+		}
+		role, ok := roleVal.(string)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "invalid role type"})
+			return
+		}
+
+		patterns, err := svc.Patterns(c.GetString("user_id"), role, action)
+		if err != nil {
+			fmt.Println("failed to load access patterns:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		c.Set("name_patterns", patterns)
+		c.Next()
+	}
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"sass.com/configsvc/internal/models"
+)
+
+type stubPolicy struct {
+	PolicyService
+	allowed  bool
+	lastName string
+}
+
+func (s *stubPolicy) Patterns(userID, role string, action Action) ([]string, error) {
+	return []string{"payments.*"}, nil
+}
+
+func (s *stubPolicy) Authorize(userID, role, name string, action Action) (bool, error) {
+	s.lastName = name
+	return s.allowed, nil
+}
+
+func setupPolicyRouter(svc PolicyService, role interface{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if role != nil {
+			c.Set("role", role)
+		}
+		c.Set("user_id", "tester")
+	})
+	r.GET("/configs/:name", RequireConfigAccess(svc, ActionRead, NameFromParam("name")), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/configs", ScopeConfigAccess(svc, ActionRead), func(c *gin.Context) {
+		patterns := c.MustGet("name_patterns").([]string)
+		c.String(http.StatusOK, strings.Join(patterns, ","))
+	})
+	r.POST("/configs", RequireConfigAccess(svc, ActionWrite, NameFromBody("name")), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+func TestRequireConfigAccess_Allowed(t *testing.T) {
+	svc := &stubPolicy{allowed: true}
+	r := setupPolicyRouter(svc, string(models.RoleUser))
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/payments.gateway", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if svc.lastName != "payments.gateway" {
+		t.Fatalf("expected name from route param, got %q", svc.lastName)
+	}
+}
+
+func TestRequireConfigAccess_Denied(t *testing.T) {
+	r := setupPolicyRouter(&stubPolicy{allowed: false}, string(models.RoleUser))
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/payments.gateway", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+}
+
+func TestRequireConfigAccess_RoleNotSet(t *testing.T) {
+	r := setupPolicyRouter(&stubPolicy{allowed: true}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/configs/payments.gateway", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestRequireConfigAccess_NameFromBodyKeepsBody(t *testing.T) {
+	svc := &stubPolicy{allowed: true}
+	r := setupPolicyRouter(svc, string(models.RoleUser))
+
+	payload := `{"Name":"payments.gateway","input":"{}"}`
+	req := httptest.NewRequest(http.MethodPost, "/configs", bytes.NewBufferString(payload))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if svc.lastName != "payments.gateway" {
+		t.Fatalf("expected name from body, got %q", svc.lastName)
+	}
+	if w.Body.String() != payload {
+		t.Fatalf("expected handler to receive the original body, got %q", w.Body.String())
+	}
+}
+
+func TestRequireConfigAccess_MissingName(t *testing.T) {
+	r := setupPolicyRouter(&stubPolicy{allowed: true}, string(models.RoleUser))
+
+	req := httptest.NewRequest(http.MethodPost, "/configs", bytes.NewBufferString(`{"input":"{}"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestScopeConfigAccess(t *testing.T) {
+	r := setupPolicyRouter(&stubPolicy{}, string(models.RoleUser))
+
+	req := httptest.NewRequest(http.MethodGet, "/configs", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "payments.*" {
+		t.Fatalf("expected patterns in context, got %d %q", w.Code, w.Body.String())
+	}
+
+	r = setupPolicyRouter(&stubPolicy{}, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/configs", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without role, got %d", w.Code)
+	}
+}
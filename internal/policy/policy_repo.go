@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sass.com/configsvc/internal/models"
+)
+
+type PolicyRepo interface {
+	SaveGrant(g *models.ConfigGrant) error
+	DeleteGrant(clientID string, id uuid.UUID) (bool, error)
+	ListGrants(clientID string) ([]models.ConfigGrant, error)
+	ListGrantsByUser(userID uuid.UUID) ([]models.ConfigGrant, error)
+	ListUsers(ids []uuid.UUID) ([]models.User, error)
+	ListAdmins(clientID string) ([]models.User, error)
+}
+
+func NewPolicyRepo(db *gorm.DB) PolicyRepo {
+	return &PolicyRepoImpl{db: db}
+}
+
+type PolicyRepoImpl struct {
+	db *gorm.DB
+}
+
+// SaveGrant creates the grant, or changes the role of an existing grant on the same pattern
+func (r *PolicyRepoImpl) SaveGrant(g *models.ConfigGrant) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "pattern"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "created_by"}),
+	}).Create(g).Error
+}
+
+func (r *PolicyRepoImpl) DeleteGrant(clientID string, id uuid.UUID) (bool, error) {
+	res := r.db.Where("client_id = ? AND id = ?", clientID, id).Delete(&models.ConfigGrant{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *PolicyRepoImpl) ListGrants(clientID string) ([]models.ConfigGrant, error) {
+	var grants []models.ConfigGrant
+	if err := r.db.Where("client_id = ?", clientID).
+		Order("pattern ASC").
+		Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (r *PolicyRepoImpl) ListGrantsByUser(userID uuid.UUID) ([]models.ConfigGrant, error) {
+	var grants []models.ConfigGrant
+	if err := r.db.Where("user_id = ?", userID).
+		Order("pattern ASC").
+		Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (r *PolicyRepoImpl) ListUsers(ids []uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *PolicyRepoImpl) ListAdmins(clientID string) ([]models.User, error) {
+	var users []models.User
+	if err := r.db.Where("client_id = ? AND role = ? AND is_active = ?", clientID, models.RoleAdmin, 1).
+		Order("username ASC").
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
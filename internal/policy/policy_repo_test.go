@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/models"
+)
+
+func setupPolicyTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite in-memory: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.ConfigGrant{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	return db
+}
+
+const testClientID = "acme"
+
+func createTestUser(t *testing.T, db *gorm.DB, username string, role models.Role) models.User {
+	return createTenantUser(t, db, testClientID, username, role)
+}
+
+func createTenantUser(t *testing.T, db *gorm.DB, clientID, username string, role models.Role) models.User {
+	u := models.User{ID: uuid.New(), ClientID: clientID, Username: username, Role: role, IsActive: 1}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+	return u
+}
+
+func TestPolicyRepo_SaveGrant_UpsertsRole(t *testing.T) {
+	db := setupPolicyTestDB(t)
+	repo := NewPolicyRepo(db)
+	user := createTestUser(t, db, "alice", models.RoleUser)
+
+	first := &models.ConfigGrant{ID: uuid.New(), ClientID: testClientID, UserID: user.ID, Pattern: "payments.*", Role: models.GrantRoleViewer}
+	if err := repo.SaveGrant(first); err != nil {
+		t.Fatalf("failed to save grant: %v", err)
+	}
+	second := &models.ConfigGrant{ID: uuid.New(), ClientID: testClientID, UserID: user.ID, Pattern: "payments.*", Role: models.GrantRoleEditor}
+	if err := repo.SaveGrant(second); err != nil {
+		t.Fatalf("failed to save grant: %v", err)
+	}
+
+	grants, err := repo.ListGrantsByUser(user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(grants) != 1 || grants[0].Role != models.GrantRoleEditor {
+		t.Fatalf("expected a single editor grant, got %+v", grants)
+	}
+}
+
+func TestPolicyRepo_DeleteGrant(t *testing.T) {
+	db := setupPolicyTestDB(t)
+	repo := NewPolicyRepo(db)
+	user := createTestUser(t, db, "alice", models.RoleUser)
+
+	g := &models.ConfigGrant{ID: uuid.New(), ClientID: testClientID, UserID: user.ID, Pattern: "database", Role: models.GrantRoleViewer}
+	if err := repo.SaveGrant(g); err != nil {
+		t.Fatalf("failed to save grant: %v", err)
+	}
+
+	deleted, err := repo.DeleteGrant("globex", g.ID)
+	if err != nil || deleted {
+		t.Fatalf("expected another tenant not to delete the grant, got %v, %v", deleted, err)
+	}
+	deleted, err = repo.DeleteGrant(testClientID, g.ID)
+	if err != nil || !deleted {
+		t.Fatalf("expected grant to be deleted, got %v, %v", deleted, err)
+	}
+	deleted, err = repo.DeleteGrant(testClientID, g.ID)
+	if err != nil || deleted {
+		t.Fatalf("expected nothing to delete, got %v, %v", deleted, err)
+	}
+}
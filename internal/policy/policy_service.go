@@ -0,0 +1,247 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+// Action is what a request wants to do with a config
+type Action string
+
+const (
+	ActionRead    Action = "read"    // get latest, versions
+	ActionWrite   Action = "write"   // create, update
+	ActionPublish Action = "publish" // rollback
+	ActionManage  Action = "manage"  // delete, restore
+)
+
+// Lowest grant role allowed to perform each action
+var requiredRole = map[Action]models.GrantRole{
+	ActionRead:    models.GrantRoleViewer,
+	ActionWrite:   models.GrantRoleEditor,
+	ActionPublish: models.GrantRolePublisher,
+	ActionManage:  models.GrantRoleOwner,
+}
+
+var roleRank = map[models.GrantRole]int{
+	models.GrantRoleViewer:    1,
+	models.GrantRoleEditor:    2,
+	models.GrantRolePublisher: 3,
+	models.GrantRoleOwner:     4,
+}
+
+var (
+	ErrInvalidGrantRole = errors.New("invalid grant role")
+	ErrInvalidPattern   = errors.New("invalid pattern")
+	ErrGrantNotFound    = errors.New("grant not found")
+	ErrUnknownUser      = errors.New("user not found")
+)
+
+// Access describes one way a user can reach a config
+type Access struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Pattern  string    `json:"pattern"`
+}
+
+// Grants are managed per tenant (clientID). Authorize needs no tenant because
+// a user only belongs to one and config lookups are already tenant scoped.
+type PolicyService interface {
+	Authorize(userID, role, name string, action Action) (bool, error)
+	Patterns(userID, role string, action Action) ([]string, error)
+	Grant(g *models.ConfigGrant) error
+	RevokeGrant(clientID string, id uuid.UUID) error
+	ListGrants(clientID string, userID *uuid.UUID) ([]models.ConfigGrant, error)
+	WhoCanAccess(clientID, name string) ([]Access, error)
+}
+
+func NewPolicyService(repo PolicyRepo) PolicyService {
+	return &PolicyServiceImpl{repo: repo}
+}
+
+type PolicyServiceImpl struct {
+	repo PolicyRepo
+}
+
+// Authorize reports whether the user may perform action on the config name.
+// Admins may do everything, everybody else needs a matching grant.
+func (s *PolicyServiceImpl) Authorize(userID, role, name string, action Action) (bool, error) {
+	if role == string(models.RoleAdmin) {
+		return true, nil
+	}
+
+	required, ok := requiredRole[action]
+	if !ok {
+		return false, nil
+	}
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return false, nil
+	}
+
+	grants, err := s.repo.ListGrantsByUser(uid)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range grants {
+		if roleRank[g.Role] >= roleRank[required] && MatchPattern(g.Pattern, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Patterns returns the name patterns under which the user may perform action,
+// so listings can be filtered without authorizing every config on its own.
+func (s *PolicyServiceImpl) Patterns(userID, role string, action Action) ([]string, error) {
+	if role == string(models.RoleAdmin) {
+		return []string{"*"}, nil
+	}
+
+	required, ok := requiredRole[action]
+	if !ok {
+		return []string{}, nil
+	}
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return []string{}, nil
+	}
+
+	grants, err := s.repo.ListGrantsByUser(uid)
+	if err != nil {
+		return nil, err
+	}
+	patterns := []string{}
+	for _, g := range grants {
+		if roleRank[g.Role] >= roleRank[required] {
+			patterns = append(patterns, g.Pattern)
+		}
+	}
+	return patterns, nil
+}
+
+func (s *PolicyServiceImpl) Grant(g *models.ConfigGrant) error {
+	if _, ok := roleRank[g.Role]; !ok {
+		return ErrInvalidGrantRole
+	}
+	if !IsValidPattern(g.Pattern) {
+		return ErrInvalidPattern
+	}
+
+	// Only users of the grant's own tenant can be granted access
+	users, err := s.repo.ListUsers([]uuid.UUID{g.UserID})
+	if err != nil {
+		return err
+	}
+	if len(users) != 1 || users[0].ClientID != g.ClientID {
+		return ErrUnknownUser
+	}
+
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return s.repo.SaveGrant(g)
+}
+
+func (s *PolicyServiceImpl) RevokeGrant(clientID string, id uuid.UUID) error {
+	deleted, err := s.repo.DeleteGrant(clientID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrGrantNotFound
+	}
+	return nil
+}
+
+// ListGrants returns every grant of the tenant, or only those of userID when given
+func (s *PolicyServiceImpl) ListGrants(clientID string, userID *uuid.UUID) ([]models.ConfigGrant, error) {
+	grants, err := s.repo.ListGrants(clientID)
+	if err != nil || userID == nil {
+		return grants, err
+	}
+
+	filtered := make([]models.ConfigGrant, 0, len(grants))
+	for _, g := range grants {
+		if g.UserID == *userID {
+			filtered = append(filtered, g)
+		}
+	}
+	return filtered, nil
+}
+
+// WhoCanAccess lists the tenant's admins and every grant whose pattern covers name
+func (s *PolicyServiceImpl) WhoCanAccess(clientID, name string) ([]Access, error) {
+	admins, err := s.repo.ListAdmins(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	access := make([]Access, 0, len(admins))
+	for _, u := range admins {
+		access = append(access, Access{UserID: u.ID, Username: u.Username, Role: string(models.RoleAdmin), Pattern: "*"})
+	}
+
+	grants, err := s.repo.ListGrants(clientID)
+	if err != nil {
+		return nil, err
+	}
+	var matched []models.ConfigGrant
+	var userIDs []uuid.UUID
+	for _, g := range grants {
+		if MatchPattern(g.Pattern, name) {
+			matched = append(matched, g)
+			userIDs = append(userIDs, g.UserID)
+		}
+	}
+
+	users, err := s.repo.ListUsers(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make(map[uuid.UUID]string, len(users))
+	for _, u := range users {
+		// Disabled users keep their grants but cannot log in
+		if u.IsActive == 1 {
+			usernames[u.ID] = u.Username
+		}
+	}
+	for _, g := range matched {
+		username, ok := usernames[g.UserID]
+		if !ok {
+			continue
+		}
+		access = append(access, Access{UserID: g.UserID, Username: username, Role: string(g.Role), Pattern: g.Pattern})
+	}
+	return access, nil
+}
+
+// MatchPattern matches a config name against a grant pattern:
+// "*" matches everything, "payments.*" matches "payments.gateway" and
+// "payments.eu.limits" but not "payments", anything else must match exactly.
+func MatchPattern(pattern, name string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return pattern == name
+}
+
+// IsValidPattern reports whether pattern is a name, a "prefix.*" or "*"
+func IsValidPattern(pattern string) bool {
+	if pattern == "" || len(pattern) > 100 {
+		return false
+	}
+	star := strings.Index(pattern, "*")
+	if star == -1 || pattern == "*" {
+		return true
+	}
+	// Only a trailing ".*" wildcard is supported
+	return star == len(pattern)-1 && strings.HasSuffix(pattern, ".*")
+}
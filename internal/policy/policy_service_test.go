@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*", "anything", true},
+		{"database", "database", true},
+		{"database", "database_replica", false},
+		{"payments.*", "payments.gateway", true},
+		{"payments.*", "payments.eu.limits", true},
+		{"payments.*", "payments", false},
+		{"payments.*", "paymentsx.gateway", false},
+	}
+	for _, tc := range cases {
+		if got := MatchPattern(tc.pattern, tc.name); got != tc.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyService_Authorize_RoleHierarchy(t *testing.T) {
+	db := setupPolicyTestDB(t)
+	svc := NewPolicyService(NewPolicyRepo(db))
+	user := createTestUser(t, db, "alice", models.RoleUser)
+
+	if err := svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: user.ID, Pattern: "payments.*", Role: models.GrantRoleEditor}); err != nil {
+		t.Fatalf("failed to grant: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		action Action
+		want   bool
+	}{
+		{"payments.gateway", ActionRead, true},
+		{"payments.gateway", ActionWrite, true},
+		{"payments.gateway", ActionPublish, false},
+		{"payments.gateway", ActionManage, false},
+		{"billing", ActionRead, false},
+	}
+	for _, tc := range cases {
+		got, err := svc.Authorize(user.ID.String(), string(models.RoleUser), tc.name, tc.action)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("Authorize(%s, %s) = %v, want %v", tc.name, tc.action, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyService_Authorize_AdminBypass(t *testing.T) {
+	svc := NewPolicyService(NewPolicyRepo(setupPolicyTestDB(t)))
+
+	ok, err := svc.Authorize(uuid.NewString(), string(models.RoleAdmin), "anything", ActionManage)
+	if err != nil || !ok {
+		t.Fatalf("expected admin to be allowed, got %v, %v", ok, err)
+	}
+}
+
+func TestPolicyService_Grant_Invalid(t *testing.T) {
+	svc := NewPolicyService(NewPolicyRepo(setupPolicyTestDB(t)))
+
+	err := svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: uuid.New(), Pattern: "payments.*", Role: "superuser"})
+	if !errors.Is(err, ErrInvalidGrantRole) {
+		t.Fatalf("expected ErrInvalidGrantRole, got %v", err)
+	}
+	for _, pattern := range []string{"", "pay*", "payments.*.limits", "*.gateway"} {
+		err := svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: uuid.New(), Pattern: pattern, Role: models.GrantRoleViewer})
+		if !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("pattern %q: expected ErrInvalidPattern, got %v", pattern, err)
+		}
+	}
+}
+
+func TestPolicyService_RevokeGrant_NotFound(t *testing.T) {
+	svc := NewPolicyService(NewPolicyRepo(setupPolicyTestDB(t)))
+
+	if err := svc.RevokeGrant(testClientID, uuid.New()); !errors.Is(err, ErrGrantNotFound) {
+		t.Fatalf("expected ErrGrantNotFound, got %v", err)
+	}
+}
+
+func TestPolicyService_WhoCanAccess(t *testing.T) {
+	db := setupPolicyTestDB(t)
+	svc := NewPolicyService(NewPolicyRepo(db))
+
+	admin := createTestUser(t, db, "admin", models.RoleAdmin)
+	alice := createTestUser(t, db, "alice", models.RoleUser)
+	bob := createTestUser(t, db, "bob", models.RoleUser)
+	createTenantUser(t, db, "globex", "root", models.RoleAdmin)
+
+	_ = svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: alice.ID, Pattern: "payments.*", Role: models.GrantRoleViewer})
+	_ = svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: bob.ID, Pattern: "billing", Role: models.GrantRoleOwner})
+
+	access, err := svc.WhoCanAccess(testClientID, "payments.gateway")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(access) != 2 {
+		t.Fatalf("expected admin and alice, got %+v", access)
+	}
+	if access[0].UserID != admin.ID || access[0].Role != string(models.RoleAdmin) {
+		t.Errorf("expected admin first, got %+v", access[0])
+	}
+	if access[1].UserID != alice.ID || access[1].Role != string(models.GrantRoleViewer) {
+		t.Errorf("expected alice as viewer, got %+v", access[1])
+	}
+}
+
+func TestPolicyService_Grant_OtherTenantUser(t *testing.T) {
+	db := setupPolicyTestDB(t)
+	svc := NewPolicyService(NewPolicyRepo(db))
+	carol := createTenantUser(t, db, "globex", "carol", models.RoleUser)
+
+	err := svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: carol.ID, Pattern: "*", Role: models.GrantRoleOwner})
+	if !errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("expected ErrUnknownUser, got %v", err)
+	}
+	if err := svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: uuid.New(), Pattern: "*", Role: models.GrantRoleOwner}); !errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("expected ErrUnknownUser for missing user, got %v", err)
+	}
+}
+
+func TestPolicyService_ListGrants_ScopedByTenant(t *testing.T) {
+	db := setupPolicyTestDB(t)
+	svc := NewPolicyService(NewPolicyRepo(db))
+	alice := createTestUser(t, db, "alice", models.RoleUser)
+	carol := createTenantUser(t, db, "globex", "carol", models.RoleUser)
+
+	_ = svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: alice.ID, Pattern: "database", Role: models.GrantRoleViewer})
+	_ = svc.Grant(&models.ConfigGrant{ClientID: "globex", UserID: carol.ID, Pattern: "database", Role: models.GrantRoleViewer})
+
+	grants, err := svc.ListGrants(testClientID, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(grants) != 1 || grants[0].UserID != alice.ID {
+		t.Fatalf("expected only alice's grant, got %+v", grants)
+	}
+	if grants, _ := svc.ListGrants(testClientID, &carol.ID); len(grants) != 0 {
+		t.Fatalf("expected no grants for another tenant's user, got %+v", grants)
+	}
+}
+
+func TestPolicyService_Patterns(t *testing.T) {
+	db := setupPolicyTestDB(t)
+	svc := NewPolicyService(NewPolicyRepo(db))
+	alice := createTestUser(t, db, "alice", models.RoleUser)
+
+	_ = svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: alice.ID, Pattern: "payments.*", Role: models.GrantRoleViewer})
+	_ = svc.Grant(&models.ConfigGrant{ClientID: testClientID, UserID: alice.ID, Pattern: "database", Role: models.GrantRoleEditor})
+
+	read, err := svc.Patterns(alice.ID.String(), string(models.RoleUser), ActionRead)
+	if err != nil || len(read) != 2 {
+		t.Fatalf("expected both patterns readable, got %v, %v", read, err)
+	}
+	write, _ := svc.Patterns(alice.ID.String(), string(models.RoleUser), ActionWrite)
+	if len(write) != 1 || write[0] != "database" {
+		t.Fatalf("expected only database writable, got %v", write)
+	}
+	admin, _ := svc.Patterns(uuid.NewString(), string(models.RoleAdmin), ActionManage)
+	if len(admin) != 1 || admin[0] != "*" {
+		t.Fatalf("expected admin to match everything, got %v", admin)
+	}
+}
@@ -0,0 +1,100 @@
+package schemaregistry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	configdata "sass.com/configsvc/internal/config_data"
+)
+
+type SchemaHandler struct {
+	service SchemaService
+}
+
+func NewSchemaHandler(service SchemaService) *SchemaHandler {
+	return &SchemaHandler{service: service}
+}
+
+// RegisterSchema answers 201 with the new version, or 200 with the latest
+// one when it is the same schema. The body goes through
+// configdata.NormalizeConfigBody first, so the schema may be an object.
+func (h *SchemaHandler) RegisterSchema(c *gin.Context) {
+	var req SchemaInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	registered, created, err := h.service.Register(c.GetString("client_id"), c.GetString("user_id"), c.Param("id"), req)
+	if err != nil {
+		writeSchemaError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, registered)
+		return
+	}
+	c.JSON(http.StatusCreated, registered)
+}
+
+func (h *SchemaHandler) ListSchemas(c *gin.Context) {
+	schemas, err := h.service.List(c.GetString("client_id"))
+	if err != nil {
+		writeSchemaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schemas)
+}
+
+func (h *SchemaHandler) ListSchemaVersions(c *gin.Context) {
+	versions, err := h.service.ListVersions(c.GetString("client_id"), c.Param("id"))
+	if err != nil {
+		writeSchemaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetSchemaVersion takes a version number or "latest"
+func (h *SchemaHandler) GetSchemaVersion(c *gin.Context) {
+	version := 0
+	if v := c.Param("version"); v != "latest" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+		version = n
+	}
+
+	registered, err := h.service.Get(c.GetString("client_id"), c.Param("id"), version)
+	if err != nil {
+		writeSchemaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, registered)
+}
+
+func writeSchemaError(c *gin.Context, err error) {
+	var ve *configdata.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		violations := ve.Violations
+		if violations == nil {
+			violations = []configdata.Violation{}
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": ve.Err.Error(), "violations": violations})
+	case errors.Is(err, ErrSchemaNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidSchemaID), errors.Is(err, ErrSchemaRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		fmt.Println("schema registry error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
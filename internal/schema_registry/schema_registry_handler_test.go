@@ -0,0 +1,133 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	configdata "sass.com/configsvc/internal/config_data"
+	"sass.com/configsvc/internal/models"
+)
+
+func setupSchemaHandlerRouter(t *testing.T) *gin.Engine {
+	h := NewSchemaHandler(setupSchemaService(t))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("client_id", testClientID)
+		c.Set("user_id", "alice")
+	})
+	r.POST("/schemas/:id/versions", configdata.NormalizeConfigBody(), h.RegisterSchema)
+	r.GET("/schemas", h.ListSchemas)
+	r.GET("/schemas/:id/versions", h.ListSchemaVersions)
+	r.GET("/schemas/:id/versions/:version", h.GetSchemaVersion)
+	return r
+}
+
+func doSchemaRequest(r *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSchemaHandler_RegisterSchema(t *testing.T) {
+	r := setupSchemaHandlerRouter(t)
+
+	w := doSchemaRequest(r, http.MethodPost, "/schemas/port/versions", "application/json", `{"schema":{"type":"integer","minimum":1}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	var s models.RegisteredSchema
+	json.Unmarshal(w.Body.Bytes(), &s)
+	if s.Version != 1 || s.Schema != `{"minimum":1,"type":"integer"}` {
+		t.Fatalf("expected version 1 in canonical JSON, got %+v", s)
+	}
+
+	w = doSchemaRequest(r, http.MethodPost, "/schemas/port/versions", "application/yaml", "schema:\n  type: integer\n  minimum: 1\n")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the same schema, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doSchemaRequest(r, http.MethodPost, "/schemas/database/versions", "application/json", `{"schema":{"$ref":"/schemas/port/versions/2"}}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unregistered ref, got %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Violations []configdata.Violation `json:"violations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Violations) == 0 {
+		t.Fatalf("expected violations, got %s", w.Body.String())
+	}
+
+	w = doSchemaRequest(r, http.MethodPost, "/schemas/a%20b/versions", "application/json", `{"schema":{}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSchemaHandler_GetSchemaVersion(t *testing.T) {
+	r := setupSchemaHandlerRouter(t)
+	doSchemaRequest(r, http.MethodPost, "/schemas/port/versions", "application/json", `{"schema":{"type":"integer"}}`)
+	doSchemaRequest(r, http.MethodPost, "/schemas/port/versions", "application/json", `{"schema":{"type":"integer","minimum":1}}`)
+
+	tests := []struct {
+		path    string
+		code    int
+		version int
+	}{
+		{"/schemas/port/versions/1", http.StatusOK, 1},
+		{"/schemas/port/versions/latest", http.StatusOK, 2},
+		{"/schemas/port/versions/3", http.StatusNotFound, 0},
+		{"/schemas/port/versions/0", http.StatusBadRequest, 0},
+		{"/schemas/cache/versions/latest", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		w := doSchemaRequest(r, http.MethodGet, tt.path, "", "")
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d %s", tt.path, tt.code, w.Code, w.Body.String())
+			continue
+		}
+		var s models.RegisteredSchema
+		json.Unmarshal(w.Body.Bytes(), &s)
+		if s.Version != tt.version {
+			t.Errorf("%s: expected version %d, got %d", tt.path, tt.version, s.Version)
+		}
+	}
+}
+
+func TestSchemaHandler_List(t *testing.T) {
+	r := setupSchemaHandlerRouter(t)
+
+	w := doSchemaRequest(r, http.MethodGet, "/schemas", "", "")
+	if w.Code != http.StatusOK || w.Body.String() != "[]" {
+		t.Fatalf("expected an empty list, got %d %s", w.Code, w.Body.String())
+	}
+
+	doSchemaRequest(r, http.MethodPost, "/schemas/port/versions", "application/json", `{"schema":{"type":"integer"}}`)
+	doSchemaRequest(r, http.MethodPost, "/schemas/port/versions", "application/json", `{"schema":{"type":"integer","minimum":1}}`)
+
+	var schemas []models.RegisteredSchema
+	w = doSchemaRequest(r, http.MethodGet, "/schemas", "", "")
+	json.Unmarshal(w.Body.Bytes(), &schemas)
+	if len(schemas) != 1 || schemas[0].Version != 2 {
+		t.Fatalf("expected port version 2, got %s", w.Body.String())
+	}
+
+	w = doSchemaRequest(r, http.MethodGet, "/schemas/port/versions", "", "")
+	json.Unmarshal(w.Body.Bytes(), &schemas)
+	if len(schemas) != 2 {
+		t.Fatalf("expected 2 versions, got %s", w.Body.String())
+	}
+	if w := doSchemaRequest(r, http.MethodGet, "/schemas/cache/versions", "", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
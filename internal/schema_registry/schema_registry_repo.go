@@ -0,0 +1,103 @@
+package schemaregistry
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/models"
+)
+
+// Registered schemas are scoped by clientID (the tenant)
+type SchemaRepo interface {
+	Create(s *models.RegisteredSchema, expectedVersion int) error
+	Get(clientID, schemaID string, version int) (*models.RegisteredSchema, error)
+	GetLatest(clientID, schemaID string) (*models.RegisteredSchema, error)
+	ListVersions(clientID, schemaID string) ([]models.RegisteredSchema, error)
+	ListLatest(clientID string) ([]models.RegisteredSchema, error)
+}
+
+func NewSchemaRepo(db *gorm.DB) SchemaRepo {
+	return &SchemaRepoImpl{db: db}
+}
+
+type SchemaRepoImpl struct {
+	db *gorm.DB
+}
+
+// Create stores s as version expectedVersion+1 of its schema, or fails with
+// ErrVersionConflict when another version was registered meanwhile
+func (r *SchemaRepoImpl) Create(s *models.RegisteredSchema, expectedVersion int) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.RegisteredSchema{}).
+			Where("client_id = ? AND schema_id = ?", s.ClientID, s.SchemaID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		if latest != expectedVersion {
+			return ErrVersionConflict
+		}
+		s.Version = latest + 1
+		return tx.Create(s).Error
+	})
+	if err != nil && (errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "UNIQUE constraint failed")) {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+// Get returns nil, nil when the tenant has no such version
+func (r *SchemaRepoImpl) Get(clientID, schemaID string, version int) (*models.RegisteredSchema, error) {
+	var s models.RegisteredSchema
+	if err := r.db.Where("client_id = ? AND schema_id = ? AND version = ?", clientID, schemaID, version).
+		First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetLatest returns nil, nil when the tenant has no such schema
+func (r *SchemaRepoImpl) GetLatest(clientID, schemaID string) (*models.RegisteredSchema, error) {
+	var s models.RegisteredSchema
+	if err := r.db.Where("client_id = ? AND schema_id = ?", clientID, schemaID).
+		Order("version DESC").
+		First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListVersions returns every version of a schema, oldest first
+func (r *SchemaRepoImpl) ListVersions(clientID, schemaID string) ([]models.RegisteredSchema, error) {
+	var versions []models.RegisteredSchema
+	if err := r.db.Where("client_id = ? AND schema_id = ?", clientID, schemaID).
+		Order("version ASC").
+		Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// ListLatest returns the latest version of every schema of the tenant, by id
+func (r *SchemaRepoImpl) ListLatest(clientID string) ([]models.RegisteredSchema, error) {
+	latest := r.db.Model(&models.RegisteredSchema{}).
+		Select("schema_id, MAX(version)").
+		Where("client_id = ?", clientID).
+		Group("schema_id")
+
+	var schemas []models.RegisteredSchema
+	if err := r.db.Where("client_id = ? AND (schema_id, version) IN (?)", clientID, latest).
+		Order("schema_id ASC").
+		Find(&schemas).Error; err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
@@ -0,0 +1,98 @@
+package schemaregistry
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/models"
+)
+
+const testClientID = "acme"
+
+func setupSchemaTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite in-memory: %v", err)
+	}
+	if err := db.AutoMigrate(&models.RegisteredSchema{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	return db
+}
+
+func createTestSchema(t *testing.T, repo SchemaRepo, clientID, schemaID, schema string, expectedVersion int) *models.RegisteredSchema {
+	s := &models.RegisteredSchema{ID: uuid.New(), ClientID: clientID, SchemaID: schemaID, Schema: schema}
+	if err := repo.Create(s, expectedVersion); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	return s
+}
+
+func TestSchemaRepo_CreateAndGet(t *testing.T) {
+	repo := NewSchemaRepo(setupSchemaTestDB(t))
+
+	v1 := createTestSchema(t, repo, testClientID, "database", `{"type":"object"}`, 0)
+	v2 := createTestSchema(t, repo, testClientID, "database", `{"type":"object","required":["host"]}`, 1)
+	createTestSchema(t, repo, "other", "database", `{}`, 0)
+	if v1.Version != 1 || v2.Version != 2 {
+		t.Fatalf("expected versions 1 and 2, got %d and %d", v1.Version, v2.Version)
+	}
+
+	got, err := repo.Get(testClientID, "database", 1)
+	if err != nil || got == nil || got.Schema != `{"type":"object"}` {
+		t.Fatalf("expected version 1, got %+v %v", got, err)
+	}
+	latest, err := repo.GetLatest(testClientID, "database")
+	if err != nil || latest == nil || latest.Version != 2 {
+		t.Fatalf("expected version 2 as latest, got %+v %v", latest, err)
+	}
+	if got, err := repo.Get(testClientID, "database", 3); got != nil || err != nil {
+		t.Fatalf("expected nil, nil for an unknown version, got %+v %v", got, err)
+	}
+	if got, err := repo.GetLatest(testClientID, "cache"); got != nil || err != nil {
+		t.Fatalf("expected nil, nil for an unknown schema, got %+v %v", got, err)
+	}
+}
+
+func TestSchemaRepo_Create_VersionConflict(t *testing.T) {
+	repo := NewSchemaRepo(setupSchemaTestDB(t))
+	createTestSchema(t, repo, testClientID, "database", `{}`, 0)
+
+	s := &models.RegisteredSchema{ID: uuid.New(), ClientID: testClientID, SchemaID: "database", Schema: `{}`}
+	if err := repo.Create(s, 0); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+}
+
+func TestSchemaRepo_Create_InvalidJSON(t *testing.T) {
+	repo := NewSchemaRepo(setupSchemaTestDB(t))
+
+	s := &models.RegisteredSchema{ID: uuid.New(), ClientID: testClientID, SchemaID: "database", Schema: `{`}
+	if err := repo.Create(s, 0); err == nil {
+		t.Fatal("expected the json_valid check to fail")
+	}
+}
+
+func TestSchemaRepo_ListVersionsAndLatest(t *testing.T) {
+	repo := NewSchemaRepo(setupSchemaTestDB(t))
+	createTestSchema(t, repo, testClientID, "port", `{"type":"integer"}`, 0)
+	createTestSchema(t, repo, testClientID, "database", `{}`, 0)
+	createTestSchema(t, repo, testClientID, "database", `{"type":"object"}`, 1)
+	createTestSchema(t, repo, "other", "cache", `{}`, 0)
+
+	versions, err := repo.ListVersions(testClientID, "database")
+	if err != nil || len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Fatalf("expected versions 1 and 2, got %+v %v", versions, err)
+	}
+
+	latest, err := repo.ListLatest(testClientID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(latest) != 2 || latest[0].SchemaID != "database" || latest[0].Version != 2 || latest[1].SchemaID != "port" {
+		t.Fatalf("expected database v2 and port v1, got %+v", latest)
+	}
+}
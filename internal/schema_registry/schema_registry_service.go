@@ -0,0 +1,135 @@
+package schemaregistry
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/google/uuid"
+	configdata "sass.com/configsvc/internal/config_data"
+	"sass.com/configsvc/internal/models"
+)
+
+var (
+	ErrSchemaNotFound  = errors.New("schema not found")
+	ErrInvalidSchemaID = errors.New("schema id must be 1 to 100 letters, digits, '.', '_' or '-'")
+	ErrSchemaRequired  = errors.New("schema is required")
+	ErrVersionConflict = errors.New("schema version has changed")
+)
+
+var schemaIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// SchemaInput is a schema to register, Schema is a JSON document
+type SchemaInput struct {
+	Schema      string `json:"schema"`
+	Description string `json:"description"`
+}
+
+type SchemaService interface {
+	Register(clientID, actor, schemaID string, in SchemaInput) (*models.RegisteredSchema, bool, error)
+	Get(clientID, schemaID string, version int) (*models.RegisteredSchema, error)
+	ListVersions(clientID, schemaID string) ([]models.RegisteredSchema, error)
+	List(clientID string) ([]models.RegisteredSchema, error)
+	LookupSchema(clientID, schemaID string, version int) (string, error)
+}
+
+func NewSchemaService(repo SchemaRepo) SchemaService {
+	return &SchemaServiceImpl{repo: repo}
+}
+
+type SchemaServiceImpl struct {
+	repo SchemaRepo
+}
+
+// Register stores the schema as the next version of schemaID. Registering
+// the latest version again changes nothing and returns it, with false.
+// The schema may refer to other registered schemas, which must exist.
+func (s *SchemaServiceImpl) Register(clientID, actor, schemaID string, in SchemaInput) (*models.RegisteredSchema, bool, error) {
+	if !schemaIDPattern.MatchString(schemaID) {
+		return nil, false, ErrInvalidSchemaID
+	}
+	if in.Schema == "" {
+		return nil, false, ErrSchemaRequired
+	}
+
+	latest, err := s.repo.GetLatest(clientID, schemaID)
+	if err != nil {
+		return nil, false, err
+	}
+	version := 0
+	if latest != nil {
+		if latest.Schema == in.Schema {
+			return latest, false, nil
+		}
+		version = latest.Version
+	}
+	if err := configdata.ValidateRegisteredSchema(clientID, schemaID, version+1, in.Schema); err != nil {
+		return nil, false, err
+	}
+
+	registered := &models.RegisteredSchema{
+		ID:          uuid.New(),
+		ClientID:    clientID,
+		SchemaID:    schemaID,
+		Schema:      in.Schema,
+		Description: in.Description,
+		CreatedBy:   actor,
+	}
+	if err := s.repo.Create(registered, version); err != nil {
+		return nil, false, err
+	}
+	return registered, true, nil
+}
+
+// Get returns a version of a schema, the latest one for version 0
+func (s *SchemaServiceImpl) Get(clientID, schemaID string, version int) (*models.RegisteredSchema, error) {
+	var registered *models.RegisteredSchema
+	var err error
+	if version == 0 {
+		registered, err = s.repo.GetLatest(clientID, schemaID)
+	} else {
+		registered, err = s.repo.Get(clientID, schemaID, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if registered == nil {
+		return nil, ErrSchemaNotFound
+	}
+	return registered, nil
+}
+
+func (s *SchemaServiceImpl) ListVersions(clientID, schemaID string) ([]models.RegisteredSchema, error) {
+	versions, err := s.repo.ListVersions(clientID, schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrSchemaNotFound
+	}
+	return versions, nil
+}
+
+// List returns the latest version of every schema
+func (s *SchemaServiceImpl) List(clientID string) ([]models.RegisteredSchema, error) {
+	schemas, err := s.repo.ListLatest(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if schemas == nil {
+		schemas = []models.RegisteredSchema{}
+	}
+	return schemas, nil
+}
+
+// LookupSchema resolves the $refs of configs and registered schemas, see
+// configdata.SchemaRegistry
+func (s *SchemaServiceImpl) LookupSchema(clientID, schemaID string, version int) (string, error) {
+	registered, err := s.repo.Get(clientID, schemaID, version)
+	if err != nil {
+		return "", err
+	}
+	if registered == nil {
+		return "", configdata.ErrSchemaNotRegistered
+	}
+	return registered.Schema, nil
+}
@@ -0,0 +1,95 @@
+package schemaregistry
+
+import (
+	"errors"
+	"testing"
+
+	configdata "sass.com/configsvc/internal/config_data"
+)
+
+func setupSchemaService(t *testing.T) SchemaService {
+	service := NewSchemaService(NewSchemaRepo(setupSchemaTestDB(t)))
+	configdata.UseSchemaRegistry(service)
+	t.Cleanup(func() { configdata.UseSchemaRegistry(nil) })
+	return service
+}
+
+func TestSchemaService_Register(t *testing.T) {
+	service := setupSchemaService(t)
+
+	s, created, err := service.Register(testClientID, "alice", "database", SchemaInput{Schema: `{"type":"object"}`, Description: "A database"})
+	if err != nil || !created || s.Version != 1 || s.CreatedBy != "alice" {
+		t.Fatalf("expected version 1 to be created, got %+v %v %v", s, created, err)
+	}
+
+	// The same schema again is a no-op
+	s, created, err = service.Register(testClientID, "bob", "database", SchemaInput{Schema: `{"type":"object"}`})
+	if err != nil || created || s.Version != 1 {
+		t.Fatalf("expected version 1 unchanged, got %+v %v %v", s, created, err)
+	}
+
+	s, created, err = service.Register(testClientID, "bob", "database", SchemaInput{Schema: `{"type":"object","required":["host"]}`})
+	if err != nil || !created || s.Version != 2 {
+		t.Fatalf("expected version 2 to be created, got %+v %v %v", s, created, err)
+	}
+}
+
+func TestSchemaService_Register_Invalid(t *testing.T) {
+	service := setupSchemaService(t)
+
+	tests := []struct {
+		name, id, schema string
+		err              error
+	}{
+		{"bad id", "../db", `{}`, ErrInvalidSchemaID},
+		{"empty id", "", `{}`, ErrInvalidSchemaID},
+		{"no schema", "database", "", ErrSchemaRequired},
+		{"invalid schema", "database", `{"type":"nope"}`, configdata.ErrInvalidSchema},
+		{"unregistered ref", "database", `{"$ref":"/schemas/port/versions/1"}`, configdata.ErrInvalidSchema},
+		{"file ref", "database", `{"$ref":"file:///etc/passwd"}`, configdata.ErrInvalidSchema},
+	}
+	for _, tt := range tests {
+		if _, _, err := service.Register(testClientID, "alice", tt.id, SchemaInput{Schema: tt.schema}); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestSchemaService_Register_Refs(t *testing.T) {
+	service := setupSchemaService(t)
+
+	if _, _, err := service.Register(testClientID, "alice", "port", SchemaInput{Schema: `{"type":"integer","minimum":1}`}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Relative to /schemas/database/versions/1
+	if _, _, err := service.Register(testClientID, "alice", "database", SchemaInput{Schema: `{"properties":{"port":{"$ref":"../../port/versions/1"}}}`}); err != nil {
+		t.Fatalf("expected a ref to port to resolve, got %v", err)
+	}
+	// Other tenants do not see them
+	if _, _, err := service.Register("other", "alice", "database", SchemaInput{Schema: `{"$ref":"/schemas/port/versions/1"}`}); !errors.Is(err, configdata.ErrInvalidSchema) {
+		t.Fatalf("expected ErrInvalidSchema for another tenant, got %v", err)
+	}
+}
+
+func TestSchemaService_GetAndLookup(t *testing.T) {
+	service := setupSchemaService(t)
+	service.Register(testClientID, "alice", "database", SchemaInput{Schema: `{}`})
+	service.Register(testClientID, "alice", "database", SchemaInput{Schema: `{"type":"object"}`})
+
+	if s, err := service.Get(testClientID, "database", 0); err != nil || s.Version != 2 {
+		t.Fatalf("expected the latest version, got %+v %v", s, err)
+	}
+	if _, err := service.Get(testClientID, "database", 3); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected ErrSchemaNotFound, got %v", err)
+	}
+	if _, err := service.ListVersions("other", "database"); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected ErrSchemaNotFound for another tenant, got %v", err)
+	}
+
+	if schema, err := service.LookupSchema(testClientID, "database", 1); err != nil || schema != `{}` {
+		t.Fatalf("expected version 1, got %q %v", schema, err)
+	}
+	if _, err := service.LookupSchema("other", "database", 1); !errors.Is(err, configdata.ErrSchemaNotRegistered) {
+		t.Fatalf("expected ErrSchemaNotRegistered, got %v", err)
+	}
+}
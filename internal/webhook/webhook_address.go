@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("webhooks cannot be sent to loopback, private or link-local addresses")
+
+// Ranges the net.IP predicates leave out: "this network" and carrier-grade NAT
+var forbiddenNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// isForbiddenIP tells the addresses of the server's own network, where a
+// webhook must never reach: loopback, private, link-local (cloud metadata
+// endpoints), unspecified and multicast
+func isForbiddenIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range forbiddenNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isForbiddenHost rejects webhook urls whose host is obviously internal.
+// Hostnames are checked again, once resolved, by denyForbiddenAddresses.
+func isForbiddenHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && isForbiddenIP(ip)
+}
+
+// denyForbiddenAddresses is a net.Dialer Control func. It sees the address
+// actually connected to, after DNS resolution and on every redirect, so a
+// hostname that resolves to an internal address later is still refused.
+func denyForbiddenAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isForbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// newDeliveryClient posts deliveries to public addresses only, and never
+// through a proxy that would hide where it connects to
+func newDeliveryClient() *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: denyForbiddenAddresses}
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: requestTimeout,
+			MaxIdleConns:        maxConcurrency,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
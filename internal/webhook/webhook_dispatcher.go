@@ -0,0 +1,252 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	MaxAttempts = 8 // a delivery is dead after this many failed attempts
+
+	baseBackoff    = 30 * time.Second
+	maxBackoff     = time.Hour
+	batchSize      = 100
+	maxConcurrency = 8
+	requestTimeout = 10 * time.Second
+)
+
+// Sign returns the signature header value for body sent at t.
+// Receivers recompute the HMAC-SHA256 of "<t>.<body>" with the webhook
+// secret and compare it with v1, rejecting stale timestamps.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff is the wait after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// Dispatcher turns outbox events into deliveries and posts them
+type Dispatcher struct {
+	repo   WebhookRepo
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewDispatcher uses client to post deliveries, a nil client gets a default
+// with a timeout that refuses internal addresses, see newDeliveryClient
+func NewDispatcher(repo WebhookRepo, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = newDeliveryClient()
+	}
+	return &Dispatcher{repo: repo, client: client, now: time.Now}
+}
+
+// Start runs RunOnce every interval until the returned stop func is called
+func (d *Dispatcher) Start(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := d.RunOnce(); err != nil {
+					log.Println("failed to dispatch webhooks:", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// RunOnce fans out pending events and makes one attempt at every due delivery
+func (d *Dispatcher) RunOnce() error {
+	if err := d.fanOut(); err != nil {
+		return err
+	}
+	return d.deliverDue()
+}
+
+func (d *Dispatcher) fanOut() error {
+	for {
+		pending, err := d.repo.PendingEvents(batchSize)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		seen := map[string]bool{}
+		var clientIDs []string
+		for _, p := range pending {
+			if p.Event.ClientID != "" && !seen[p.Event.ClientID] {
+				seen[p.Event.ClientID] = true
+				clientIDs = append(clientIDs, p.Event.ClientID)
+			}
+		}
+		var hooks []models.Webhook
+		if len(clientIDs) > 0 {
+			if hooks, err = d.repo.ListActive(clientIDs); err != nil {
+				return err
+			}
+		}
+
+		now := d.now()
+		outboxIDs := make([]uint, 0, len(pending))
+		var deliveries []models.WebhookDelivery
+		for _, p := range pending {
+			outboxIDs = append(outboxIDs, p.OutboxID)
+			if p.Event.ID == 0 {
+				continue
+			}
+			payload, err := json.Marshal(p.Event)
+			if err != nil {
+				return err
+			}
+			for _, w := range hooks {
+				if !matches(w, p.Event) {
+					continue
+				}
+				deliveries = append(deliveries, models.WebhookDelivery{
+					WebhookID:     w.ID,
+					ClientID:      w.ClientID,
+					EventID:       p.Event.ID,
+					EventType:     p.Event.Type,
+					Payload:       string(payload),
+					Status:        models.DeliveryPending,
+					NextAttemptAt: now,
+				})
+			}
+		}
+		if err := d.repo.SaveDeliveries(outboxIDs, deliveries); err != nil {
+			return err
+		}
+		if len(pending) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue() error {
+	due, err := d.repo.DueDeliveries(d.now(), batchSize)
+	if err != nil {
+		return err
+	}
+
+	// Deliveries to the same webhook share its lookup
+	hooks := map[uuid.UUID]*models.Webhook{}
+	for _, dl := range due {
+		if _, ok := hooks[dl.WebhookID]; ok {
+			continue
+		}
+		w, err := d.repo.Get(dl.ClientID, dl.WebhookID)
+		if err != nil {
+			return err
+		}
+		hooks[dl.WebhookID] = w
+	}
+
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i := range due {
+		dl := &due[i]
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if err := d.attempt(hooks[dl.WebhookID], dl); err != nil {
+				log.Println("failed to save webhook attempt:", err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// attempt posts the delivery once and records the outcome
+func (d *Dispatcher) attempt(w *models.Webhook, dl *models.WebhookDelivery) error {
+	start := d.now()
+	a := &models.WebhookAttempt{}
+	if w == nil || w.IsActive == 0 {
+		a.Error = "webhook is disabled"
+	} else {
+		a.StatusCode, a.Error = d.post(w, dl, start)
+	}
+	a.DurationMs = d.now().Sub(start).Milliseconds()
+
+	dl.Attempts++
+	dl.LastStatusCode = a.StatusCode
+	dl.LastError = a.Error
+	switch {
+	case a.Error == "":
+		dl.Status = models.DeliveryDelivered
+		dl.DeliveredAt = &start
+	case w == nil || w.IsActive == 0 || dl.Attempts >= MaxAttempts:
+		dl.Status = models.DeliveryDead
+	default:
+		dl.NextAttemptAt = start.Add(backoff(dl.Attempts))
+	}
+	// Not saved when the delivery was deleted or redelivered meanwhile
+	_, err := d.repo.SaveAttempt(dl, a)
+	return err
+}
+
+// post returns the response status and an error message for anything but a 2xx
+func (d *Dispatcher) post(w *models.Webhook, dl *models.WebhookDelivery, now time.Time) (int, string) {
+	body := []byte(dl.Payload)
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "configsvc-webhooks")
+	req.Header.Set(EventHeader, string(dl.EventType))
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(dl.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, now, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, ""
+}
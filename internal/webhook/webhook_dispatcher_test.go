@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sass.com/configsvc/internal/models"
+)
+
+// receiver records what a webhook endpoint is sent and answers with statuses in turn
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.bodies = append(rc.bodies, body)
+	rc.headers = append(rc.headers, r.Header.Clone())
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) calls() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.bodies)
+}
+
+// newTestDispatcher may reach the httptest servers on 127.0.0.1, which the
+// default client refuses
+func newTestDispatcher(repo WebhookRepo, now *time.Time) *Dispatcher {
+	d := NewDispatcher(repo, &http.Client{Timeout: requestTimeout})
+	d.now = func() time.Time { return *now }
+	return d
+}
+
+func TestDispatcher_SignsDelivery(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	w := createTestWebhook(t, repo, testClientID, srv.URL, "*", "")
+	e := recordEvent(t, db, testClientID, "database", models.ConfigCreated)
+
+	now := time.Now()
+	if err := newTestDispatcher(repo, &now).RunOnce(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rc.calls() != 1 {
+		t.Fatalf("expected one call, got %d", rc.calls())
+	}
+
+	var got models.ConfigEvent
+	if err := json.Unmarshal(rc.bodies[0], &got); err != nil || got.ID != e.ID || got.Name != "database" {
+		t.Fatalf("expected the event as payload, got %s, %v", rc.bodies[0], err)
+	}
+	h := rc.headers[0]
+	if h.Get(SignatureHeader) != Sign(w.Secret, now, rc.bodies[0]) {
+		t.Fatalf("signature mismatch: %s", h.Get(SignatureHeader))
+	}
+	if h.Get(EventHeader) != "created" || h.Get(DeliveryHeader) == "" {
+		t.Fatalf("unexpected headers: %v", h)
+	}
+
+	deliveries, _ := repo.ListDeliveries(testClientID, w.ID, models.DeliveryDelivered, 10)
+	if len(deliveries) != 1 || deliveries[0].DeliveredAt == nil {
+		t.Fatalf("expected a delivered delivery, got %+v", deliveries)
+	}
+}
+
+func TestDispatcher_FiltersEvents(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	createTestWebhook(t, repo, testClientID, srv.URL, "payments.*", "updated")
+	recordEvent(t, db, testClientID, "payments.gateway", models.ConfigCreated)
+	recordEvent(t, db, testClientID, "database", models.ConfigUpdated)
+	recordEvent(t, db, "globex", "payments.gateway", models.ConfigUpdated)
+	want := recordEvent(t, db, testClientID, "payments.gateway", models.ConfigUpdated)
+
+	now := time.Now()
+	if err := newTestDispatcher(repo, &now).RunOnce(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rc.calls() != 1 {
+		t.Fatalf("expected one matching event, got %d calls", rc.calls())
+	}
+	var got models.ConfigEvent
+	json.Unmarshal(rc.bodies[0], &got)
+	if got.ID != want.ID {
+		t.Fatalf("expected event %d, got %+v", want.ID, got)
+	}
+	if pending, _ := repo.PendingEvents(10); len(pending) != 0 {
+		t.Fatalf("expected outbox to be drained, got %+v", pending)
+	}
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	w := createTestWebhook(t, repo, testClientID, srv.URL, "*", "")
+	recordEvent(t, db, testClientID, "database", models.ConfigCreated)
+
+	now := time.Now()
+	d := newTestDispatcher(repo, &now)
+	d.RunOnce()
+	// Not due yet: nothing is sent
+	now = now.Add(baseBackoff - time.Second)
+	d.RunOnce()
+	if rc.calls() != 1 {
+		t.Fatalf("expected the retry to wait, got %d calls", rc.calls())
+	}
+
+	now = now.Add(time.Second)
+	d.RunOnce()
+	now = now.Add(2 * baseBackoff)
+	d.RunOnce()
+	if rc.calls() != 3 {
+		t.Fatalf("expected 3 calls, got %d", rc.calls())
+	}
+
+	deliveries, _ := repo.ListDeliveries(testClientID, w.ID, "", 10)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 3 {
+		t.Fatalf("expected delivery to succeed on the third attempt, got %+v", deliveries)
+	}
+	attempts, _ := repo.ListAttempts(deliveries[0].ID)
+	if len(attempts) != 3 || attempts[0].StatusCode != http.StatusInternalServerError || attempts[2].Error != "" {
+		t.Fatalf("expected every attempt to be logged, got %+v", attempts)
+	}
+}
+
+func TestDispatcher_DeadLettersAndRedelivers(t *testing.T) {
+	rc := &receiver{}
+	for i := 0; i < MaxAttempts; i++ {
+		rc.statuses = append(rc.statuses, http.StatusServiceUnavailable)
+	}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	service := NewWebhookService(repo)
+	w := createTestWebhook(t, repo, testClientID, srv.URL, "*", "")
+	recordEvent(t, db, testClientID, "database", models.ConfigCreated)
+
+	now := time.Now()
+	d := newTestDispatcher(repo, &now)
+	for i := 0; i < MaxAttempts+2; i++ {
+		d.RunOnce()
+		now = now.Add(maxBackoff)
+	}
+	if rc.calls() != MaxAttempts {
+		t.Fatalf("expected %d attempts, got %d", MaxAttempts, rc.calls())
+	}
+	dead, _ := service.ListDeliveries(testClientID, w.ID, models.DeliveryDead, 0)
+	if len(dead) != 1 || !strings.Contains(dead[0].LastError, "503") {
+		t.Fatalf("expected a dead delivery, got %+v", dead)
+	}
+
+	if err := service.Redeliver(testClientID, w.ID, dead[0].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now = time.Now().Add(time.Second)
+	d.RunOnce()
+
+	log, err := service.GetDelivery(testClientID, w.ID, dead[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if log.Delivery.Status != models.DeliveryDelivered || len(log.Attempts) != MaxAttempts+1 {
+		t.Fatalf("expected redelivery to succeed, got %+v with %d attempts", log.Delivery, len(log.Attempts))
+	}
+}
+
+func TestDispatcher_RefusesInternalAddresses(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	// Stored directly, as if example.com had resolved to 127.0.0.1
+	w := createTestWebhook(t, repo, testClientID, srv.URL, "*", "")
+	recordEvent(t, db, testClientID, "database", models.ConfigCreated)
+
+	d := NewDispatcher(repo, nil)
+	if err := d.RunOnce(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rc.calls() != 0 {
+		t.Fatalf("expected nothing to be sent to %s, got %d calls", srv.URL, rc.calls())
+	}
+	deliveries, _ := repo.ListDeliveries(testClientID, w.ID, "", 10)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryPending {
+		t.Fatalf("expected the delivery to be retried, got %+v", deliveries)
+	}
+	attempts, _ := repo.ListAttempts(deliveries[0].ID)
+	if len(attempts) != 1 || !strings.Contains(attempts[0].Error, ErrForbiddenAddress.Error()) {
+		t.Fatalf("expected the attempt to be refused, got %+v", attempts)
+	}
+}
+
+func TestIsForbiddenIP(t *testing.T) {
+	forbidden := []string{"127.0.0.1", "::1", "10.0.0.1", "172.16.5.4", "192.168.1.1", "169.254.169.254",
+		"fe80::1", "fd00::1", "0.0.0.0", "::", "0.1.2.3", "100.64.0.1", "224.0.0.1", "::ffff:127.0.0.1"}
+	for _, s := range forbidden {
+		if !isForbiddenIP(net.ParseIP(s)) {
+			t.Errorf("expected %s to be forbidden", s)
+		}
+		if err := denyForbiddenAddresses("tcp", net.JoinHostPort(s, "443"), nil); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("expected dialing %s to be refused, got %v", s, err)
+		}
+	}
+	for _, s := range []string{"93.184.216.34", "8.8.8.8", "2606:4700::1111"} {
+		if isForbiddenIP(net.ParseIP(s)) {
+			t.Errorf("expected %s to be allowed", s)
+		}
+		if err := denyForbiddenAddresses("tcp", net.JoinHostPort(s, "443"), nil); err != nil {
+			t.Errorf("expected dialing %s to be allowed, got %v", s, err)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: baseBackoff, 2: 2 * baseBackoff, 3: 4 * baseBackoff, 20: maxBackoff}
+	for attempts, want := range cases {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d): expected %s, got %s", attempts, want, got)
+		}
+	}
+}
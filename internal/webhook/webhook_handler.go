@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/policy"
+)
+
+type WebhookHandler struct {
+	service WebhookService
+}
+
+func NewWebhookHandler(service WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req WebhookInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	w, err := h.service.CreateWebhook(c.GetString("client_id"), c.GetString("user_id"), req)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, w)
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	hooks, err := h.service.ListWebhooks(c.GetString("client_id"))
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	w, err := h.service.GetWebhook(c.GetString("client_id"), id)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	var req WebhookInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	w, err := h.service.UpdateWebhook(c.GetString("client_id"), id, req)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWebhook(c.GetString("client_id"), id); err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries supports ?status= and ?limit=
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	status := models.DeliveryStatus(c.Query("status"))
+	deliveries, err := h.service.ListDeliveries(c.GetString("client_id"), id, status, limit)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDelivery returns a delivery with its attempts
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	id, deliveryID, ok := deliveryParams(c)
+	if !ok {
+		return
+	}
+
+	dl, err := h.service.GetDelivery(c.GetString("client_id"), id, deliveryID)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dl)
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, deliveryID, ok := deliveryParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Redeliver(c.GetString("client_id"), id, deliveryID); err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func webhookID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func deliveryParams(c *gin.Context) (uuid.UUID, uint, bool) {
+	id, ok := webhookID(c)
+	if !ok {
+		return uuid.Nil, 0, false
+	}
+	deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery id"})
+		return uuid.Nil, 0, false
+	}
+	return id, uint(deliveryID), true
+}
+
+func writeWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrWebhookNotFound), errors.Is(err, ErrDeliveryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrForbiddenAddress), errors.Is(err, ErrInvalidEventType), errors.Is(err, ErrInvalidStatus),
+		errors.Is(err, ErrWeakSecret), errors.Is(err, policy.ErrInvalidPattern):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		fmt.Println("webhook service error:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
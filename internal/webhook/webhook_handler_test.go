@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/models"
+)
+
+func setupWebhookHandlerRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	db := setupWebhookTestDB(t)
+	h := NewWebhookHandler(NewWebhookService(NewWebhookRepo(db)))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("client_id", testClientID)
+		c.Set("user_id", "alice")
+	})
+	r.POST("/webhooks", h.CreateWebhook)
+	r.GET("/webhooks", h.ListWebhooks)
+	r.GET("/webhooks/:id", h.GetWebhook)
+	r.PUT("/webhooks/:id", h.UpdateWebhook)
+	r.DELETE("/webhooks/:id", h.DeleteWebhook)
+	r.GET("/webhooks/:id/deliveries", h.ListDeliveries)
+	r.GET("/webhooks/:id/deliveries/:delivery_id", h.GetDelivery)
+	r.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", h.Redeliver)
+	return r, db
+}
+
+func TestWebhookHandler_CreateWebhook(t *testing.T) {
+	r, _ := setupWebhookHandlerRouter(t)
+
+	body := bytes.NewBufferString(`{"url":"https://example.com/hook","pattern":"payments.*","event_types":["updated"],"secret":"0123456789abcdef"}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", w.Code, w.Body.String())
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("0123456789abcdef")) {
+		t.Fatalf("expected secret to be hidden, got %s", w.Body.String())
+	}
+	var hook models.Webhook
+	json.Unmarshal(w.Body.Bytes(), &hook)
+
+	req = httptest.NewRequest(http.MethodGet, "/webhooks/"+hook.ID.String(), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestWebhookHandler_CreateWebhook_Invalid(t *testing.T) {
+	r, _ := setupWebhookHandlerRouter(t)
+
+	body := bytes.NewBufferString(`{"url":"https://example.com/hook","secret":"short"}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestWebhookHandler_Deliveries(t *testing.T) {
+	r, db := setupWebhookHandlerRouter(t)
+	repo := NewWebhookRepo(db)
+	hook := createTestWebhook(t, repo, testClientID, "https://example.com/hook", "*", "")
+	deliveries := []models.WebhookDelivery{{WebhookID: hook.ID, ClientID: testClientID, Status: models.DeliveryDead}}
+	if err := repo.SaveDeliveries(nil, deliveries); err != nil {
+		t.Fatalf("failed to save delivery: %v", err)
+	}
+	base := "/webhooks/" + hook.ID.String() + "/deliveries"
+	id := strconv.FormatUint(uint64(deliveries[0].ID), 10)
+
+	cases := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, base + "?status=dead", http.StatusOK},
+		{http.MethodGet, base + "?status=lost", http.StatusBadRequest},
+		{http.MethodGet, base + "?limit=0", http.StatusBadRequest},
+		{http.MethodGet, base + "/" + id, http.StatusOK},
+		{http.MethodGet, base + "/999", http.StatusNotFound},
+		{http.MethodGet, base + "/abc", http.StatusBadRequest},
+		{http.MethodGet, "/webhooks/" + uuid.New().String() + "/deliveries", http.StatusNotFound},
+		{http.MethodPost, base + "/" + id + "/redeliver", http.StatusAccepted},
+		{http.MethodPost, base + "/999/redeliver", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s %s: expected %d, got %d %s", tc.method, tc.path, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestWebhookHandler_DeleteWebhook(t *testing.T) {
+	r, db := setupWebhookHandlerRouter(t)
+	hook := createTestWebhook(t, NewWebhookRepo(db), testClientID, "https://example.com/hook", "*", "")
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodDelete, "/webhooks/"+hook.ID.String(), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("expected %d, got %d", want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/models"
+)
+
+// PendingEvent is an outbox entry with the event it announces
+type PendingEvent struct {
+	OutboxID uint
+	Event    models.ConfigEvent
+}
+
+// Webhooks and their deliveries are scoped by clientID (the tenant)
+type WebhookRepo interface {
+	Create(w *models.Webhook) error
+	Update(w *models.Webhook) error
+	Delete(clientID string, id uuid.UUID) (bool, error)
+	Get(clientID string, id uuid.UUID) (*models.Webhook, error)
+	List(clientID string) ([]models.Webhook, error)
+	ListActive(clientIDs []string) ([]models.Webhook, error)
+
+	PendingEvents(limit int) ([]PendingEvent, error)
+	SaveDeliveries(outboxIDs []uint, deliveries []models.WebhookDelivery) error
+	DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	SaveAttempt(d *models.WebhookDelivery, a *models.WebhookAttempt) (bool, error)
+	ListDeliveries(clientID string, webhookID uuid.UUID, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(clientID string, webhookID uuid.UUID, id uint) (*models.WebhookDelivery, error)
+	ListAttempts(deliveryID uint) ([]models.WebhookAttempt, error)
+	Redeliver(clientID string, webhookID uuid.UUID, id uint, now time.Time) (bool, error)
+}
+
+func NewWebhookRepo(db *gorm.DB) WebhookRepo {
+	return &WebhookRepoImpl{db: db}
+}
+
+type WebhookRepoImpl struct {
+	db *gorm.DB
+}
+
+func (r *WebhookRepoImpl) Create(w *models.Webhook) error {
+	return r.db.Create(w).Error
+}
+
+func (r *WebhookRepoImpl) Update(w *models.Webhook) error {
+	return r.db.Save(w).Error
+}
+
+// Delete removes the webhook with its deliveries and their logs
+func (r *WebhookRepoImpl) Delete(clientID string, id uuid.UUID) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("client_id = ? AND id = ?", clientID, id).Delete(&models.Webhook{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		deleted = true
+
+		deliveries := tx.Model(&models.WebhookDelivery{}).Select("id").Where("webhook_id = ?", id)
+		if err := tx.Where("delivery_id IN (?)", deliveries).Delete(&models.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error
+	})
+	return deleted, err
+}
+
+// Get returns nil, nil when the tenant has no such webhook
+func (r *WebhookRepoImpl) Get(clientID string, id uuid.UUID) (*models.Webhook, error) {
+	var w models.Webhook
+	if err := r.db.Where("client_id = ? AND id = ?", clientID, id).First(&w).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *WebhookRepoImpl) List(clientID string) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	if err := r.db.Where("client_id = ?", clientID).
+		Order("created_at ASC").
+		Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// ListActive returns the active webhooks of the given tenants
+func (r *WebhookRepoImpl) ListActive(clientIDs []string) ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	if len(clientIDs) == 0 {
+		return hooks, nil
+	}
+	if err := r.db.Where("client_id IN ? AND is_active = ?", clientIDs, 1).
+		Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// PendingEvents returns the oldest outbox entries with their events
+func (r *WebhookRepoImpl) PendingEvents(limit int) ([]PendingEvent, error) {
+	var outbox []models.OutboxEvent
+	if err := r.db.Order("id ASC").Limit(limit).Find(&outbox).Error; err != nil {
+		return nil, err
+	}
+	if len(outbox) == 0 {
+		return nil, nil
+	}
+
+	eventIDs := make([]uint, 0, len(outbox))
+	for _, o := range outbox {
+		eventIDs = append(eventIDs, o.EventID)
+	}
+	var events []models.ConfigEvent
+	if err := r.db.Where("id IN ?", eventIDs).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.ConfigEvent, len(events))
+	for _, e := range events {
+		byID[e.ID] = e
+	}
+
+	pending := make([]PendingEvent, 0, len(outbox))
+	for _, o := range outbox {
+		// Entries without an event have nothing to announce, they are dropped with the rest
+		pending = append(pending, PendingEvent{OutboxID: o.ID, Event: byID[o.EventID]})
+	}
+	return pending, nil
+}
+
+// SaveDeliveries stores the deliveries made from outbox entries and removes
+// those entries in one transaction, so each event is fanned out exactly once
+func (r *WebhookRepoImpl) SaveDeliveries(outboxIDs []uint, deliveries []models.WebhookDelivery) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(deliveries) > 0 {
+			if err := tx.Create(&deliveries).Error; err != nil {
+				return err
+			}
+		}
+		if len(outboxIDs) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", outboxIDs).Delete(&models.OutboxEvent{}).Error
+	})
+}
+
+// DueDeliveries returns pending deliveries whose next attempt is due, oldest first
+func (r *WebhookRepoImpl) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.db.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at ASC").
+		Order("id ASC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// SaveAttempt stores the outcome of an attempt on the delivery and logs it.
+// d is the delivery as read by DueDeliveries, with the outcome applied: it is
+// only saved if the delivery is still pending with one attempt less, so
+// neither a deleted delivery comes back nor a Redeliver meanwhile is undone.
+// It returns false, recording nothing, otherwise.
+func (r *WebhookRepoImpl) SaveAttempt(d *models.WebhookDelivery, a *models.WebhookAttempt) (bool, error) {
+	saved := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND attempts = ?", d.ID, models.DeliveryPending, d.Attempts-1).
+			Updates(map[string]interface{}{
+				"status":           d.Status,
+				"attempts":         d.Attempts,
+				"next_attempt_at":  d.NextAttemptAt,
+				"last_status_code": d.LastStatusCode,
+				"last_error":       d.LastError,
+				"delivered_at":     d.DeliveredAt,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		saved = true
+		a.DeliveryID = d.ID
+		return tx.Create(a).Error
+	})
+	return saved, err
+}
+
+// ListDeliveries returns the newest deliveries of a webhook, optionally only those with status
+func (r *WebhookRepoImpl) ListDeliveries(clientID string, webhookID uuid.UUID, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	tx := r.db.Where("client_id = ? AND webhook_id = ?", clientID, webhookID)
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := tx.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetDelivery returns nil, nil when the webhook has no such delivery
+func (r *WebhookRepoImpl) GetDelivery(clientID string, webhookID uuid.UUID, id uint) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	if err := r.db.Where("client_id = ? AND webhook_id = ? AND id = ?", clientID, webhookID, id).
+		First(&d).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *WebhookRepoImpl) ListAttempts(deliveryID uint) ([]models.WebhookAttempt, error) {
+	var attempts []models.WebhookAttempt
+	if err := r.db.Where("delivery_id = ?", deliveryID).
+		Order("id ASC").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// Redeliver queues a delivery again with a fresh retry budget, whatever its status
+func (r *WebhookRepoImpl) Redeliver(clientID string, webhookID uuid.UUID, id uint, now time.Time) (bool, error) {
+	res := r.db.Model(&models.WebhookDelivery{}).
+		Where("client_id = ? AND webhook_id = ? AND id = ?", clientID, webhookID, id).
+		Updates(map[string]interface{}{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
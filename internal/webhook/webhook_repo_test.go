@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/models"
+)
+
+const testClientID = "acme"
+
+func setupWebhookTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite in-memory: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ConfigEvent{}, &models.OutboxEvent{}, &models.Webhook{},
+		&models.WebhookDelivery{}, &models.WebhookAttempt{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	return db
+}
+
+func createTestWebhook(t *testing.T, repo WebhookRepo, clientID, url, pattern, eventTypes string) *models.Webhook {
+	w := &models.Webhook{ID: uuid.New(), ClientID: clientID, URL: url, Pattern: pattern,
+		EventTypes: eventTypes, Secret: "0123456789abcdef", IsActive: 1}
+	if err := repo.Create(w); err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+	return w
+}
+
+// recordEvent stores an event with its outbox entry, as the config repo does
+func recordEvent(t *testing.T, db *gorm.DB, clientID, name string, typ models.ConfigEventType) models.ConfigEvent {
+	e := models.ConfigEvent{ClientID: clientID, Name: name, Type: typ, Version: 1, Actor: "bob"}
+	if err := db.Create(&e).Error; err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+	if err := db.Create(&models.OutboxEvent{EventID: e.ID}).Error; err != nil {
+		t.Fatalf("failed to insert outbox entry: %v", err)
+	}
+	return e
+}
+
+func TestWebhookRepo_SaveDeliveries_ClearsOutbox(t *testing.T) {
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	w := createTestWebhook(t, repo, testClientID, "http://example.com", "*", "")
+	e := recordEvent(t, db, testClientID, "database", models.ConfigCreated)
+
+	pending, err := repo.PendingEvents(10)
+	if err != nil || len(pending) != 1 || pending[0].Event.ID != e.ID {
+		t.Fatalf("expected the event to be pending, got %+v, %v", pending, err)
+	}
+
+	d := models.WebhookDelivery{WebhookID: w.ID, ClientID: testClientID, EventID: e.ID, Status: models.DeliveryPending}
+	if err := repo.SaveDeliveries([]uint{pending[0].OutboxID}, []models.WebhookDelivery{d}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pending, _ = repo.PendingEvents(10)
+	if len(pending) != 0 {
+		t.Fatalf("expected outbox to be empty, got %+v", pending)
+	}
+	deliveries, _ := repo.ListDeliveries(testClientID, w.ID, "", 10)
+	if len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %+v", deliveries)
+	}
+}
+
+func TestWebhookRepo_Delete_RemovesDeliveries(t *testing.T) {
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	w := createTestWebhook(t, repo, testClientID, "http://example.com", "*", "")
+	d := models.WebhookDelivery{WebhookID: w.ID, ClientID: testClientID, Status: models.DeliveryPending}
+	if err := repo.SaveDeliveries(nil, []models.WebhookDelivery{d}); err != nil {
+		t.Fatalf("failed to save delivery: %v", err)
+	}
+
+	// Another tenant cannot delete it
+	if deleted, err := repo.Delete("globex", w.ID); err != nil || deleted {
+		t.Fatalf("expected no delete across tenants, got %v, %v", deleted, err)
+	}
+	if deleted, err := repo.Delete(testClientID, w.ID); err != nil || !deleted {
+		t.Fatalf("expected webhook to be deleted, got %v, %v", deleted, err)
+	}
+
+	var count int64
+	db.Model(&models.WebhookDelivery{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected deliveries to be removed, got %d", count)
+	}
+}
+
+func TestWebhookRepo_Redeliver(t *testing.T) {
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	w := createTestWebhook(t, repo, testClientID, "http://example.com", "*", "")
+	deliveries := []models.WebhookDelivery{{WebhookID: w.ID, ClientID: testClientID, Status: models.DeliveryDead, Attempts: MaxAttempts}}
+	if err := repo.SaveDeliveries(nil, deliveries); err != nil {
+		t.Fatalf("failed to save delivery: %v", err)
+	}
+	id := deliveries[0].ID
+
+	now := time.Now()
+	if queued, err := repo.Redeliver("globex", w.ID, id, now); err != nil || queued {
+		t.Fatalf("expected no redelivery across tenants, got %v, %v", queued, err)
+	}
+	if queued, err := repo.Redeliver(testClientID, w.ID, id, now); err != nil || !queued {
+		t.Fatalf("expected delivery to be queued, got %v, %v", queued, err)
+	}
+
+	due, err := repo.DueDeliveries(now, 10)
+	if err != nil || len(due) != 1 || due[0].Attempts != 0 {
+		t.Fatalf("expected a fresh due delivery, got %+v, %v", due, err)
+	}
+}
+
+func TestWebhookRepo_SaveAttempt_Stale(t *testing.T) {
+	db := setupWebhookTestDB(t)
+	repo := NewWebhookRepo(db)
+	w := createTestWebhook(t, repo, testClientID, "http://example.com", "*", "")
+	now := time.Now()
+	deliveries := []models.WebhookDelivery{
+		{WebhookID: w.ID, ClientID: testClientID, Status: models.DeliveryPending, Attempts: 2, NextAttemptAt: now},
+		{WebhookID: w.ID, ClientID: testClientID, Status: models.DeliveryPending, Attempts: 0, NextAttemptAt: now},
+	}
+	if err := repo.SaveDeliveries(nil, deliveries); err != nil {
+		t.Fatalf("failed to save deliveries: %v", err)
+	}
+	due, _ := repo.DueDeliveries(now, 10)
+	redelivered, deleted := due[0], due[1]
+
+	// Redelivered while the attempt was made
+	if _, err := repo.Redeliver(testClientID, w.ID, redelivered.ID, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	redelivered.Attempts++
+	redelivered.Status = models.DeliveryDead
+	if saved, err := repo.SaveAttempt(&redelivered, &models.WebhookAttempt{Error: "boom"}); err != nil || saved {
+		t.Fatalf("expected the stale attempt to be skipped, got %v, %v", saved, err)
+	}
+	got, _ := repo.GetDelivery(testClientID, w.ID, redelivered.ID)
+	if got.Status != models.DeliveryPending || got.Attempts != 0 {
+		t.Fatalf("expected the redelivery to be kept, got %+v", got)
+	}
+
+	// Deleted with its webhook while the attempt was made
+	if _, err := repo.Delete(testClientID, w.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deleted.Attempts++
+	deleted.Status = models.DeliveryDelivered
+	if saved, err := repo.SaveAttempt(&deleted, &models.WebhookAttempt{}); err != nil || saved {
+		t.Fatalf("expected the attempt to be skipped, got %v, %v", saved, err)
+	}
+	var count int64
+	db.Model(&models.WebhookDelivery{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no delivery to come back, got %d", count)
+	}
+	db.Model(&models.WebhookAttempt{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no attempt to be logged, got %d", count)
+	}
+}
@@ -0,0 +1,228 @@
+package webhook
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/policy"
+)
+
+const (
+	minSecretLength      = 16
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	ErrInvalidURL       = errors.New("url must be an absolute http or https url")
+	ErrInvalidEventType = errors.New("invalid event type")
+	ErrInvalidStatus    = errors.New("invalid delivery status")
+	ErrWeakSecret       = errors.New("secret must be at least 16 characters")
+)
+
+// Event types a webhook can subscribe to
+var eventTypes = map[models.ConfigEventType]bool{
+	models.ConfigCreated:    true,
+	models.ConfigUpdated:    true,
+	models.ConfigRolledBack: true,
+	models.ConfigDeleted:    true,
+	models.ConfigRestored:   true,
+	models.ConfigPurged:     true,
+}
+
+// WebhookInput holds the fields a client sets on a webhook
+type WebhookInput struct {
+	URL        string                   `json:"url"`
+	Pattern    string                   `json:"pattern"`     // defaults to "*"
+	EventTypes []models.ConfigEventType `json:"event_types"` // empty for every type
+	Secret     string                   `json:"secret"`      // kept as is when empty on update
+	Active     *bool                    `json:"active"`      // defaults to true
+}
+
+// DeliveryLog is a delivery with every attempt made so far
+type DeliveryLog struct {
+	Delivery models.WebhookDelivery  `json:"delivery"`
+	Attempts []models.WebhookAttempt `json:"attempts"`
+}
+
+type WebhookService interface {
+	CreateWebhook(clientID, actor string, in WebhookInput) (*models.Webhook, error)
+	UpdateWebhook(clientID string, id uuid.UUID, in WebhookInput) (*models.Webhook, error)
+	DeleteWebhook(clientID string, id uuid.UUID) error
+	GetWebhook(clientID string, id uuid.UUID) (*models.Webhook, error)
+	ListWebhooks(clientID string) ([]models.Webhook, error)
+	ListDeliveries(clientID string, webhookID uuid.UUID, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(clientID string, webhookID uuid.UUID, id uint) (*DeliveryLog, error)
+	Redeliver(clientID string, webhookID uuid.UUID, id uint) error
+}
+
+func NewWebhookService(repo WebhookRepo) WebhookService {
+	return &WebhookServiceImpl{repo: repo}
+}
+
+type WebhookServiceImpl struct {
+	repo WebhookRepo
+}
+
+func (s *WebhookServiceImpl) CreateWebhook(clientID, actor string, in WebhookInput) (*models.Webhook, error) {
+	if len(in.Secret) < minSecretLength {
+		return nil, ErrWeakSecret
+	}
+	w := &models.Webhook{ID: uuid.New(), ClientID: clientID, Secret: in.Secret, CreatedBy: actor}
+	if err := apply(w, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// UpdateWebhook replaces the settings of a webhook, an empty secret keeps the current one
+func (s *WebhookServiceImpl) UpdateWebhook(clientID string, id uuid.UUID, in WebhookInput) (*models.Webhook, error) {
+	w, err := s.GetWebhook(clientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if in.Secret != "" {
+		if len(in.Secret) < minSecretLength {
+			return nil, ErrWeakSecret
+		}
+		w.Secret = in.Secret
+	}
+	if err := apply(w, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// apply validates in and copies it onto w
+func apply(w *models.Webhook, in WebhookInput) error {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if isForbiddenHost(u.Hostname()) {
+		return ErrForbiddenAddress
+	}
+	pattern := in.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	if !policy.IsValidPattern(pattern) {
+		return policy.ErrInvalidPattern
+	}
+	types := make([]string, 0, len(in.EventTypes))
+	for _, t := range in.EventTypes {
+		if !eventTypes[t] {
+			return ErrInvalidEventType
+		}
+		types = append(types, string(t))
+	}
+
+	w.URL = u.String()
+	w.Pattern = pattern
+	w.EventTypes = strings.Join(types, ",")
+	w.IsActive = 1
+	if in.Active != nil && !*in.Active {
+		w.IsActive = 0
+	}
+	return nil
+}
+
+func (s *WebhookServiceImpl) DeleteWebhook(clientID string, id uuid.UUID) error {
+	deleted, err := s.repo.Delete(clientID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (s *WebhookServiceImpl) GetWebhook(clientID string, id uuid.UUID) (*models.Webhook, error) {
+	w, err := s.repo.Get(clientID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return w, nil
+}
+
+func (s *WebhookServiceImpl) ListWebhooks(clientID string) ([]models.Webhook, error) {
+	return s.repo.List(clientID)
+}
+
+// ListDeliveries returns the newest deliveries of a webhook first
+func (s *WebhookServiceImpl) ListDeliveries(clientID string, webhookID uuid.UUID, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	if limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+	if _, err := s.GetWebhook(clientID, webhookID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(clientID, webhookID, status, limit)
+}
+
+func (s *WebhookServiceImpl) GetDelivery(clientID string, webhookID uuid.UUID, id uint) (*DeliveryLog, error) {
+	d, err := s.repo.GetDelivery(clientID, webhookID, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrDeliveryNotFound
+	}
+	attempts, err := s.repo.ListAttempts(d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryLog{Delivery: *d, Attempts: attempts}, nil
+}
+
+// Redeliver sends a delivery again, also one that was delivered or gave up
+func (s *WebhookServiceImpl) Redeliver(clientID string, webhookID uuid.UUID, id uint) error {
+	queued, err := s.repo.Redeliver(clientID, webhookID, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !queued {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// matches reports whether the webhook wants the event
+func matches(w models.Webhook, e models.ConfigEvent) bool {
+	if w.ClientID != e.ClientID || !policy.MatchPattern(w.Pattern, e.Name) {
+		return false
+	}
+	if w.EventTypes == "" {
+		return true
+	}
+	for _, t := range strings.Split(w.EventTypes, ",") {
+		if models.ConfigEventType(t) == e.Type {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/policy"
+)
+
+func TestWebhookService_CreateWebhook_Validation(t *testing.T) {
+	service := NewWebhookService(NewWebhookRepo(setupWebhookTestDB(t)))
+	valid := WebhookInput{URL: "https://example.com/hook", Secret: "0123456789abcdef"}
+
+	cases := []struct {
+		desc   string
+		modify func(in *WebhookInput)
+		want   error
+	}{
+		{"relative url", func(in *WebhookInput) { in.URL = "/hook" }, ErrInvalidURL},
+		{"unsupported scheme", func(in *WebhookInput) { in.URL = "ftp://example.com" }, ErrInvalidURL},
+		{"loopback", func(in *WebhookInput) { in.URL = "http://127.0.0.1:8080/hook" }, ErrForbiddenAddress},
+		{"loopback ipv6", func(in *WebhookInput) { in.URL = "http://[::1]/hook" }, ErrForbiddenAddress},
+		{"localhost", func(in *WebhookInput) { in.URL = "http://LocalHost./hook" }, ErrForbiddenAddress},
+		{"metadata endpoint", func(in *WebhookInput) { in.URL = "http://169.254.169.254/latest/meta-data" }, ErrForbiddenAddress},
+		{"private", func(in *WebhookInput) { in.URL = "https://10.1.2.3/hook" }, ErrForbiddenAddress},
+		{"short secret", func(in *WebhookInput) { in.Secret = "short" }, ErrWeakSecret},
+		{"unknown event type", func(in *WebhookInput) { in.EventTypes = []models.ConfigEventType{"renamed"} }, ErrInvalidEventType},
+		{"invalid pattern", func(in *WebhookInput) { in.Pattern = "pay*ments" }, policy.ErrInvalidPattern},
+	}
+	for _, tc := range cases {
+		in := valid
+		tc.modify(&in)
+		if _, err := service.CreateWebhook(testClientID, "alice", in); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.want, err)
+		}
+	}
+
+	w, err := service.CreateWebhook(testClientID, "alice", valid)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Pattern != "*" || w.EventTypes != "" || w.IsActive != 1 || w.CreatedBy != "alice" {
+		t.Fatalf("expected defaults to be applied, got %+v", w)
+	}
+}
+
+func TestWebhookService_UpdateWebhook_KeepsSecret(t *testing.T) {
+	service := NewWebhookService(NewWebhookRepo(setupWebhookTestDB(t)))
+	w, err := service.CreateWebhook(testClientID, "alice", WebhookInput{URL: "https://example.com/hook", Secret: "0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	inactive := false
+	updated, err := service.UpdateWebhook(testClientID, w.ID, WebhookInput{
+		URL:        "https://example.com/other",
+		Pattern:    "payments.*",
+		EventTypes: []models.ConfigEventType{models.ConfigUpdated, models.ConfigDeleted},
+		Active:     &inactive,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Secret != "0123456789abcdef" || updated.EventTypes != "updated,deleted" || updated.IsActive != 0 {
+		t.Fatalf("unexpected webhook: %+v", updated)
+	}
+}
+
+func TestWebhookService_TenantScoping(t *testing.T) {
+	service := NewWebhookService(NewWebhookRepo(setupWebhookTestDB(t)))
+	w, err := service.CreateWebhook(testClientID, "alice", WebhookInput{URL: "https://example.com/hook", Secret: "0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	if _, err := service.GetWebhook("globex", w.ID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("expected ErrWebhookNotFound, got %v", err)
+	}
+	if _, err := service.ListDeliveries("globex", w.ID, "", 0); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("expected ErrWebhookNotFound, got %v", err)
+	}
+	if err := service.DeleteWebhook("globex", w.ID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Fatalf("expected ErrWebhookNotFound, got %v", err)
+	}
+	if hooks, _ := service.ListWebhooks("globex"); len(hooks) != 0 {
+		t.Fatalf("expected no webhooks for other tenant, got %+v", hooks)
+	}
+	if _, err := service.GetDelivery(testClientID, uuid.New(), 1); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Fatalf("expected ErrDeliveryNotFound, got %v", err)
+	}
+}
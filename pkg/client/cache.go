@@ -0,0 +1,104 @@
+package client
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// configCache keeps the last known good version of every config read, in memory
+// and, with a directory, on disk so it survives restarts
+type configCache struct {
+	dir string
+
+	mu      sync.RWMutex
+	configs map[string]Config
+}
+
+func newCache(dir string) *configCache {
+	return &configCache{dir: dir, configs: map[string]Config{}}
+}
+
+// get returns a copy of the cached config, loading it from disk on first use
+func (c *configCache) get(name string) (*Config, bool) {
+	c.mu.RLock()
+	cfg, ok := c.configs[name]
+	c.mu.RUnlock()
+	if ok {
+		return &cfg, true
+	}
+	if c.dir == "" {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(name))
+	if err != nil {
+		return nil, false
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Println("config client: ignoring unreadable cache file:", err)
+		return nil, false
+	}
+
+	c.mu.Lock()
+	// A fresher copy may have been stored meanwhile
+	if newer, ok := c.configs[name]; ok && newer.Version >= cfg.Version {
+		cfg = newer
+	} else {
+		c.configs[name] = cfg
+	}
+	c.mu.Unlock()
+	return &cfg, true
+}
+
+// put stores cfg unless a newer version is cached already
+func (c *configCache) put(cfg *Config) {
+	c.mu.Lock()
+	if cur, ok := c.configs[cfg.Name]; ok && cur.Version > cfg.Version {
+		c.mu.Unlock()
+		return
+	}
+	stored := *cfg
+	stored.Stale = false
+	c.configs[cfg.Name] = stored
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return
+	}
+	if err := c.write(&stored); err != nil {
+		log.Println("config client: failed to write cache file:", err)
+	}
+}
+
+// write replaces the cache file in one rename so readers never see half a file
+func (c *configCache) write(cfg *Config) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, ".config-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(cfg.Name))
+}
+
+func (c *configCache) path(name string) string {
+	return filepath.Join(c.dir, url.PathEscape(name)+".json")
+}
@@ -0,0 +1,355 @@
+// Package client is the Go SDK of the config service REST API.
+//
+//	c, err := client.New(client.Options{BaseURL: "http://configsvc:8089", Username: "svc", Password: "..."})
+//	var flags FeatureFlags
+//	cfg, err := c.Load(ctx, "feature_flags", &flags)
+//
+// Configs read successfully are kept as last known good copies, in memory and
+// optionally on disk, and served when the service cannot be reached.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchTimeout  = 30 * time.Second
+	defaultRetryInterval = 5 * time.Second
+)
+
+var (
+	ErrNotFound        = errors.New("config not found")
+	ErrUnauthorized    = errors.New("not authenticated")
+	ErrVersionConflict = errors.New("config version has changed")
+)
+
+// APIError is a response of the service other than the ones with sentinel errors
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("config service: %d %s", e.StatusCode, e.Message)
+}
+
+type Options struct {
+	// BaseURL of the service, like http://localhost:8089
+	BaseURL string
+	// Username and Password log in. Without them Token is used, and renewed
+	// with RefreshToken once rejected.
+	Username     string
+	Password     string
+	Token        string
+	RefreshToken string
+	// OnTokens is called with every new token pair, refresh tokens are single use
+	OnTokens func(accessToken, refreshToken string)
+	// CacheDir keeps last known good configs across restarts, empty keeps them in memory only
+	CacheDir   string
+	HTTPClient *http.Client
+	// WatchTimeout is how long a single long poll waits, at most 2m
+	WatchTimeout time.Duration
+	// RetryInterval is the pause of watchers after a failed request
+	RetryInterval time.Duration
+}
+
+// Config is a config version as returned by the service
+type Config struct {
+	ID       string
+	ClientID string
+	Name     string
+	Type     string
+	Schema   string
+	Input    string
+	Version  int
+	// SchemaVersion goes up with every change of the schema
+	SchemaVersion int
+	CreatedBy     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	ETag          string
+
+	// Stale is set on last known good copies served while the service is unreachable
+	Stale bool `json:"-"`
+}
+
+// Decode unmarshals the input of the config into v
+func (c *Config) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(c.Input), v)
+}
+
+type Client struct {
+	baseURL string
+	opts    Options
+	http    *http.Client
+	cache   *configCache
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+}
+
+func New(opts Options) (*Client, error) {
+	u, err := url.Parse(opts.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q", opts.BaseURL)
+	}
+	if opts.Username == "" && opts.Token == "" && opts.RefreshToken == "" {
+		return nil, errors.New("either username and password or a token is required")
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.WatchTimeout <= 0 {
+		opts.WatchTimeout = defaultWatchTimeout
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultRetryInterval
+	}
+
+	return &Client{
+		baseURL:      strings.TrimRight(opts.BaseURL, "/") + "/api/v1",
+		opts:         opts,
+		http:         opts.HTTPClient,
+		cache:        newCache(opts.CacheDir),
+		accessToken:  opts.Token,
+		refreshToken: opts.RefreshToken,
+	}, nil
+}
+
+// GetLatest returns the latest version of a config. When the service cannot
+// be reached or fails, the last known good copy is returned with Stale set.
+func (c *Client) GetLatest(ctx context.Context, name string) (*Config, error) {
+	cached, hasCached := c.cache.get(name)
+
+	header := http.Header{}
+	if hasCached && cached.ETag != "" {
+		header.Set("If-None-Match", cached.ETag)
+	}
+	cfg, status, err := c.getConfig(ctx, "/configs/"+url.PathEscape(name)+"/latest", header)
+	switch {
+	case err == nil && status == http.StatusNotModified && hasCached:
+		cached.Stale = false
+		return cached, nil
+	case err == nil:
+		c.cache.put(cfg)
+		return cfg, nil
+	case hasCached && unavailable(err):
+		cached.Stale = true
+		return cached, nil
+	default:
+		return nil, err
+	}
+}
+
+// GetVersion returns a single version of a config
+func (c *Client) GetVersion(ctx context.Context, name string, version int) (*Config, error) {
+	cfg, _, err := c.getConfig(ctx, "/configs/"+url.PathEscape(name)+"/versions/"+strconv.Itoa(version), nil)
+	return cfg, err
+}
+
+// Load is GetLatest followed by decoding the input into v
+func (c *Client) Load(ctx context.Context, name string, v interface{}) (*Config, error) {
+	cfg, err := c.GetLatest(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Decode(v); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", name, err)
+	}
+	return cfg, nil
+}
+
+// Watch waits for a version newer than afterVersion, up to WatchTimeout. It
+// returns nil, nil when there was none.
+func (c *Client) Watch(ctx context.Context, name string, afterVersion int) (*Config, error) {
+	q := url.Values{}
+	q.Set("after_version", strconv.Itoa(afterVersion))
+	q.Set("timeout", c.opts.WatchTimeout.String())
+	cfg, status, err := c.getConfig(ctx, "/configs/"+url.PathEscape(name)+"/watch?"+q.Encode(), nil)
+	if err != nil || status == http.StatusNotModified {
+		return nil, err
+	}
+	c.cache.put(cfg)
+	return cfg, nil
+}
+
+// getConfig reads a config, a 304 is returned as a nil config with its status
+func (c *Client) getConfig(ctx context.Context, path string, header http.Header) (*Config, int, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, header, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, resp.StatusCode, nil
+	default:
+		return nil, resp.StatusCode, responseError(resp)
+	}
+
+	var cfg Config
+	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("decode response: %w", err)
+	}
+	cfg.ETag = resp.Header.Get("ETag")
+	return &cfg, resp.StatusCode, nil
+}
+
+// do sends an authenticated request, renewing the tokens once on a 401
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	token, err := c.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, method, path, header, body, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if token, err = c.renew(ctx, token); err != nil {
+		return nil, err
+	}
+	return c.send(ctx, method, path, header, body, token)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, body []byte, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.http.Do(req)
+}
+
+// Login logs in with the username and password now instead of on first use
+func (c *Client) Login(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.login(ctx)
+}
+
+// token returns the access token, logging in first when there is none
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" {
+		return c.accessToken, nil
+	}
+	if c.refreshToken != "" && c.refresh(ctx) == nil {
+		return c.accessToken, nil
+	}
+	if err := c.login(ctx); err != nil {
+		return "", err
+	}
+	return c.accessToken, nil
+}
+
+// renew replaces a rejected access token, by refreshing it or else logging in
+// again. Concurrent callers that saw the same rejected token renew it once.
+func (c *Client) renew(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != rejected {
+		return c.accessToken, nil
+	}
+	if c.refreshToken != "" && c.refresh(ctx) == nil {
+		return c.accessToken, nil
+	}
+	if err := c.login(ctx); err != nil {
+		return "", err
+	}
+	return c.accessToken, nil
+}
+
+// login must be called with mu held
+func (c *Client) login(ctx context.Context) error {
+	if c.opts.Username == "" {
+		return ErrUnauthorized
+	}
+	return c.authenticate(ctx, "/login", map[string]string{"username": c.opts.Username, "password": c.opts.Password})
+}
+
+// refresh must be called with mu held
+func (c *Client) refresh(ctx context.Context) error {
+	return c.authenticate(ctx, "/token/refresh", map[string]string{"refresh_token": c.refreshToken})
+}
+
+// authenticate posts credentials and stores the returned token pair, mu must be held
+func (c *Client) authenticate(ctx context.Context, path string, credentials map[string]string) error {
+	body, _ := json.Marshal(credentials)
+	resp, err := c.send(ctx, http.MethodPost, path, nil, body, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return fmt.Errorf("decode tokens: %w", err)
+	}
+	c.accessToken, c.refreshToken = tokens.AccessToken, tokens.RefreshToken
+	if c.opts.OnTokens != nil {
+		c.opts.OnTokens(c.accessToken, c.refreshToken)
+	}
+	return nil
+}
+
+// responseError turns an unsuccessful response into a sentinel error or an *APIError
+func responseError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusPreconditionFailed:
+		return ErrVersionConflict
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	var msg struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &msg) != nil || msg.Error == "" {
+		msg.Error = strings.TrimSpace(string(body))
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: msg.Error}
+}
+
+// unavailable reports whether err means the service could not answer, as
+// opposed to a definite answer like not found or forbidden
+func unavailable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnauthorized)
+}
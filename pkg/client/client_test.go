@@ -0,0 +1,241 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"sass.com/configsvc/internal/auth"
+	"sass.com/configsvc/internal/cache"
+	"sass.com/configsvc/internal/config"
+	configdata "sass.com/configsvc/internal/config_data"
+	"sass.com/configsvc/internal/models"
+	"sass.com/configsvc/internal/notify"
+	"sass.com/configsvc/internal/policy"
+	"sass.com/configsvc/internal/secrets"
+)
+
+const (
+	testClientID = "acme"
+	testSchema   = `{"type":"object","properties":{"enabled":{"type":"boolean"},"limit":{"type":"integer"}},"required":["enabled"]}`
+)
+
+func TestMain(m *testing.M) {
+	cache.Init()
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	*httptest.Server
+	configs configdata.ConfigService
+}
+
+// newTestServer serves the auth and config routes of cmd/server with a
+// single admin, alice, of the test tenant
+func newTestServer(t *testing.T, middleware ...gin.HandlerFunc) *testServer {
+	// Long polls and writes run on separate connections, they must share the database
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "config.db")+"?_txlock=immediate"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.UserRevocation{},
+		&models.Configurations{}, &models.LastConfigurations{}, &models.ConfigEvent{}, &models.OutboxEvent{}, &models.ConfigGrant{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	secs := &secrets.Secrets{JWTsecret: []byte("testsecret")}
+	revocations, err := auth.NewRevocationList(auth.NewRevocationRepo(db))
+	if err != nil {
+		t.Fatalf("failed to load revocations: %v", err)
+	}
+	userRepo := auth.NewUserRepo(db)
+	authService := auth.NewAuthService(userRepo, auth.NewTokenRepo(db), revocations,
+		&config.Config{AccessTokenTTLInDays: 1, RefreshTokenTTLInMinutes: 60}, secs)
+	if _, err := auth.NewUserService(userRepo, authService).CreateUser(testClientID, "alice", "password123", models.RoleAdmin); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	authHandler := auth.NewAuthHandler(authService)
+	configService := configdata.NewConfigService(configdata.NewConfigRepo(db), notify.New(notify.DefaultMaxWaiters))
+	configHandler := configdata.NewConfigHandler(configService)
+	policyService := policy.NewPolicyService(policy.NewPolicyRepo(db))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware...)
+	r.POST("/api/v1/login", func(c *gin.Context) { authHandler.Login(c.Writer, c.Request) })
+	r.POST("/api/v1/token/refresh", func(c *gin.Context) { authHandler.Refresh(c.Writer, c.Request) })
+	api := r.Group("/api/v1")
+	api.Use(auth.AuthMiddleware(secs, revocations))
+	canRead := policy.RequireConfigAccess(policyService, policy.ActionRead, policy.NameFromParam("name"))
+	api.GET("/configs/:name/latest", canRead, configHandler.GetLastVersionByName)
+	api.GET("/configs/:name/watch", canRead, configHandler.WatchConfig)
+	api.GET("/configs/:name/versions/:version", canRead, configHandler.GetConfigByNameByVersion)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, configs: configService}
+}
+
+// write stores a new version of name as alice
+func (s *testServer) write(t *testing.T, name, input string) {
+	cfg := &models.Configurations{ClientID: testClientID, Name: name, Type: models.TypeObject, Schema: testSchema, Input: input, CreatedBy: "alice", IsActive: 1}
+	if err := s.configs.Create(cfg); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+func newTestClient(t *testing.T, srv *testServer, cacheDir string) *Client {
+	c, err := New(Options{BaseURL: srv.URL, Username: "alice", Password: "password123", CacheDir: cacheDir,
+		WatchTimeout: time.Second, RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c
+}
+
+type flags struct {
+	Enabled bool `json:"enabled"`
+	Limit   int  `json:"limit"`
+}
+
+func TestClient_LoadAndGetVersion(t *testing.T) {
+	srv := newTestServer(t)
+	srv.write(t, "client.flags", `{"enabled":true,"limit":5}`)
+	srv.write(t, "client.flags", `{"enabled":false,"limit":7}`)
+	c := newTestClient(t, srv, "")
+	ctx := context.Background()
+
+	var got flags
+	cfg, err := c.Load(ctx, "client.flags", &got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Version != 2 || cfg.ETag == "" || cfg.Stale || got != (flags{Enabled: false, Limit: 7}) {
+		t.Fatalf("unexpected config %+v decoded as %+v", cfg, got)
+	}
+
+	v1, err := c.GetVersion(ctx, "client.flags", 1)
+	if err != nil || v1.Input != `{"enabled":true,"limit":5}` {
+		t.Fatalf("unexpected version 1: %+v, %v", v1, err)
+	}
+	if _, err := c.GetLatest(ctx, "client.missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_RenewsRejectedToken(t *testing.T) {
+	srv := newTestServer(t)
+	srv.write(t, "client.flags", `{"enabled":true}`)
+	c := newTestClient(t, srv, "")
+	ctx := context.Background()
+
+	if _, err := c.GetLatest(ctx, "client.flags"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	refresh := c.refreshToken
+
+	// A rejected access token is refreshed transparently
+	c.accessToken = "expired"
+	if _, err := c.GetLatest(ctx, "client.flags"); err != nil {
+		t.Fatalf("expected the token to be refreshed, got %v", err)
+	}
+	if c.refreshToken == refresh || c.accessToken == "expired" {
+		t.Fatal("expected a new token pair")
+	}
+
+	bad, _ := New(Options{BaseURL: srv.URL, Username: "alice", Password: "wrong"})
+	if _, err := bad.GetLatest(ctx, "client.flags"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestClient_FallsBackToLastKnownGood(t *testing.T) {
+	srv := newTestServer(t)
+	srv.write(t, "client.flags", `{"enabled":true,"limit":3}`)
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := newTestClient(t, srv, dir).GetLatest(ctx, "client.flags"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.Close()
+
+	// A new process starts while the service is down
+	c := newTestClient(t, srv, dir)
+	var got flags
+	cfg, err := c.Load(ctx, "client.flags", &got)
+	if err != nil {
+		t.Fatalf("expected the cached copy, got %v", err)
+	}
+	if !cfg.Stale || cfg.Version != 1 || got.Limit != 3 {
+		t.Fatalf("unexpected cached config %+v decoded as %+v", cfg, got)
+	}
+	if _, err := c.GetLatest(ctx, "client.other"); err == nil {
+		t.Fatal("expected an error without a cached copy")
+	}
+}
+
+func TestClient_RevalidatesCachedCopy(t *testing.T) {
+	var notModified int
+	srv := newTestServer(t, func(c *gin.Context) {
+		c.Next()
+		if c.Writer.Status() == http.StatusNotModified {
+			notModified++
+		}
+	})
+	srv.write(t, "client.flags", `{"enabled":true}`)
+	c := newTestClient(t, srv, "")
+	ctx := context.Background()
+
+	first, _ := c.GetLatest(ctx, "client.flags")
+	second, err := c.GetLatest(ctx, "client.flags")
+	if err != nil || second.Version != first.Version || notModified != 1 {
+		t.Fatalf("expected a 304 revalidation, got %+v, %v, %d", second, err, notModified)
+	}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	srv := newTestServer(t)
+	srv.write(t, "client.flags", `{"enabled":true}`)
+	c := newTestClient(t, srv, "")
+
+	var mu sync.Mutex
+	var versions []int
+	changed := make(chan struct{}, 10)
+	stop := c.Subscribe("client.flags", func(cfg *Config) {
+		mu.Lock()
+		versions = append(versions, cfg.Version)
+		mu.Unlock()
+		changed <- struct{}{}
+	})
+	defer stop()
+
+	waitChange := func() {
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a change")
+		}
+	}
+	waitChange()
+	srv.write(t, "client.flags", `{"enabled":false}`)
+	waitChange()
+	srv.write(t, "client.flags", `{"enabled":true}`)
+	waitChange()
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 3 {
+		t.Fatalf("expected versions 1, 2, 3, got %v", versions)
+	}
+}
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ConfigInput is a config to write. Schema and Input are JSON documents.
+type ConfigInput struct {
+	Name   string
+	Type   string
+	Schema string
+	Input  string
+	// Force makes an update store a schema that is not backward compatible, admins only
+	Force bool `json:",omitempty"`
+}
+
+// Page is a page of configs, NextCursor is empty on the last one
+type Page struct {
+	Items      []Config `json:"items"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+type ListOptions struct {
+	Prefix string
+	Query  string // substring of the name
+	Type   string
+	Limit  int
+	Cursor string
+}
+
+// Diff is what changed between two versions of a config
+type Diff struct {
+	Name    string    `json:"name"`
+	From    int       `json:"from"`
+	To      int       `json:"to"`
+	Patch   []PatchOp `json:"patch"`
+	Summary []Change  `json:"summary"`
+}
+
+// PatchOp is a JSON Patch (RFC 6902) operation
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Change is a path that was added, removed or changed
+type Change struct {
+	Path string          `json:"path"`
+	Type string          `json:"type"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// List returns a page of the latest versions of the configs the caller can read
+func (c *Client) List(ctx context.Context, opts ListOptions) (*Page, error) {
+	q := url.Values{}
+	setQuery(q, "prefix", opts.Prefix)
+	setQuery(q, "q", opts.Query)
+	setQuery(q, "type", opts.Type)
+	setQuery(q, "cursor", opts.Cursor)
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var page Page
+	if _, err := c.call(ctx, http.MethodGet, "/configs?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Versions returns a page of the history of a config, oldest first
+func (c *Client) Versions(ctx context.Context, name string, limit int, cursor string) (*Page, error) {
+	q := url.Values{}
+	setQuery(q, "cursor", cursor)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var page Page
+	if _, err := c.call(ctx, http.MethodGet, "/configs/"+url.PathEscape(name)+"/versions?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Diff compares two versions of a config, a to of 0 compares with the latest version
+func (c *Client) Diff(ctx context.Context, name string, from, to int) (*Diff, error) {
+	q := url.Values{}
+	q.Set("from", strconv.Itoa(from))
+	if to > 0 {
+		q.Set("to", strconv.Itoa(to))
+	}
+
+	var diff Diff
+	if _, err := c.call(ctx, http.MethodGet, "/configs/"+url.PathEscape(name)+"/diff?"+q.Encode(), nil, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// Create stores version 1 of a new config
+func (c *Client) Create(ctx context.Context, in ConfigInput) (*Config, error) {
+	return c.write(ctx, http.MethodPost, "/configs", in)
+}
+
+// Update stores a new version of a config. With an expectedVersion other
+// than 0 it fails with ErrVersionConflict once somebody else wrote a newer one.
+func (c *Client) Update(ctx context.Context, in ConfigInput, expectedVersion int) (*Config, error) {
+	body := struct {
+		ConfigInput
+		ExpectedVersion int `json:"expected_version,omitempty"`
+	}{in, expectedVersion}
+	return c.write(ctx, http.MethodPut, "/configs/"+url.PathEscape(in.Name), body)
+}
+
+// Rollback stores an old version again as the latest one. Its schema must be
+// compatible with the latest one unless force is set, admins only.
+func (c *Client) Rollback(ctx context.Context, name string, version, expectedVersion int, force bool) (*Config, error) {
+	q := url.Values{}
+	if expectedVersion > 0 {
+		q.Set("expected_version", strconv.Itoa(expectedVersion))
+	}
+	if force {
+		q.Set("force", "true")
+	}
+	return c.write(ctx, http.MethodPost, "/configs/"+url.PathEscape(name)+"/rollback/"+strconv.Itoa(version)+"?"+q.Encode(), nil)
+}
+
+// ValidationResult is the answer of the service to a write it was asked to
+// check: what it would store and everything that would stop it
+type ValidationResult struct {
+	Valid          bool                `json:"valid"`
+	Name           string              `json:"name"`
+	Version        int                 `json:"version"`
+	CurrentVersion int                 `json:"current_version"`
+	SchemaVersion  int                 `json:"schema_version"`
+	Diff           *Diff               `json:"diff"`
+	Errors         []ValidationFailure `json:"errors"`
+}
+
+type ValidationFailure struct {
+	Error      string      `json:"error"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Violation is where an input breaks its schema, as JSON pointers
+type Violation struct {
+	InstancePath string `json:"instance_path"`
+	KeywordPath  string `json:"keyword_path"`
+	Message      string `json:"message"`
+}
+
+// Validate runs in through every check of the service without storing it,
+// as an update of an existing config or else as a create. A write the
+// service would reject is a result with Valid unset, not an error.
+func (c *Client) Validate(ctx context.Context, in ConfigInput) (*ValidationResult, error) {
+	result, err := c.validate(ctx, "/configs/"+url.PathEscape(in.Name)+"/validate", in)
+	if errors.Is(err, ErrNotFound) {
+		return c.validate(ctx, "/configs/validate", in)
+	}
+	return result, err
+}
+
+func (c *Client) validate(ctx context.Context, path string, in ConfigInput) (*ValidationResult, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, http.MethodPost, path, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnprocessableEntity {
+		return nil, responseError(resp)
+	}
+
+	var result ValidationResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) write(ctx context.Context, method, path string, body interface{}) (*Config, error) {
+	var cfg Config
+	header, err := c.call(ctx, method, path, body, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ETag = header.Get("ETag")
+	c.cache.put(&cfg)
+	return &cfg, nil
+}
+
+// call sends in as JSON and decodes a successful response into out
+func (c *Client) call(ctx context.Context, method, path string, in, out interface{}) (http.Header, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.do(ctx, method, path, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
+
+func setQuery(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
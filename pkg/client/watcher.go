@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Subscribe calls fn from a background goroutine with the current version of
+// the config and then with every newer one, until the returned stop func is
+// called. When the service is down, fn first gets the last known good copy.
+// Calls to fn never overlap.
+func (c *Client) Subscribe(name string, fn func(*Config)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.watchLoop(ctx, name, fn)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (c *Client) watchLoop(ctx context.Context, name string, fn func(*Config)) {
+	// GetLatest falls back to the last known good copy
+	after := 0
+	if cfg, err := c.GetLatest(ctx, name); err == nil {
+		fn(cfg)
+		after = cfg.Version
+	}
+
+	for {
+		cfg, err := c.Watch(ctx, name, after)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("config client: failed to watch %s: %v", name, err)
+			select {
+			case <-time.After(c.opts.RetryInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if cfg != nil && cfg.Version > after {
+			fn(cfg)
+			after = cfg.Version
+		}
+	}
+}
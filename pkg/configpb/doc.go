@@ -0,0 +1,3 @@
+// Package configpb is the generated gRPC API of the config service, see
+// proto/configsvc/v1/config.proto. Regenerate it with `make proto`.
+package configpb